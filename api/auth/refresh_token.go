@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// NewRefreshToken returns an opaque refresh token for the client and the hash that is stored in the database
+func NewRefreshToken() (string, string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buffer)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken hashes a refresh token so a leaked table cannot be replayed
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenFamily returns a random id shared by all the refresh tokens of one login
+func NewTokenFamily() (string, error) {
+	return newTokenID()
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"gorm.io/gorm"
+)
+
+// RevocationStore keeps track of the access tokens that were logged out before they expired
+type RevocationStore interface {
+	IsRevoked(token *TokenMetadata) bool
+	RevokeToken(token *TokenMetadata) error
+	RevokeAllSessions(uid uint32) error
+}
+
+// Revocations is checked on every token verification, the server replaces it with a database backed store
+var Revocations RevocationStore = NewRevocationStore(nil)
+
+// how long the cache is trusted before rows written by other instances are loaded again
+const revocationCacheTTL = 30 * time.Second
+
+type cachedRevocationStore struct {
+	db       *gorm.DB
+	mu       sync.RWMutex
+	tokens   map[string]time.Time // jti -> token expiry
+	users    map[uint32]time.Time // user id -> tokens issued up to this time are revoked
+	loadedAt time.Time
+}
+
+// NewRevocationStore returns a store persisted in the revoked_tokens table and cached in memory,
+// with a nil db it only lives in memory which is enough for tests
+func NewRevocationStore(db *gorm.DB) RevocationStore {
+	store := &cachedRevocationStore{
+		db:     db,
+		tokens: map[string]time.Time{},
+		users:  map[uint32]time.Time{},
+	}
+	store.reload()
+	return store
+}
+
+func (s *cachedRevocationStore) IsRevoked(token *TokenMetadata) bool {
+	s.mu.RLock()
+	stale := time.Since(s.loadedAt) > revocationCacheTTL
+	s.mu.RUnlock()
+	if stale {
+		s.reload()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if token.JTI != "" {
+		if _, ok := s.tokens[token.JTI]; ok {
+			return true
+		}
+	}
+	// the tokens are issued to the millisecond, one of the same millisecond as the cutoff is revoked with it
+	if cutoff, ok := s.users[token.UserID]; ok && !token.IssuedAt.After(cutoff.Truncate(time.Millisecond)) {
+		return true
+	}
+	return false
+}
+
+func (s *cachedRevocationStore) RevokeToken(token *TokenMetadata) error {
+	if token.JTI == "" {
+		return nil
+	}
+
+	if s.db != nil {
+		revokedToken := models.RevokedToken{
+			JTI:       token.JTI,
+			UserID:    token.UserID,
+			ExpiresAt: token.ExpiresAt,
+		}
+		if _, err := revokedToken.SaveRevokedToken(s.db); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.tokens[token.JTI] = token.ExpiresAt
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *cachedRevocationStore) RevokeAllSessions(uid uint32) error {
+	now := time.Now()
+
+	if s.db != nil {
+		// no access token issued before now can outlive its ttl, the row is not needed after that
+		revokedToken := models.RevokedToken{
+			UserID:      uid,
+			AllSessions: true,
+			ExpiresAt:   now.Add(AccessTokenTTL()),
+		}
+		revokedToken.CreatedAt = now
+		if _, err := revokedToken.SaveRevokedToken(s.db); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	s.users[uid] = now
+	s.mu.Unlock()
+	return nil
+}
+
+// reload replaces the cache with the active rows of the table, expired entries are dropped on the way
+func (s *cachedRevocationStore) reload() {
+	if s.db == nil {
+		s.mu.Lock()
+		s.pruneExpired()
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+		return
+	}
+
+	revokedToken := models.RevokedToken{}
+	revokedTokens, err := revokedToken.FindActiveRevokedTokens(s.db)
+	if err != nil {
+		// keep serving the old cache, it is retried on the next check
+		log.Println("cannot load revoked tokens:", err)
+		return
+	}
+
+	tokens := map[string]time.Time{}
+	users := map[uint32]time.Time{}
+	for _, revoked := range *revokedTokens {
+		if revoked.AllSessions {
+			if cutoff, ok := users[revoked.UserID]; !ok || revoked.CreatedAt.After(cutoff) {
+				users[revoked.UserID] = revoked.CreatedAt
+			}
+			continue
+		}
+		tokens[revoked.JTI] = revoked.ExpiresAt
+	}
+
+	s.mu.Lock()
+	s.tokens = tokens
+	s.users = users
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+}
+
+func (s *cachedRevocationStore) pruneExpired() {
+	now := time.Now()
+	for jti, expiresAt := range s.tokens {
+		if now.After(expiresAt) {
+			delete(s.tokens, jti)
+		}
+	}
+	for uid, cutoff := range s.users {
+		if now.After(cutoff.Add(AccessTokenTTL())) {
+			delete(s.users, uid)
+		}
+	}
+}
@@ -1,41 +1,78 @@
 package auth
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/Mdromi/exp-blog-backend/api/models"
 	"github.com/dgrijalva/jwt-go"
 )
 
-func CreateToken(id uint32) (string, error) {
+var (
+	// ErrTokenExpired is returned when the token signature is fine but its exp claim is in the past
+	ErrTokenExpired = errors.New("token has expired")
+	// ErrTokenInvalid is returned for every other token that cannot be trusted
+	ErrTokenInvalid = errors.New("invalid token")
+	// ErrTokenRevoked is returned for a token that was logged out before it expired
+	ErrTokenRevoked = errors.New("token has been revoked")
+)
+
+// TokenMetadata holds the claims of a verified token that the server cares about
+type TokenMetadata struct {
+	UserID    uint32
+	Role      string
+	JTI       string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// AccessTokenTTL is how long an access token is valid, it can be changed with ACCESS_TOKEN_TTL (e.g. "30m")
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
+}
+
+// RefreshTokenTTL is how long a refresh token is valid, it can be changed with REFRESH_TOKEN_TTL (e.g. "168h")
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+func CreateToken(id uint32, role string) (string, error) {
+	jti, err := newTokenID()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["id"] = id
+	claims["role"] = role
+	claims["permissions"] = models.RolePermissions(role)
+	claims["jti"] = jti
+	claims["iat"] = now.Unix()
+	claims["iat_ms"] = now.UnixMilli() // iat is in seconds, logging out all the sessions needs to tell apart the tokens of the same second
+	claims["exp"] = now.Add(AccessTokenTTL()).Unix()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(os.Getenv("API_SECRET")))
 }
 
 func TokenValid(r *http.Request) error {
-	tokenString := ExtractToken(r)
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("Unexpected signing method : %v", token.Header["alg"])
-		}
-		return []byte(os.Getenv("API_SECRET")), nil
-	})
-	if err != nil {
-		return err
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		Pretty(claims)
-	}
-	return nil
+	_, err := ExtractTokenMetadata(r)
+	return err
 }
 
 func ExtractToken(r *http.Request) string {
@@ -52,28 +89,107 @@ func ExtractToken(r *http.Request) string {
 	return ""
 }
 
+// ExtractTokenRole returns the role the token was issued with
+func ExtractTokenRole(r *http.Request) (string, error) {
+	metadata, err := ExtractTokenMetadata(r)
+	if err != nil {
+		return "", err
+	}
+	return metadata.Role, nil
+}
+
 func ExtractTokenID(r *http.Request) (uint32, error) {
-	tokenString := ExtractToken(r)
+	metadata, err := ExtractTokenMetadata(r)
+	if err != nil {
+		return 0, err
+	}
+	return metadata.UserID, nil
+}
+
+// ExtractTokenMetadata verifies the request token, including the revocation list, and returns its claims
+func ExtractTokenMetadata(r *http.Request) (*TokenMetadata, error) {
+	claims, err := parseToken(ExtractToken(r))
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["id"]), 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	// tokens without a role claim only get the smallest set of permissions
+	metadata := &TokenMetadata{UserID: uint32(uid), Role: models.RoleReader}
+	if role, ok := claims["role"].(string); ok && models.IsValidRole(role) {
+		metadata.Role = role
+	}
+	if jti, ok := claims["jti"].(string); ok {
+		metadata.JTI = jti
+	}
+	if iat, ok := claims["iat"].(float64); ok {
+		metadata.IssuedAt = time.Unix(int64(iat), 0)
+	}
+	if iatMs, ok := claims["iat_ms"].(float64); ok {
+		metadata.IssuedAt = time.UnixMilli(int64(iatMs))
+	}
+	if exp, ok := claims["exp"].(float64); ok {
+		metadata.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+
+	if Revocations != nil && Revocations.IsRevoked(metadata) {
+		return nil, ErrTokenRevoked
+	}
+	return metadata, nil
+}
+
+// parseToken verifies the signature and the registered claims of the token,
+// an expired token is reported as ErrTokenExpired so callers can tell the client to refresh
+func parseToken(tokenString string) (jwt.MapClaims, error) {
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(os.Getenv("API_SECRET")), nil
 	})
-
 	if err != nil {
-		return 0, err
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) && validationErr.Errors&jwt.ValidationErrorExpired != 0 {
+			return nil, ErrTokenExpired
+		}
+		return nil, err
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
-	if ok && token.Valid {
-		uid, err := strconv.ParseUint(fmt.Sprintf("%.0f", claims["id"]), 10, 32)
-		if err != nil {
-			return 0, err
-		}
-		return uint32(uid), nil
+	if !ok || !token.Valid {
+		return nil, ErrTokenInvalid
+	}
+
+	// tokens issued before expiry was introduced never expire, so they are not accepted anymore
+	if _, ok := claims["exp"]; !ok {
+		return nil, ErrTokenInvalid
+	}
+	return claims, nil
+}
+
+func newTokenID() (string, error) {
+	buffer := make([]byte, 16)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		log.Printf("invalid %s %q, using %s", key, value, fallback)
+		return fallback
 	}
-	return 0, nil
+	return duration
 }
 
 // Pretty display the claims licely in the termianl
@@ -0,0 +1,307 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// adminResource describes how the admin api reads and deletes one kind of row
+type adminResource struct {
+	targetType    string
+	newModel      func() interface{}
+	newList       func() interface{}
+	searchColumns []string
+	ownerColumn   string
+	omitColumns   []string // left out of the admin list
+	hardDelete    func(db *gorm.DB, id uint64) error
+	spamText      func(record interface{}) string // the text the spam classifier learns from, nil when it cannot be labelled
+}
+
+var adminResources = map[string]adminResource{
+	"users": {
+		targetType:    "user",
+		newModel:      func() interface{} { return &models.User{} },
+		newList:       func() interface{} { return &[]models.User{} },
+		searchColumns: []string{"username", "email"},
+		ownerColumn:   "profile_id",
+		omitColumns:   []string{"password"},
+		hardDelete:    hardDeleteUser,
+	},
+	"profiles": {
+		targetType:    "profile",
+		newModel:      func() interface{} { return &models.Profile{} },
+		newList:       func() interface{} { return &[]models.Profile{} },
+		searchColumns: []string{"name", "username"},
+		ownerColumn:   "user_id",
+		hardDelete:    hardDeleteProfile,
+	},
+	"posts": {
+		targetType:    "post",
+		newModel:      func() interface{} { return &models.Post{} },
+		newList:       func() interface{} { return &[]models.Post{} },
+		searchColumns: []string{"title", "content"},
+		ownerColumn:   "author_id",
+		hardDelete:    hardDeletePost,
+		spamText: func(record interface{}) string {
+			post := record.(*models.Post)
+			return post.Title + "\n" + post.Content
+		},
+	},
+	"comments": {
+		targetType:    "comment",
+		newModel:      func() interface{} { return &models.Comment{} },
+		newList:       func() interface{} { return &[]models.Comment{} },
+		searchColumns: []string{"body"},
+		ownerColumn:   "profile_id",
+		hardDelete:    hardDeleteComment,
+		spamText: func(record interface{}) string {
+			return record.(*models.Comment).Body
+		},
+	},
+}
+
+// GET /admin/:resource?status=suspended&q=spam&owner_id=3&limit=20&cursor=...
+func (server *Server) AdminList(c *gin.Context) {
+	errList := map[string]string{}
+
+	resource, ok := adminResources[c.Param("resource")]
+	if !ok {
+		errList["Invalid_resource"] = "Unknown resource"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	filter := models.AdminFilter{
+		Status:        c.Query("status"),
+		Query:         c.Query("q"),
+		SearchColumns: resource.searchColumns,
+		OwnerColumn:   resource.ownerColumn,
+		Omit:          resource.omitColumns,
+	}
+	switch filter.Status {
+	case "", models.StatusActive, models.StatusSuspended, models.StatusDeleted:
+	default:
+		errList["Invalid_status"] = "Status should be active, suspended or deleted"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+	if ownerID := c.Query("owner_id"); ownerID != "" {
+		oid, err := strconv.ParseUint(ownerID, 10, 64)
+		if err != nil {
+			errList["Invalid_request"] = "Invalid Request"
+			handleError(c, http.StatusBadRequest, errList)
+			return
+		}
+		filter.OwnerID = oid
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	list := resource.newList()
+	err := models.AdminFind(server.DB, resource.newModel(), list, filter, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   list,
+		"pagination": pg,
+	})
+}
+
+func (server *Server) AdminSuspend(c *gin.Context) {
+	server.adminAction(c, models.AuditActionSuspend)
+}
+
+func (server *Server) AdminRestore(c *gin.Context) {
+	server.adminAction(c, models.AuditActionRestore)
+}
+
+func (server *Server) AdminHardDelete(c *gin.Context) {
+	server.adminAction(c, models.AuditActionHardDelete)
+}
+
+func (server *Server) AdminLabelSpam(c *gin.Context) {
+	server.adminAction(c, models.AuditActionLabelSpam)
+}
+
+func (server *Server) AdminLabelHam(c *gin.Context) {
+	server.adminAction(c, models.AuditActionLabelHam)
+}
+
+func (server *Server) GetAuditLogs(c *gin.Context) {
+	errList := map[string]string{}
+
+	var actorID uint64
+	if actor := c.Query("actor_id"); actor != "" {
+		var err error
+		actorID, err = strconv.ParseUint(actor, 10, 32)
+		if err != nil {
+			errList["Invalid_request"] = "Invalid Request"
+			handleError(c, http.StatusBadRequest, errList)
+			return
+		}
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	auditLog := models.AuditLog{}
+	auditLogs, err := auditLog.FindAuditLogs(server.DB, c.Query("target_type"), uint32(actorID), pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   auditLogs,
+		"pagination": pg,
+	})
+}
+
+// adminAction runs one admin action on /admin/:resource/:id and writes it to the audit log
+func (server *Server) adminAction(c *gin.Context, action string) {
+	errList := map[string]string{}
+
+	resource, ok := adminResources[c.Param("resource")]
+	if !ok {
+		errList["Invalid_resource"] = "Unknown resource"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	actorID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	// the reason is optional, it only ends up in the audit log
+	requestBody := map[string]string{}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err == nil && len(body) > 0 {
+		if err := json.Unmarshal(body, &requestBody); err != nil {
+			errList["Unmarshal_error"] = "Cannot unmarshal body"
+			handleError(c, http.StatusUnprocessableEntity, errList)
+			return
+		}
+	}
+
+	// make sure the row exists, whatever its state is
+	record := resource.newModel()
+	err = server.DB.Unscoped().Model(resource.newModel()).Where("id = ?", id).Take(record).Error
+	if err != nil {
+		errList["No_record"] = "No Record Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	isLabel := action == models.AuditActionLabelSpam || action == models.AuditActionLabelHam
+	if isLabel && resource.spamText == nil {
+		errList["Invalid_resource"] = "Only posts and comments can be labelled"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	// the action and its audit log are written together, or neither is
+	auditLog := models.AuditLog{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: resource.targetType,
+		TargetID:   id,
+		Details:    requestBody["reason"],
+	}
+	err = server.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		switch action {
+		case models.AuditActionSuspend:
+			_, err = models.SuspendRecord(tx, resource.newModel(), id)
+			if err == nil && resource.targetType == "user" {
+				refreshToken := models.RefreshToken{}
+				_, err = refreshToken.RevokeUserRefreshTokens(tx, uint32(id))
+			}
+		case models.AuditActionRestore:
+			_, err = models.RestoreRecord(tx, resource.newModel(), id)
+		case models.AuditActionHardDelete:
+			err = resource.hardDelete(tx, id)
+		case models.AuditActionLabelSpam, models.AuditActionLabelHam:
+			sample := models.SpamSample{
+				Kind:       resource.targetType,
+				RecordID:   id,
+				Text:       resource.spamText(record),
+				Spam:       action == models.AuditActionLabelSpam,
+				LabelledBy: actorID,
+			}
+			_, err = sample.LabelSpam(tx)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = auditLog.SaveAuditLog(tx)
+		return err
+	})
+	if err == nil && action == models.AuditActionSuspend && resource.targetType == "user" {
+		// a suspended user must not keep using the access tokens it already has
+		err = auth.Revocations.RevokeAllSessions(uint32(id))
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": auditLog,
+	})
+}
+
+// The hard deletes run the model cascades on an unscoped db, so the rows are removed for good
+
+func hardDeleteUser(db *gorm.DB, id uint64) error {
+	user := models.User{}
+	return user.DeleteUserCascade(models.HardDeleteDB(db), uint32(id))
+}
+
+func hardDeleteProfile(db *gorm.DB, id uint64) error {
+	profile := models.Profile{}
+	return profile.DeleteProfileCascade(models.HardDeleteDB(db), uint32(id))
+}
+
+func hardDeletePost(db *gorm.DB, id uint64) error {
+	post := models.Post{}
+	post.ID = uint(id)
+	return post.DeletePostCascade(models.HardDeleteDB(db))
+}
+
+func hardDeleteComment(db *gorm.DB, id uint64) error {
+	comment := models.Comment{}
+	comment.ID = uint(id)
+	_, err := comment.DeleteAComment(models.HardDeleteDB(db))
+	return err
+}
@@ -4,9 +4,13 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 
+	"github.com/Mdromi/exp-blog-backend/api/auth"
 	"github.com/Mdromi/exp-blog-backend/api/middlewares"
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/moderation"
+	"github.com/Mdromi/exp-blog-backend/api/storage"
 	"github.com/gin-gonic/gin"
 	"gorm.io/driver/mysql"    //mysql database driver
 	"gorm.io/driver/postgres" //postgres database driver
@@ -14,8 +18,9 @@ import (
 )
 
 type Server struct {
-	DB     *gorm.DB
-	Router *gin.Engine
+	DB      *gorm.DB
+	Router  *gin.Engine
+	Storage storage.Backend
 }
 
 var errList = make(map[string]string)
@@ -47,6 +52,12 @@ func (server *Server) Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost, D
 		fmt.Println("Unknown Driver")
 	}
 
+	// changing a reaction used to save it again, only the last one of each reader is kept for the unique index
+	backfillReactions, err := models.DedupeReactions(server.DB)
+	if err != nil {
+		log.Println("cannot dedupe the reactions:", err)
+	}
+
 	// database migration
 	server.DB.Debug().AutoMigrate(
 		&models.User{},
@@ -56,10 +67,84 @@ func (server *Server) Initialize(Dbdriver, DbUser, DbPassword, DbPort, DbHost, D
 		&models.ResetPassword{},
 		&models.LikeDislike{},
 		&models.Comment{},
-		&models.Replyes{},
 		&models.SocialLink{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.AuditLog{},
+		&models.Tag{},
+		&models.PostSlug{},
+		&models.PostRevision{},
+		&models.Series{},
+		&models.SeriesPost{},
+		&models.PostCollaborator{},
+		&models.PostMedia{},
+		&models.MediaObject{},
+		&models.SpamSample{},
+		&models.Report{},
 	)
 
+	// replies used to have their own table, they are comments in the threads now
+	if err := models.MigrateCommentThreads(server.DB); err != nil {
+		log.Println("cannot migrate the comment threads:", err)
+	}
+
+	// the posts migrated before the reaction counters get them counted once
+	if backfillReactions {
+		if err := models.RefreshReactionCounts(server.DB); err != nil {
+			log.Println("cannot count the reactions:", err)
+		}
+	}
+
+	// the references the cascading deletes rely on, gorm only creates the ones of the associations
+	models.AddForeignKeys(server.DB)
+
+	// posts used to keep their tags in a postgres only text[] column
+	if err := models.MigrateLegacyPostTags(server.DB); err != nil {
+		log.Println("cannot migrate the post tags:", err)
+	}
+
+	// posts created before the permalink history have no entry in it yet
+	if err := models.BackfillPostSlugs(server.DB); err != nil {
+		log.Println("cannot backfill the post permalinks:", err)
+	}
+
+	// posts saved before the markdown pipeline have their content escaped and no HTML, nor an excerpt
+	if err := models.RenderLegacyPosts(server.DB); err != nil {
+		log.Println("cannot render the legacy posts:", err)
+	}
+
+	// the posts saved before the search column are indexed once
+	if err := models.MigratePostSearch(server.DB); err != nil {
+		log.Println("cannot index the posts for the search:", err)
+	}
+
+	// the uploads go to the local disk, an S3 compatible bucket or memory
+	server.Storage, err = storage.FromEnv()
+	if err != nil {
+		log.Fatal("This is the error setting up the storage:", err)
+	}
+	storage.Signer, err = storage.SignerFromEnv(server.Storage)
+	if err != nil {
+		log.Fatal("This is the error setting up the media urls:", err)
+	}
+
+	// comments and posts go through the filters when they are saved, the classifier learns the labelled spam
+	moderation.Default = moderation.FromEnv()
+	if err := models.TrainSpamClassifier(server.DB); err != nil {
+		log.Println("cannot train the spam classifier:", err)
+	}
+
+	// logged out tokens are checked on every request, keep them cached next to the database
+	auth.Revocations = auth.NewRevocationStore(server.DB)
+
+	// there is no way to sign up as an admin, so the first one comes from the environment
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		user := models.User{}
+		if _, err := user.PromoteAdmin(server.DB, adminEmail); err != nil {
+			log.Println("cannot promote the admin user:", err)
+		}
+	}
+
 	// Add the SocialLink field as JSONB type
 	// if err := server.DB.Migrator().AlterColumn(&models.Profile{}, "social_links", ""); err != nil {
 	// 	log.Fatal(err)
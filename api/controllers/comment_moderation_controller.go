@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /moderation/comments?status=pending lists the comments waiting on every post of the authenticated profile
+func (server *Server) GetModerationQueue(c *gin.Context) {
+	errList := map[string]string{}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	status := c.DefaultQuery("status", models.CommentStatusPending)
+	if !models.IsValidCommentStatus(status) {
+		errList["Invalid_status"] = "Status should be pending, approved or rejected"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	comments, err := models.FindModerationQueue(server.DB, profileID, status, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   comments,
+		"pagination": pg,
+	})
+}
+
+// PUT /moderation/comments/:id/approve shows the comment to the readers
+func (server *Server) ApproveComment(c *gin.Context) {
+	server.setCommentStatus(c, models.CommentStatusApproved)
+}
+
+// PUT /moderation/comments/:id/reject keeps the comment hidden from the readers
+func (server *Server) RejectComment(c *gin.Context) {
+	server.setCommentStatus(c, models.CommentStatusRejected)
+}
+
+func (server *Server) setCommentStatus(c *gin.Context, status string) {
+	errList := map[string]string{}
+
+	comment, ok := server.findModeratedComment(c, errList)
+	if !ok {
+		return
+	}
+
+	comment, err := comment.SetCommentStatus(server.DB, status)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": comment,
+	})
+}
+
+// DELETE /moderation/comments/:id deletes a comment on a post of the authenticated profile, with its replies
+func (server *Server) DeleteModeratedComment(c *gin.Context) {
+	errList := map[string]string{}
+
+	comment, ok := server.findModeratedComment(c, errList)
+	if !ok {
+		return
+	}
+
+	// the author of the comment cannot take it back from the trash
+	comment.DeletedBy, _ = auth.ExtractTokenID(c.Request)
+	_, err := comment.DeleteAComment(server.DB)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Comment deleted",
+	})
+}
+
+// findModeratedComment loads the comment of the :id param when the authenticated profile owns its post or
+// moderates every comment, it writes the error response itself otherwise
+func (server *Server) findModeratedComment(c *gin.Context, errList map[string]string) (*models.Comment, bool) {
+	cid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return nil, false
+	}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return nil, false
+	}
+
+	comment := models.Comment{}
+	err = server.DB.Debug().Model(models.Comment{}).Where("id = ?", cid).Take(&comment).Error
+	if err != nil {
+		errList["No_comment"] = "No Comment Found"
+		handleError(c, http.StatusNotFound, errList)
+		return nil, false
+	}
+
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", comment.PostID).Take(&post).Error
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return nil, false
+	}
+
+	role, err := post.RoleOf(server.DB, uint(profileID))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return nil, false
+	}
+	if !models.CanManagePost(role) && !HasPermission(c, models.PermissionModerateComments) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return nil, false
+	}
+	return &comment, true
+}
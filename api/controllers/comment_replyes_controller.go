@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -40,7 +41,8 @@ func (server *Server) CreateCommentReplye(c *gin.Context) {
 		return
 	}
 
-	replye := models.Replyes{}
+	// a reply is a comment with the comment it replies to as its parent
+	replye := models.Comment{}
 	err = json.Unmarshal(body, &replye)
 	if err != nil {
 		errList["Unmarshal_error"] = "Cannot unmarshal body"
@@ -49,9 +51,9 @@ func (server *Server) CreateCommentReplye(c *gin.Context) {
 	}
 
 	// erter the profile, comment and the postid. the reply body is automatically passed
-	replye.ProfileID = uint64(profileID)
-	replye.PostID = uint32(pid)
-	replye.CommentID = cid
+	replye.ProfileID = profileID
+	replye.PostID = pid
+	replye.ParentID = &cid
 
 	replye.Preapre()
 	errorMessages := replye.Validate("")
@@ -61,7 +63,17 @@ func (server *Server) CreateCommentReplye(c *gin.Context) {
 		return
 	}
 
-	commentReplyCreated, err := replye.SaveCommentReplyes(server.DB)
+	commentReplyCreated, err := replye.SaveComment(server.DB)
+	if errors.Is(err, models.ErrCommentParentNotFound) {
+		errList["No_comment"] = "No Comment Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	if errors.Is(err, models.ErrCommentTooDeep) {
+		errList["Too_deep"] = err.Error()
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		errList = formattedError
@@ -110,8 +122,28 @@ func (server *Server) GetCommentReplyes(c *gin.Context) {
 		return
 	}
 
-	replye := models.Replyes{}
-	replyes, err := replye.GetCommentReplyes(server.DB, cid)
+	// the replies are read on the post of the comment
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", origComment.PostID).Take(&post).Error
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	if !server.checkPostVisible(c, &post) {
+		return
+	}
+
+	depth, ok := GetThreadDepth(c)
+	if !ok {
+		return
+	}
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	replyes, err := origComment.GetCommentThreads(server.DB, origComment.PostID, cid, depth, pg)
 	if err != nil {
 		errList["No_comment_replyes"] = "No Comment Replyes Found"
 		handleError(c, http.StatusNotFound, errList)
@@ -119,8 +151,9 @@ func (server *Server) GetCommentReplyes(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": replyes,
+		"status":     http.StatusOK,
+		"response":   replyes,
+		"pagination": pg,
 	})
 }
 
@@ -179,22 +212,22 @@ func (server *Server) UpdateACommentReplyes(c *gin.Context) {
 	}
 
 	// check if the comment replyes exist
-	origCommentReplyes := models.Replyes{}
-	err = server.DB.Debug().Model(models.Replyes{}).Where("id = ? AND comment_id = ? AND profile_id = ?", rcid, cid, profileID).Take(&origCommentReplyes).Error
+	origCommentReplyes := models.Comment{}
+	err = server.DB.Debug().Model(models.Comment{}).Where("id = ? AND parent_id = ?", rcid, cid).Take(&origCommentReplyes).Error
 	if err != nil {
 		errList["Invalid_request"] = "Invalid Request"
 		handleError(c, http.StatusBadRequest, errList)
 		return
 	}
 
-	if profileID != uint32(origCommentReplyes.ProfileID) {
+	if profileID != origCommentReplyes.ProfileID && !HasPermission(c, models.PermissionModerateComments) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
 
 	// start processing requested data
-	replye := models.Replyes{}
+	replye := models.Comment{}
 	err = json.Unmarshal(body, &replye)
 	if err != nil {
 		errList["Unmarshal_error"] = "Cannot unmarshal body"
@@ -213,10 +246,12 @@ func (server *Server) UpdateACommentReplyes(c *gin.Context) {
 	replye.ID = origCommentReplyes.ID //this is important to tell the model the post id to update, the other update field are set above
 	replye.ProfileID = origCommentReplyes.ProfileID
 	replye.PostID = origCommentReplyes.PostID
-	replye.CommentID = origCommentReplyes.CommentID
-	replye.PostID = origCommentReplyes.PostID
+	replye.Status = origCommentReplyes.Status
+	replye.ParentID = origCommentReplyes.ParentID
+	replye.Path = origCommentReplyes.Path
+	replye.Depth = origCommentReplyes.Depth
 
-	commentReplyUpdated, err := replye.UpdateACommentReplyes(server.DB)
+	commentReplyUpdated, err := replye.UpdateAComment(server.DB)
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		errList = formattedError
@@ -264,29 +299,24 @@ func (server *Server) DeleteCommentReplye(c *gin.Context) {
 	}
 
 	// check if the comment replyes exist
-	origCommentReplyes := models.Replyes{}
-	err = server.DB.Debug().Model(models.Replyes{}).Where("id = ? AND comment_id = ? AND profile_id = ?", rcid, cid, profileID).Take(&origCommentReplyes).Error
+	origCommentReplyes := models.Comment{}
+	err = server.DB.Debug().Model(models.Comment{}).Where("id = ? AND parent_id = ?", rcid, cid).Take(&origCommentReplyes).Error
 	if err != nil {
 		errList["Invalid_request"] = "Invalid Request"
 		handleError(c, http.StatusBadRequest, errList)
 		return
 	}
 
-	if profileID != uint32(origCommentReplyes.ProfileID) {
-		errList["Unauthorized"] = "Unauthorized"
-		handleError(c, http.StatusUnauthorized, errList)
-		return
-	}
-
-	// Is the authenticated user, the owner of this replye?
-	if profileID != uint32(origCommentReplyes.ProfileID) {
+	// Is the authenticated user, the owner of this replye or a moderator?
+	if profileID != origCommentReplyes.ProfileID && !HasPermission(c, models.PermissionModerateComments) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
 
 	// If all the conditions are met, delete the post
-	_, err = origCommentReplyes.DeleteAReplyes(server.DB)
+	origCommentReplyes.DeletedBy = profileID
+	_, err = origCommentReplyes.DeleteAComment(server.DB)
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		handleError(c, http.StatusNotFound, errList)
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
 	"net/http"
 	"strconv"
@@ -32,7 +33,8 @@ func (server *Server) CreateComment(c *gin.Context) {
 		return
 	}
 
-	// erter the profile and the postid. the comment body is automatically passed
+	// erter the profile and the postid. the comment body is automatically passed, with the parent_id of the
+	// comment it replies to if any
 	comment.ProfileID = profileID
 	comment.PostID = pid
 
@@ -45,6 +47,16 @@ func (server *Server) CreateComment(c *gin.Context) {
 	}
 
 	commentCreated, err := comment.SaveComment(server.DB)
+	if errors.Is(err, models.ErrCommentParentNotFound) {
+		errList["No_comment"] = "No Comment Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	if errors.Is(err, models.ErrCommentTooDeep) {
+		errList["Too_deep"] = err.Error()
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		errList = formattedError
@@ -79,10 +91,23 @@ func (server *Server) GetComments(c *gin.Context) {
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
+	if !server.checkPostVisible(c, &post) {
+		return
+	}
+
+	depth, ok := GetThreadDepth(c)
+	if !ok {
+		return
+	}
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
 
 	comment := models.Comment{}
 
-	comments, err := comment.GetComments(server.DB, pid)
+	// the top comments of the post, with their replies nested up to the depth
+	comments, err := comment.GetCommentThreads(server.DB, pid, 0, depth, pg)
 	if err != nil {
 		errList["No_comments"] = "No comments found"
 		handleError(c, http.StatusNotFound, errList)
@@ -90,8 +115,9 @@ func (server *Server) GetComments(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": comments,
+		"status":     http.StatusOK,
+		"response":   comments,
+		"pagination": pg,
 	})
 }
 
@@ -124,7 +150,7 @@ func (server *Server) UpdateComment(c *gin.Context) {
 		return
 	}
 
-	if profileID != origComment.ProfileID {
+	if profileID != origComment.ProfileID && !HasPermission(c, models.PermissionModerateComments) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
@@ -158,6 +184,7 @@ func (server *Server) UpdateComment(c *gin.Context) {
 	comment.ID = origComment.ID //this is important to tell the model the post id to update, the other update field are set above
 	comment.ProfileID = origComment.ProfileID
 	comment.PostID = origComment.PostID
+	comment.Status = origComment.Status
 
 	commentUpdated, err := comment.UpdateAComment(server.DB)
 	if err != nil {
@@ -200,13 +227,14 @@ func (server *Server) DeleteComment(c *gin.Context) {
 		return
 	}
 
-	if profileID != origComment.ProfileID {
+	if profileID != origComment.ProfileID && !HasPermission(c, models.PermissionModerateComments) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
 
 	// If all the conditions are met, delete the post
+	origComment.DeletedBy = profileID
 	_, err = origComment.DeleteAComment(server.DB)
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
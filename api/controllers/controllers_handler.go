@@ -2,11 +2,13 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/Mdromi/exp-blog-backend/api/auth"
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
@@ -30,6 +32,73 @@ func FindUserProfileByID(db *gorm.DB, userID uint32) (*models.Profile, error) {
 	return profile, nil
 }
 
+// HasPermission tells if the role of the request token grants the permission,
+// controllers use it to let editors and admins act on resources they do not own
+func HasPermission(c *gin.Context, permission string) bool {
+	role, err := auth.ExtractTokenRole(c.Request)
+	if err != nil {
+		return false
+	}
+	return models.RoleHasPermission(role, permission)
+}
+
+// GetViewerID returns the id in the token of a request on a public route, 0 when it has no valid token
+func GetViewerID(c *gin.Context) uint {
+	uid, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		return 0
+	}
+	return uint(uid)
+}
+
+// checkPostVisible tells if the viewer can read the post of a post route. Like GetPost it answers 404 for a post the
+// viewer cannot see, so its id does not give a draft away, and writes the error response itself.
+func (server *Server) checkPostVisible(c *gin.Context, post *models.Post) bool {
+	errList := map[string]string{}
+	visible, err := post.VisibleTo(server.DB, GetViewerID(c))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return false
+	}
+	if !visible {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return false
+	}
+	return true
+}
+
+// GetPagination reads the limit, page, cursor and sort query params of a list request,
+// it writes the error response itself when they are invalid
+func GetPagination(c *gin.Context) (*pagination.Pagination, bool) {
+	pg, err := pagination.New(c.Query("limit"), c.Query("page"), c.Query("cursor"), c.Query("sort"))
+	if err != nil {
+		errList := map[string]string{}
+		errList["Invalid_pagination"] = err.Error()
+		handleError(c, http.StatusBadRequest, errList)
+		return nil, false
+	}
+	return pg, true
+}
+
+// GetThreadDepth reads how many levels of replies a comment thread request wants, it writes the error response
+// itself when the depth is invalid
+func GetThreadDepth(c *gin.Context) (int, bool) {
+	depth := models.DefaultCommentThreadDepth
+	if value := c.Query("depth"); value != "" {
+		var err error
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 0 || depth > models.MaxCommentDepth {
+			errList := map[string]string{}
+			errList["Invalid_depth"] = fmt.Sprintf("Depth should be a number from 0 to %d", models.MaxCommentDepth)
+			handleError(c, http.StatusBadRequest, errList)
+			return 0, false
+		}
+	}
+	return depth, true
+}
+
 func GetSocialLinksFromBody(requestBody map[string]string) *models.SocialLink {
 	socialLinksStr, ok := requestBody["social_links"]
 	if ok && socialLinksStr != "" {
@@ -59,6 +128,9 @@ func (server *Server) CommonCommentAndReplyesCode(c *gin.Context) (uint64, uint3
 		handleError(c, http.StatusUnauthorized, errList)
 		return 0, 0, nil, nil
 	}
+	if !server.checkPostVisible(c, &post) {
+		return 0, 0, nil, nil
+	}
 
 	// check if the auth token is valid and get the user id from it
 	userID, err := auth.ExtractTokenID(c.Request)
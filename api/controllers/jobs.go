@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/jobs"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+)
+
+// StartJobs starts the background jobs working on the database, they run until the process exits
+func (server *Server) StartJobs() {
+	jobs.Every(time.Minute, "publish scheduled posts", func() error {
+		_, err := models.PublishDuePosts(server.DB)
+		return err
+	})
+
+	jobs.Every(time.Hour, "delete expired revoked tokens", func() error {
+		revokedToken := models.RevokedToken{}
+		_, err := revokedToken.DeleteExpiredRevokedTokens(server.DB)
+		return err
+	})
+
+	jobs.Every(time.Hour, "collect released media", func() error {
+		_, failed, err := server.collectMedia(false)
+		if err == nil && len(failed) > 0 {
+			err = fmt.Errorf("%d released media could not be deleted from the storage", len(failed))
+		}
+		return err
+	})
+
+	jobs.Every(24*time.Hour, "sweep untracked media", func() error {
+		_, err := server.sweepMedia()
+		return err
+	})
+
+	jobs.Every(time.Hour, "purge the trash", func() error {
+		_, err := models.PurgeTrash(server.DB, time.Now().Add(-models.TrashRetention()))
+		return err
+	})
+}
@@ -1,8 +1,11 @@
 package controllers
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Mdromi/exp-blog-backend/api/auth"
 	"github.com/Mdromi/exp-blog-backend/api/models"
@@ -39,26 +42,28 @@ func (server *Server) LikePost(c *gin.Context) {
 
 	// check if the post exist
 	post := models.Post{}
-	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&post).Error
+	err = server.DB.Debug().Model(models.Post{}).Scopes(models.NotSuspended).Where("id = ?", pid).Take(&post).Error
 	if err != nil {
-		errList["Unauthorized"] = "Unauthorized"
-		handleError(c, http.StatusUnauthorized, errList)
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	if !server.checkPostVisible(c, &post) {
 		return
 	}
 
 	// Extrect Action
-	// GET /like/123?action=like
+	// POST /likes/123?action=like
 	action := c.Query("action")
-	if action == "" {
-		errList["Invalid_request"] = "Invalid Request"
-		handleError(c, http.StatusBadRequest, errList)
+	if !models.IsValidReaction(action) {
+		errList["Invalid_action"] = "Action should be like, dislike, hard or sad"
+		handleError(c, http.StatusUnprocessableEntity, errList)
 		return
 	}
 
 	like := models.LikeDislike{}
 	like.ProfileID = profile.ID
 	like.PostID = post.ID
-	// TASK: Need to chnage
 	like.Action = action
 
 	likeCreated, err := like.SaveLike(server.DB)
@@ -96,18 +101,27 @@ func (server *Server) GetLikes(c *gin.Context) {
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
+	if !server.checkPostVisible(c, &post) {
+		return
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
 
 	like := models.LikeDislike{}
 
-	likes, err := like.GetLikesInfo(server.DB, uint(pid))
+	likes, err := like.GetLikesInfo(server.DB, uint(pid), pg)
 	if err != nil {
 		errList["No_likes"] = "No Likes found"
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": likes,
+		"status":     http.StatusOK,
+		"response":   likes,
+		"pagination": pg,
 	})
 }
 
@@ -156,3 +170,143 @@ func (server *Server) UnLikePost(c *gin.Context) {
 		"response": "Like deleted",
 	})
 }
+
+// findReactedPost loads the post of the reactions route, it writes the error response itself when there is none or
+// the viewer cannot see it
+func (server *Server) findReactedPost(c *gin.Context) (*models.Post, bool) {
+	errList := map[string]string{}
+
+	pid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return nil, false
+	}
+
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Scopes(models.NotSuspended).Where("id = ?", pid).Take(&post).Error
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return nil, false
+	}
+	if !server.checkPostVisible(c, &post) {
+		return nil, false
+	}
+	return &post, true
+}
+
+// GET /posts/:id/reactions returns the counts of each reaction and the one of the reader when they are logged in
+func (server *Server) GetReactions(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, ok := server.findReactedPost(c)
+	if !ok {
+		return
+	}
+
+	summary, err := post.ReactionSummary(server.DB, GetViewerID(c))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": summary,
+	})
+}
+
+// PUT /posts/:id/reactions with {"action": "like"} sets the reaction of the reader, replacing the one they had
+func (server *Server) React(c *gin.Context) {
+	errList := map[string]string{}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	requestBody := map[string]string{}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	err = json.Unmarshal(body, &requestBody)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	action := strings.ToLower(strings.TrimSpace(requestBody["action"]))
+	if !models.IsValidReaction(action) {
+		errList["Invalid_action"] = "Action should be like, dislike, hard or sad"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	post, ok := server.findReactedPost(c)
+	if !ok {
+		return
+	}
+
+	reaction := models.LikeDislike{ProfileID: uint(profileID), PostID: post.ID, Action: action}
+	_, err = reaction.SaveLike(server.DB)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	server.respondReactions(c, post.ID, uint(profileID))
+}
+
+// DELETE /posts/:id/reactions removes the reaction of the reader
+func (server *Server) RemoveReaction(c *gin.Context) {
+	errList := map[string]string{}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	post, ok := server.findReactedPost(c)
+	if !ok {
+		return
+	}
+
+	_, err = models.RemoveReaction(server.DB, post.ID, uint(profileID))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	server.respondReactions(c, post.ID, uint(profileID))
+}
+
+// respondReactions answers with the reactions to the post once they are counted again
+func (server *Server) respondReactions(c *gin.Context, postID, profileID uint) {
+	errList := map[string]string{}
+
+	post := models.Post{}
+	err := server.DB.Debug().Model(models.Post{}).Where("id = ?", postID).Take(&post).Error
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	summary, err := post.ReactionSummary(server.DB, profileID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": summary,
+	})
+}
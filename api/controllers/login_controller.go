@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -14,6 +15,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// ErrAccountSuspended is returned by SignIn for a user suspended by an admin
+var ErrAccountSuspended = errors.New("account suspended")
+
 func (server *Server) Login(c *gin.Context) {
 	// clear previous error if any
 	errList = map[string]string{}
@@ -40,6 +44,10 @@ func (server *Server) Login(c *gin.Context) {
 	}
 
 	userData, err := server.SignIn(user.Email, user.Password)
+	if errors.Is(err, ErrAccountSuspended) {
+		handleError(c, http.StatusForbidden, map[string]string{"Account_suspended": "This account has been suspended"})
+		return
+	}
 	if err != nil {
 		formattedError := formaterror.FormatError(err.Error())
 		handleError(c, http.StatusUnprocessableEntity, formattedError)
@@ -69,18 +77,35 @@ func (server *Server) SignIn(email, password string) (map[string]interface{}, er
 		fmt.Println("this is the error hashing the password: ", err)
 		return nil, err
 	}
-	token, err := auth.CreateToken(uint32(user.ID))
+
+	if user.SuspendedAt != nil {
+		return nil, ErrAccountSuspended
+	}
+	token, err := auth.CreateToken(uint32(user.ID), user.Role)
 	if err != nil {
 		fmt.Println("this is the error creating the token: ", err)
 		return nil, err
 	}
 
+	familyID, err := auth.NewTokenFamily()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := server.issueRefreshToken(uint32(user.ID), familyID)
+	if err != nil {
+		return nil, err
+	}
+
 	userData["token"] = token
+	userData["refresh_token"] = refreshToken
+	userData["expires_in"] = int64(auth.AccessTokenTTL().Seconds())
 	userData["id"] = user.ID
 	userData["email"] = user.Email
 	userData["avatar_path"] = user.AvatarPath
 	userData["username"] = user.Username
 	userData["profileID"] = user.ProfileID
+	userData["role"] = user.Role
+	userData["permissions"] = user.Permissions()
 
 	return userData, nil
 }
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (server *Server) Logout(c *gin.Context) {
+	errList := map[string]string{}
+
+	metadata, err := auth.ExtractTokenMetadata(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	// the refresh token is optional, without it only the access token is revoked
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	requestBody := map[string]string{}
+	if len(body) > 0 {
+		err = json.Unmarshal(body, &requestBody)
+		if err != nil {
+			errList["Unmarshal_error"] = "Cannot unmarshal body"
+			handleError(c, http.StatusUnprocessableEntity, errList)
+			return
+		}
+	}
+
+	err = auth.Revocations.RevokeToken(metadata)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	if requestBody["refresh_token"] != "" {
+		refreshToken := models.RefreshToken{}
+		current, err := refreshToken.FindRefreshTokenByHash(server.DB, auth.HashRefreshToken(requestBody["refresh_token"]))
+		// a refresh token of another user is silently ignored
+		if err == nil && current.UserID == metadata.UserID {
+			_, err = refreshToken.RevokeRefreshTokenFamily(server.DB, current.FamilyID)
+			if err != nil {
+				errList["Other_error"] = "Please try again later"
+				handleError(c, http.StatusInternalServerError, errList)
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Logged out",
+	})
+}
+
+func (server *Server) LogoutAllSessions(c *gin.Context) {
+	errList := map[string]string{}
+
+	uid, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	err = auth.Revocations.RevokeAllSessions(uid)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	refreshToken := models.RefreshToken{}
+	_, err = refreshToken.RevokeUserRefreshTokens(server.DB, uid)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Logged out from all sessions",
+	})
+}
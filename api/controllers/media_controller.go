@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /media/*key?expires=&signature=, serves a private object through a url issued by storage.Signer
+func (server *Server) GetMedia(c *gin.Context) {
+	errList := map[string]string{}
+
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	expires := c.Query("expires")
+	err := storage.Signer.Verify(key, expires, c.Query("signature"))
+	if errors.Is(err, storage.ErrURLExpired) {
+		errList["Expired_url"] = "The url has expired"
+		handleError(c, http.StatusForbidden, errList)
+		return
+	}
+	if err != nil {
+		errList["Invalid_signature"] = "The url signature is invalid"
+		handleError(c, http.StatusForbidden, errList)
+		return
+	}
+
+	object, err := server.Storage.Open(key)
+	if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+		errList["No_media"] = "No Media Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	defer object.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	// the url stops working when it expires, so caches should not keep the object longer
+	expiresAt, _ := strconv.ParseInt(expires, 10, 64)
+	maxAge := expiresAt - time.Now().Unix()
+	c.DataFromReader(http.StatusOK, -1, contentType, object, map[string]string{
+		"Cache-Control": "private, max-age=" + strconv.FormatInt(maxAge, 10),
+	})
+}
+
+// mediaGCBatch is how many released files one run of the media garbage collection deletes at most
+const mediaGCBatch = 500
+
+// GET /admin/media/orphans, the released files the next media garbage collection would delete, nothing is removed
+func (server *Server) GetMediaOrphans(c *gin.Context) {
+	errList := map[string]string{}
+
+	objects, _, err := server.collectMedia(true)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	var size int64
+	for _, object := range *objects {
+		size += object.Size
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": objects,
+		"summary": gin.H{
+			"count":        len(*objects),
+			"size":         size,
+			"grace_period": models.MediaGracePeriod().String(),
+		},
+	})
+}
+
+// collectMedia deletes the files released for longer than the grace period from the storage and the media table.
+// With dryRun it only returns them. A file the storage cannot delete is skipped and returned with the failures, it
+// goes to the back of the queue so it does not hold the others up.
+func (server *Server) collectMedia(dryRun bool) (*[]models.MediaObject, []models.MediaObject, error) {
+	mediaObject := models.MediaObject{}
+	objects, err := mediaObject.FindReleasedMediaObjects(server.DB, time.Now().Add(-models.MediaGracePeriod()), mediaGCBatch)
+	if err != nil || dryRun {
+		return objects, nil, err
+	}
+
+	deleted := []models.MediaObject{}
+	failed := []models.MediaObject{}
+	for _, object := range *objects {
+		// the row goes only once the file is gone, so a failed delete is tried again after the grace period
+		if err := server.Storage.Delete(object.Key); err != nil {
+			log.Println("cannot delete the released media", object.Key, err)
+			failed = append(failed, object)
+			if _, err := object.PostponeMediaObject(server.DB); err != nil {
+				return &deleted, failed, err
+			}
+			continue
+		}
+		if _, err := object.DeleteMediaObject(server.DB); err != nil {
+			return &deleted, failed, err
+		}
+		deleted = append(deleted, object)
+	}
+	return &deleted, failed, nil
+}
+
+// sweepMedia registers the files of the storage the media table does not know about, the ones uploaded before the
+// files were tracked and the ones a failed upload left behind. A file a single record points to is tracked for that
+// record, a file no record points to is released so collectMedia deletes it after the grace period. A file several
+// records share, such as the default avatar, is left alone. It returns how many files were released.
+func (server *Server) sweepMedia() (int, error) {
+	keys, err := server.Storage.List("")
+	if err != nil {
+		return 0, err
+	}
+	mediaObject := models.MediaObject{}
+	trackedKeys, err := mediaObject.FindMediaObjectKeys(server.DB)
+	if err != nil {
+		return 0, err
+	}
+	tracked := map[string]bool{}
+	for _, key := range trackedKeys {
+		tracked[key] = true
+	}
+	references, err := models.FindMediaReferences(server.DB)
+	if err != nil {
+		return 0, err
+	}
+
+	// the references are grouped by file name so each key is only compared with the few that can point to it
+	byName := map[string][]models.MediaReference{}
+	for _, reference := range references {
+		reference.Path, _, _ = strings.Cut(reference.Path, "?")
+		reference.Path = strings.TrimPrefix(reference.Path, "/")
+		name := path.Base(reference.Path)
+		byName[name] = append(byName[name], reference)
+	}
+
+	released := 0
+	for _, key := range keys {
+		if tracked[key] || referencesKey(models.DefaultAvatar, key) {
+			continue
+		}
+		owners := []models.MediaReference{}
+		for _, reference := range byName[path.Base(key)] {
+			if referencesKey(reference.Path, key) {
+				owners = append(owners, reference)
+			}
+		}
+		if len(owners) > 1 {
+			continue
+		}
+
+		object := models.MediaObject{Key: key, ContentType: mime.TypeByExtension(path.Ext(key)), OwnerType: models.MediaOwnerNone}
+		if len(owners) == 1 {
+			object.OwnerType = owners[0].OwnerType
+			object.OwnerID = owners[0].OwnerID
+		} else {
+			now := time.Now()
+			object.ReleasedAt = &now
+		}
+		created, err := object.TrackMediaObject(server.DB)
+		if err != nil {
+			return released, err
+		}
+		if created > 0 && object.ReleasedAt != nil {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// referencesKey tells if the stored path points to the key. The records hold keys, urls ending with the key, or
+// for the oldest avatars only the name of the file under its folder.
+func referencesKey(reference string, key string) bool {
+	return reference == key || strings.HasSuffix(reference, "/"+key) || strings.HasSuffix(key, "/"+reference)
+}
@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /posts/:id/collaborators, shown to everyone working on the post
+func (server *Server) GetPostCollaborators(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, _, role, ok := server.findCollaboratedPost(c, errList)
+	if !ok {
+		return
+	}
+	if role == "" && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	collaborator := models.PostCollaborator{}
+	collaborators, err := collaborator.FindPostCollaborators(server.DB, uint64(post.ID))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": collaborators,
+	})
+}
+
+// POST /posts/:id/collaborators with {"profile_id": 3, "role": "editor"}, only the owners of the post can invite
+func (server *Server) InviteCollaborator(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, profileID, role, ok := server.findCollaboratedPost(c, errList)
+	if !ok {
+		return
+	}
+	if !models.CanManagePost(role) && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	requestBody := struct {
+		ProfileID uint   `json:"profile_id"`
+		Role      string `json:"role"`
+	}{}
+	err = json.Unmarshal(body, &requestBody)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	_, err = FindUserProfileByID(server.DB, uint32(requestBody.ProfileID))
+	if err != nil {
+		errList["Not_Found_profile"] = "Not Found the profile"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	collaborator := models.PostCollaborator{
+		ProfileID: requestBody.ProfileID,
+		Role:      strings.ToLower(strings.TrimSpace(requestBody.Role)),
+		InvitedBy: profileID,
+	}
+	invitation, err := collaborator.InviteCollaborator(server.DB, post)
+	switch {
+	case errors.Is(err, models.ErrInvalidCollaborator):
+		errList["Invalid_role"] = "Role should be owner, editor or viewer"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	case errors.Is(err, models.ErrCollaboratorAuthor):
+		errList["Invalid_profile"] = "The author of the post cannot be invited to it"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	case errors.Is(err, models.ErrCollaboratorExists):
+		errList["Already_invited"] = "This profile is already invited to the post"
+		handleError(c, http.StatusConflict, errList)
+		return
+	case err != nil:
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": invitation,
+	})
+}
+
+// DELETE /posts/:id/collaborators/:profile_id, the owners remove a collaborator, a collaborator can also leave
+func (server *Server) RemoveCollaborator(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, profileID, role, ok := server.findCollaboratedPost(c, errList)
+	if !ok {
+		return
+	}
+
+	collaboratorID, err := strconv.ParseUint(c.Param("profile_id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+	if uint(collaboratorID) != profileID && !models.CanManagePost(role) && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	collaborator := models.PostCollaborator{}
+	removed, err := collaborator.RemoveCollaborator(server.DB, uint64(post.ID), collaboratorID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	if removed == 0 {
+		errList["No_collaborator"] = "No Collaborator Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Collaborator removed",
+	})
+}
+
+// GET /invitations, the invitations of the authenticated user waiting for an answer
+func (server *Server) GetInvitations(c *gin.Context) {
+	errList := map[string]string{}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	collaborator := models.PostCollaborator{}
+	invitations, err := collaborator.FindProfileInvitations(server.DB, profileID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": invitations,
+	})
+}
+
+// POST /invitations/:id/accept
+func (server *Server) AcceptInvitation(c *gin.Context) {
+	errList := map[string]string{}
+
+	invitation, ok := server.findInvitation(c, errList)
+	if !ok {
+		return
+	}
+	if invitation.AcceptedAt != nil {
+		errList["Already_accepted"] = "The invitation is already accepted"
+		handleError(c, http.StatusConflict, errList)
+		return
+	}
+
+	accepted, err := invitation.AcceptInvitation(server.DB)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": accepted,
+	})
+}
+
+// DELETE /invitations/:id, declines the invitation
+func (server *Server) DeclineInvitation(c *gin.Context) {
+	errList := map[string]string{}
+
+	invitation, ok := server.findInvitation(c, errList)
+	if !ok {
+		return
+	}
+
+	_, err := invitation.RemoveCollaborator(server.DB, uint64(invitation.PostID), uint64(invitation.ProfileID))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": "Invitation declined",
+	})
+}
+
+// findCollaboratedPost loads the post of the request with the id and the role of the caller on it
+func (server *Server) findCollaboratedPost(c *gin.Context, errList map[string]string) (*models.Post, uint, string, bool) {
+	pid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return nil, 0, "", false
+	}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return nil, 0, "", false
+	}
+
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ?", pid).Take(&post).Error
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return nil, 0, "", false
+	}
+
+	role, err := post.RoleOf(server.DB, uint(profileID))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return nil, 0, "", false
+	}
+	return &post, uint(profileID), role, true
+}
+
+// findInvitation loads the invitation of the request, it has to be one of the caller
+func (server *Server) findInvitation(c *gin.Context, errList map[string]string) (*models.PostCollaborator, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return nil, false
+	}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return nil, false
+	}
+
+	invitation := models.PostCollaborator{}
+	_, err = invitation.FindInvitation(server.DB, id, profileID)
+	if err != nil {
+		errList["No_invitation"] = "No Invitation Found"
+		handleError(c, http.StatusNotFound, errList)
+		return nil, false
+	}
+	return &invitation, true
+}
@@ -0,0 +1,157 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/storage"
+	"github.com/Mdromi/exp-blog-backend/api/utils/imageformat"
+	"github.com/gin-gonic/gin"
+	"github.com/twinj/uuid"
+)
+
+// maxMediaSize is the largest image accepted by UploadPostMedia, 10 MB
+const maxMediaSize = 10 << 20
+
+// POST /posts/:id/media with a multipart "file" field. The image is stored in every size of imageformat.Variants
+// and as WebP, and becomes the thumbnail of the post when the "thumbnail" field is true.
+func (server *Server) UploadPostMedia(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, profileID, role, ok := server.findCollaboratedPost(c, errList)
+	if !ok {
+		return
+	}
+	if !models.CanEditPost(role) && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		errList["Invalid_file"] = "Invalid File"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	if file.Size > maxMediaSize {
+		errList["Too_large"] = "Sorry, Please upload an Image of 10MB or less"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	f, err := file.Open()
+	if err != nil {
+		errList["Invalid_file"] = "Invalid File"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxMediaSize+1))
+	if err != nil || len(data) > maxMediaSize {
+		errList["Invalid_file"] = "Invalid File"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	images, err := imageformat.Process(data)
+	switch {
+	case errors.Is(err, imageformat.ErrUnsupportedImage):
+		errList["Not_Image"] = "Please Upload a jpeg, png, gif or webp image"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	case errors.Is(err, imageformat.ErrImageTooLarge):
+		errList["Too_large"] = "Sorry, the image dimensions are too large"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	case err != nil:
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	contentType, _ := imageformat.DetectType(data)
+	config, _, _ := image.DecodeConfig(bytes.NewReader(data))
+
+	// all the versions of an upload share a folder named after it, they are private and
+	// only downloaded through signed urls so the images of a draft do not leak
+	dir := path.Join(storage.PrivatePrefix+"posts", strconv.Itoa(int(post.ID)), uuid.NewV4().String())
+	variants := []models.MediaVariant{}
+	for _, img := range images {
+		key := path.Join(dir, img.Name+img.Ext)
+		err = server.Storage.Put(key, img.Data, img.ContentType)
+		if err != nil {
+			server.releaseUpload(variants)
+			errList["Other_error"] = "Please try again later"
+			handleError(c, http.StatusInternalServerError, errList)
+			return
+		}
+		object := models.MediaObject{Key: key, ContentType: img.ContentType, Size: int64(len(img.Data)), OwnerType: models.MediaOwnerPost, OwnerID: post.ID}
+		_, err = object.SaveMediaObject(server.DB)
+		if err != nil {
+			// the file just stored has no row, the media sweep finds it
+			server.releaseUpload(variants)
+			errList["Other_error"] = "Please try again later"
+			handleError(c, http.StatusInternalServerError, errList)
+			return
+		}
+		variants = append(variants, models.MediaVariant{
+			Name:        img.Name,
+			Key:         key,
+			ContentType: img.ContentType,
+			Width:       img.Width,
+			Height:      img.Height,
+			Size:        len(img.Data),
+		})
+	}
+
+	media := models.PostMedia{
+		PostID:       post.ID,
+		ProfileID:    profileID,
+		OriginalName: filepath.Base(file.Filename),
+		ContentType:  contentType,
+		Width:        config.Width,
+		Height:       config.Height,
+		Variants:     variants,
+	}
+	mediaCreated, err := media.SavePostMedia(server.DB)
+	if err != nil {
+		server.releaseUpload(variants)
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	if c.PostForm("thumbnail") == "true" {
+		err = post.SetThumbnail(server.DB, mediaCreated.Variant("medium").Key)
+		if err != nil {
+			errList["Other_error"] = "Please try again later"
+			handleError(c, http.StatusInternalServerError, errList)
+			return
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": mediaCreated,
+	})
+}
+
+// releaseUpload releases the versions a failed upload already stored, the media garbage collection deletes them.
+// The request failed anyway so a failure is only logged, the files are then released with the post.
+func (server *Server) releaseUpload(variants []models.MediaVariant) {
+	keys := []string{}
+	for _, variant := range variants {
+		keys = append(keys, variant.Key)
+	}
+	object := models.MediaObject{}
+	if _, err := object.ReleaseMediaObjectKeys(server.DB, keys); err != nil {
+		log.Println("cannot release the media of the failed upload", keys, err)
+	}
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /moderation/posts lists the posts the filters held in review
+func (server *Server) GetPostsInReview(c *gin.Context) {
+	errList := map[string]string{}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	posts, err := models.FindPostsInReview(server.DB, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   posts,
+		"pagination": pg,
+	})
+}
+
+// PUT /moderation/posts/:id/approve publishes the post held in review
+func (server *Server) ApprovePost(c *gin.Context) {
+	server.endPostReview(c, true)
+}
+
+// PUT /moderation/posts/:id/reject sends the post held in review back to its author as a draft
+func (server *Server) RejectPost(c *gin.Context) {
+	server.endPostReview(c, false)
+}
+
+func (server *Server) endPostReview(c *gin.Context, approve bool) {
+	errList := map[string]string{}
+
+	pid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	post := models.Post{}
+	err = server.DB.Debug().Model(models.Post{}).Where("id = ? AND status = ?", pid, models.PostStatusReview).Take(&post).Error
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	postReviewed, err := post.EndReview(server.DB, approve)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": postReviewed,
+	})
+}
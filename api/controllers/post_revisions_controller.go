@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/formaterror"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /posts/:id/revisions, the history is only shown to the profiles that can edit the post
+func (server *Server) GetPostRevisions(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, _, ok := server.findRevisedPost(c, errList)
+	if !ok {
+		return
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	revision := models.PostRevision{}
+	revisions, err := revision.FindPostRevisions(server.DB, uint64(post.ID), pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   revisions,
+		"pagination": pg,
+	})
+}
+
+// GET /posts/:id/revisions/diff?from=1&to=3, the unified diff going from one revision to the other
+func (server *Server) GetPostRevisionsDiff(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, _, ok := server.findRevisedPost(c, errList)
+	if !ok {
+		return
+	}
+
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		errList["Invalid_revision"] = "from and to should be revision numbers"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	fromRevision := models.PostRevision{}
+	_, err := fromRevision.FindPostRevision(server.DB, uint64(post.ID), from)
+	if err != nil {
+		errList["No_revision"] = "No Revision Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	toRevision := models.PostRevision{}
+	_, err = toRevision.FindPostRevision(server.DB, uint64(post.ID), to)
+	if err != nil {
+		errList["No_revision"] = "No Revision Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"response": gin.H{
+			"from": fromRevision.Revision,
+			"to":   toRevision.Revision,
+			"diff": fromRevision.Diff(&toRevision),
+		},
+	})
+}
+
+// POST /posts/:id/revisions/:rev/restore, the post takes back the title, content and tags of the revision.
+// Restoring is an update like any other, so it is recorded as a new revision and can be undone too.
+func (server *Server) RestorePostRevision(c *gin.Context) {
+	errList := map[string]string{}
+
+	post, userID, ok := server.findRevisedPost(c, errList)
+	if !ok {
+		return
+	}
+
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	revision := models.PostRevision{}
+	_, err = revision.FindPostRevision(server.DB, uint64(post.ID), rev)
+	if err != nil {
+		errList["No_revision"] = "No Revision Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.Tags = revision.Tags
+	post.EditedBy = userID
+
+	postUpdated, err := post.UpdateAPost(server.DB)
+	if err != nil {
+		errList := formaterror.FormatError(err.Error())
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": postUpdated,
+	})
+}
+
+// findRevisedPost loads the post of the request for the profiles that can edit it, along with the id of the caller
+func (server *Server) findRevisedPost(c *gin.Context, errList map[string]string) (*models.Post, uint, bool) {
+	pid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return nil, 0, false
+	}
+
+	userID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return nil, 0, false
+	}
+
+	post := models.Post{}
+	_, err = post.FindPostById(server.DB, pid)
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return nil, 0, false
+	}
+
+	role, err := post.RoleOf(server.DB, uint(userID))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return nil, 0, false
+	}
+	if !models.CanEditPost(role) && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return nil, 0, false
+	}
+	return &post, uint(userID), true
+}
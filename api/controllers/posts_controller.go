@@ -10,9 +10,7 @@ import (
 	"github.com/Mdromi/exp-blog-backend/api/auth"
 	"github.com/Mdromi/exp-blog-backend/api/models"
 	"github.com/Mdromi/exp-blog-backend/api/utils/formaterror"
-	"github.com/Mdromi/exp-blog-backend/api/utils/postformator"
 	"github.com/gin-gonic/gin"
-	"github.com/lib/pq"
 )
 
 func (server *Server) CreatePost(c *gin.Context) {
@@ -51,6 +49,13 @@ func (server *Server) CreatePost(c *gin.Context) {
 	}
 
 	post.AuthorID = uint(pid) // the authenticated user is the one creating the post
+	post.EditedBy = uint(pid)
+
+	if !models.IsRequestablePostStatus(post.Status) {
+		errList["Invalid_status"] = "Status should be draft, scheduled, published or archived"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
 
 	post.Prepare()
 	errorMessages := post.Validate()
@@ -60,19 +65,13 @@ func (server *Server) CreatePost(c *gin.Context) {
 		return
 	}
 
-	if len(post.Tags) == 0 {
+	if len(models.NormalizeTags(post.Tags)) == 0 {
 		errList["Invalid_tags"] = "Invalid Tagas"
 		handleError(c, http.StatusBadRequest, errList)
 		return
 	}
 	// result := postformator.ConvertTags(post.Tags)
 
-	post.PostPermalinks = postformator.CreatePostPermalinks(post.Title)
-	post.ReadTime = postformator.CalculateReadingTime(post.Content)
-
-	// Convert the array of strings to a pq.StringArray
-	post.Tags = pq.StringArray(post.Tags)
-
 	postCreated, err := post.SavePost(server.DB)
 	if err != nil {
 		errList := formaterror.FormatError(err.Error())
@@ -86,17 +85,23 @@ func (server *Server) CreatePost(c *gin.Context) {
 }
 
 func (server *Server) GetPosts(c *gin.Context) {
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
 	post := models.Post{}
 
-	posts, err := post.FindAllPosts(server.DB)
+	posts, err := post.FindAllPosts(server.DB, GetViewerID(c), pg)
 	if err != nil {
 		errList["No_post"] = "No Post Found"
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": posts,
+		"status":     http.StatusOK,
+		"response":   posts,
+		"pagination": pg,
 	})
 }
 
@@ -109,6 +114,7 @@ func (server *Server) GetPost(c *gin.Context) {
 		return
 	}
 
+	viewerID := GetViewerID(c)
 	post := models.Post{}
 	postReceived, err := post.FindPostById(server.DB, pid)
 	if err != nil {
@@ -116,13 +122,77 @@ func (server *Server) GetPost(c *gin.Context) {
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
+	visible, err := postReceived.VisibleTo(server.DB, viewerID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	if !visible {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	// the previous and next parts when the post is part of a series
+	navigation, err := models.FindSeriesNavigation(server.DB, postReceived.ID, viewerID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":   http.StatusOK,
 		"response": postReceived,
+		"series":   navigation,
 	})
 }
 
+// GET /posts/slug/:permalink, an old permalink of a renamed post answers with the post and where it lives now
+func (server *Server) GetPostBySlug(c *gin.Context) {
+	errList := map[string]string{}
+
+	permalink := c.Param("permalink")
+
+	viewerID := GetViewerID(c)
+	post := models.Post{}
+	postReceived, err := post.FindPostBySlug(server.DB, permalink)
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	visible, err := postReceived.VisibleTo(server.DB, viewerID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	if !visible {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	navigation, err := models.FindSeriesNavigation(server.DB, postReceived.ID, viewerID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	response := gin.H{
+		"status":   http.StatusOK,
+		"response": postReceived,
+		"series":   navigation,
+	}
+	if postReceived.PostPermalinks != permalink {
+		response["redirect_to"] = "/api/v1/posts/slug/" + postReceived.PostPermalinks
+	}
+	c.JSON(http.StatusOK, response)
+}
+
 func (server *Server) UpdatePost(c *gin.Context) {
 	// clear previous error if any
 	errList = map[string]string{}
@@ -144,14 +214,20 @@ func (server *Server) UpdatePost(c *gin.Context) {
 		return
 	}
 
+	// editors can update any post, so they do not need a profile of their own
+	canModerate := HasPermission(c, models.PermissionModeratePosts)
+
 	// find the Author profile
+	var profileID uint
 	profile, err := FindUserProfileByID(server.DB, userID)
-	if err != nil {
+	if err != nil && !canModerate {
 		errList["Not_Found_profile"] = "Not Found the profile"
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
-	profileID := profile.ID
+	if err == nil {
+		profileID = profile.ID
+	}
 
 	//Check if the post exist
 	origPost := models.Post{}
@@ -163,7 +239,14 @@ func (server *Server) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	if profileID != origPost.AuthorID {
+	// the owners and editors of the post can update it
+	role, err := origPost.RoleOf(server.DB, profileID)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	if !models.CanEditPost(role) && !canModerate {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
@@ -188,6 +271,28 @@ func (server *Server) UpdatePost(c *gin.Context) {
 
 	post.ID = origPost.ID // this is important to tell the model the post id to update, the other field are set above
 	post.AuthorID = origPost.AuthorID
+	post.EditedBy = uint(userID) // an editor may be the one changing it, the revision keeps who did
+
+	if !models.IsRequestablePostStatus(post.Status) {
+		errList["Invalid_status"] = "Status should be draft, scheduled, published or archived"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	// editing a post does not change its state unless asked to
+	if post.Status == "" {
+		post.Status = origPost.Status
+		if post.PublishAt == nil {
+			post.PublishAt = origPost.PublishAt
+		}
+	}
+
+	// a post sent without moderate_comments keeps its setting, a null one follows its author again
+	fields := map[string]json.RawMessage{}
+	_ = json.Unmarshal(body, &fields)
+	if _, sent := fields["moderate_comments"]; !sent {
+		post.ModerateComments = origPost.ModerateComments
+	}
 
 	post.Prepare()
 	errorMessages := post.Validate()
@@ -197,9 +302,6 @@ func (server *Server) UpdatePost(c *gin.Context) {
 		return
 	}
 
-	post.PostPermalinks = postformator.CreatePostPermalinks(post.Title)
-	post.ReadTime = postformator.CalculateReadingTime(post.Content)
-
 	postUpdated, err := post.UpdateAPost(server.DB)
 	if err != nil {
 		errList := formaterror.FormatError(err.Error())
@@ -241,33 +343,22 @@ func (server *Server) DeletePost(c *gin.Context) {
 		return
 	}
 
-	// Is the authenticated user, the owner of this post?
-	if profileID != uint32(post.AuthorID) {
-		errList["Unauthorized"] = "Unauthorized"
-		handleError(c, http.StatusUnauthorized, errList)
-		return
-	}
-
-	// if all the conditions are metn delete the post
-	_, err = post.DeleteAPost(server.DB)
+	// Is the authenticated user one of the owners of this post or an editor?
+	role, err := post.RoleOf(server.DB, uint(profileID))
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
-
-	commnnt := models.Comment{}
-	likeDislike := models.LikeDislike{}
-
-	// also delete the likes and the comments that thi post have:
-	_, err = commnnt.DeletePostComments(server.DB, pid)
-	if err != nil {
-		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusInternalServerError, errList)
+	if !models.CanManagePost(role) && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
 
-	_, err = likeDislike.DeletePostLikes(server.DB, pid)
+	// if all the conditions are metn delete the post, with the comments, replies and likes that this post have
+	post.DeletedBy = profileID
+	err = post.DeletePostCascade(server.DB)
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		handleError(c, http.StatusInternalServerError, errList)
@@ -290,8 +381,13 @@ func (server *Server) GetUserProfilePosts(c *gin.Context) {
 		return
 	}
 
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
 	post := models.Post{}
-	posts, err := post.FindUserPosts(server.DB, uint32(pid))
+	posts, err := post.FindUserPosts(server.DB, uint32(pid), GetViewerID(c), pg)
 
 	if err != nil {
 		errList["No_post"] = "No Post Found"
@@ -299,7 +395,8 @@ func (server *Server) GetUserProfilePosts(c *gin.Context) {
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": posts,
+		"status":     http.StatusOK,
+		"response":   posts,
+		"pagination": pg,
 	})
 }
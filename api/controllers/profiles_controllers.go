@@ -4,16 +4,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 
 	"github.com/Mdromi/exp-blog-backend/api/auth"
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/fileformat"
 	"github.com/Mdromi/exp-blog-backend/api/utils/formaterror"
 	"github.com/gin-gonic/gin"
 
@@ -147,8 +148,13 @@ func (server *Server) GetUserProfiles(c *gin.Context) {
 	// clear previous error if any
 	errList = map[string]string{}
 
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
 	profile := models.Profile{}
-	profiles, err := profile.FindAllUsersProfile(server.DB)
+	profiles, err := profile.FindAllUsersProfile(server.DB, pg)
 	if err != nil {
 		errList["No_profile"] = "No Profile Found"
 		handleError(c, http.StatusInternalServerError, errList)
@@ -156,8 +162,9 @@ func (server *Server) GetUserProfiles(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": profiles,
+		"status":     http.StatusOK,
+		"response":   profiles,
+		"pagination": pg,
 	})
 }
 
@@ -223,14 +230,18 @@ func (server *Server) UpdateUserProfileImage(c *gin.Context) {
 	}
 
 	// if the id is not the authenticated user id
-	if tokenID != 0 && tokenID != uint32(pid) {
+	if tokenID != 0 && tokenID != uint32(pid) && !HasPermission(c, models.PermissionManageUsers) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
 
 	// Upload profile or cover pic based on the image type
-	filePath, err := server.uploadFile(c, uint32(pid), "profilePic", nil)
+	fieldname := "profilePic"
+	if imageType == "cover_pic" {
+		fieldname = "coverPic"
+	}
+	filePath, err := server.uploadFile(c, uint32(pid), fieldname, nil)
 	if err != nil {
 		errList["Cannot_Save_Image"] = err.Error()
 		handleError(c, http.StatusInternalServerError, errList)
@@ -256,6 +267,7 @@ func (server *Server) UpdateUserProfileImage(c *gin.Context) {
 		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
+	server.releaseReplacedMedia(uint32(pid), profileMediaOwners[fieldname])
 	c.JSON(http.StatusOK, gin.H{
 		"status":   http.StatusOK,
 		"response": updatedProfile,
@@ -309,7 +321,7 @@ func (server *Server) UpdateAUserProfile(c *gin.Context) {
 	}
 
 	// if the id is not the authentication user id
-	if tokenID != 0 && tokenID != uint32(pid) {
+	if tokenID != 0 && tokenID != uint32(pid) && !HasPermission(c, models.PermissionManageUsers) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
@@ -351,8 +363,11 @@ func (server *Server) UpdateAUserProfile(c *gin.Context) {
 		return
 	}
 
-	// Upload profile pic
+	// Upload profile pic, the json body keeps its profile_pic when no file is sent
 	profilePicPath, err := server.uploadFile(c, uint32(pid), "profilePic", nil)
+	if errors.Is(err, http.ErrMissingFile) || errors.Is(err, http.ErrNotMultipart) {
+		profilePicPath, err = newProfile.ProfilePic, nil
+	}
 	if err != nil {
 		errList["Cannot_Save_Profile_Pic"] = err.Error()
 		handleError(c, http.StatusInternalServerError, errList)
@@ -378,6 +393,7 @@ func (server *Server) UpdateAUserProfile(c *gin.Context) {
 		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
+	server.releaseReplacedMedia(uint32(pid), models.MediaOwnerProfilePic)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":   http.StatusOK,
@@ -416,51 +432,19 @@ func (server *Server) DeleteUserProfile(c *gin.Context) {
 	}
 
 	// If the id is not the authenticated user id
-	if tokenID != 0 && tokenID != uint32(profile.UserID) {
+	if tokenID != 0 && tokenID != uint32(profile.UserID) && !HasPermission(c, models.PermissionManageUsers) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
 
-	// Also delete the posts, likes and the comments that this user created if any:
-
-	comment := models.Comment{}
-	likeDislike := models.LikeDislike{}
-	post := models.Post{}
-
-	_, err = post.DeleteUserPosts(server.DB, uint32(pid))
-	if err != nil {
-		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusInternalServerError, errList)
-		return
-	}
-	_, err = comment.DeleteUserComments(server.DB, uint32(pid))
-	if err != nil {
-		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusInternalServerError, errList)
-		return
-	}
-	_, err = likeDislike.DeleteUserLikes(server.DB, uint32(pid))
-	if err != nil {
-		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusInternalServerError, errList)
-		return
-	}
-
-	// Delete user profile uploads directory and its contents
-	uploadsDir := "static/uploads/" + strconv.Itoa(int(pid))
-	err = os.RemoveAll(uploadsDir)
-	if err != nil {
-		errList["Other_error"] = "Error deleting user uploads directory"
-		handleError(c, http.StatusInternalServerError, errList)
-		return
-	}
-
+	// Also delete the posts, likes, comments and replies that this user created if any. The pictures of the
+	// profile are deleted by the media garbage collection after the grace period
 	deletedProfile := models.Profile{}
-	_, err = deletedProfile.DeleteAUserProfile(server.DB, uint32(pid))
+	err = deletedProfile.DeleteProfileCascade(server.DB, uint32(pid))
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusNotFound, errList)
+		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
 
@@ -470,26 +454,52 @@ func (server *Server) DeleteUserProfile(c *gin.Context) {
 	})
 }
 
-func (server *Server) uploadFile(c *gin.Context, userID uint32, fieldname string, file *multipart.FileHeader) (string, error) {
-	// Base directory without the user-specific folder
-	baseDir := filepath.Join("static", "uploads", fieldname)
+// uploadFile stores the file in the folder of the profile and returns its url. Without a file,
+// the one sent in the form field is used.
+func (server *Server) uploadFile(c *gin.Context, profileID uint32, fieldname string, file *multipart.FileHeader) (string, error) {
+	if file == nil {
+		var err error
+		file, err = c.FormFile(fieldname)
+		if err != nil {
+			return "", err
+		}
+	}
 
-	// Ensure the base directory exists
-	if err := os.MkdirAll(baseDir, os.ModePerm); err != nil {
-		return "", errors.New("could not create upload directory")
+	f, err := file.Open()
+	if err != nil {
+		return "", errors.New("could not read the file")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return "", errors.New("could not read the file")
 	}
 
-	// Create user-specific directory within the base directory
-	userDir := filepath.Join(baseDir, strconv.Itoa(int(userID)))
-	if err := os.MkdirAll(userDir, os.ModePerm); err != nil {
-		return "", errors.New("could not create user-specific upload directory")
+	key := path.Join("profiles", strconv.Itoa(int(profileID)), fieldname, fileformat.UniqueFormat(file.Filename))
+	contentType := http.DetectContentType(data)
+	if err := server.Storage.Put(key, data, contentType); err != nil {
+		return "", errors.New("could not save file on server")
 	}
 
-	// Save file to a new file in the user-specific directory
-	filePath := filepath.Join(userDir, file.Filename)
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	// the file is tracked so it is collected once a newer one replaces it
+	object := models.MediaObject{Key: key, ContentType: contentType, Size: int64(len(data)), OwnerType: profileMediaOwners[fieldname], OwnerID: uint(profileID)}
+	if _, err := object.SaveMediaObject(server.DB); err != nil {
 		return "", errors.New("could not save file on server")
 	}
+	return server.Storage.URL(key), nil
+}
 
-	return filePath, nil
+// profileMediaOwners maps the upload fields of a profile to the use of their files
+var profileMediaOwners = map[string]string{
+	"profilePic": models.MediaOwnerProfilePic,
+	"coverPic":   models.MediaOwnerCoverPic,
+}
+
+// releaseReplacedMedia releases the files the latest upload of the owner replaced, the request
+// already succeeded so a failure is only logged and the files are kept
+func (server *Server) releaseReplacedMedia(ownerID uint32, ownerType string) {
+	object := models.MediaObject{}
+	if _, err := object.ReleaseReplacedMediaObjects(server.DB, uint(ownerID), ownerType); err != nil {
+		log.Println("cannot release the replaced media:", err)
+	}
 }
@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reportResources maps the reported targets to the admin resources they are deleted with
+var reportResources = map[string]string{
+	models.ReportTargetPost:    "posts",
+	models.ReportTargetComment: "comments",
+	models.ReportTargetProfile: "profiles",
+}
+
+// POST /reports with {"target_type": "comment", "target_id": 3, "reason": "spam", "details": ""}
+func (server *Server) CreateReport(c *gin.Context) {
+	errList := map[string]string{}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	report := models.Report{}
+	err = json.Unmarshal(body, &report)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	report.ReporterID = profileID
+
+	report.Prepare()
+	errorMessages := report.Validate()
+	if len(errorMessages) > 0 {
+		handleError(c, http.StatusUnprocessableEntity, errorMessages)
+		return
+	}
+
+	// only what the readers can see can be reported
+	target := models.ReportTargetModel(report.TargetType)
+	err = server.DB.Debug().Model(target).Scopes(models.NotSuspended).Where("id = ?", report.TargetID).Take(target).Error
+	if err != nil {
+		errList["No_record"] = "No Record Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	reportCreated, _, err := report.SaveReport(server.DB)
+	if errors.Is(err, models.ErrAlreadyReported) {
+		errList["Already_reported"] = err.Error()
+		handleError(c, http.StatusConflict, errList)
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": reportCreated,
+	})
+}
+
+// GET /admin/reports?status=open&target_type=comment&target_id=3&reason=spam
+func (server *Server) AdminListReports(c *gin.Context) {
+	errList := map[string]string{}
+
+	filter := models.ReportFilter{
+		Status:     c.Query("status"),
+		TargetType: c.Query("target_type"),
+		Reason:     c.Query("reason"),
+	}
+	if targetID := c.Query("target_id"); targetID != "" {
+		tid, err := strconv.ParseUint(targetID, 10, 64)
+		if err != nil {
+			errList["Invalid_request"] = "Invalid Request"
+			handleError(c, http.StatusBadRequest, errList)
+			return
+		}
+		filter.TargetID = tid
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	report := models.Report{}
+	reports, err := report.FindReports(server.DB, filter, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   reports,
+		"pagination": pg,
+	})
+}
+
+// PUT /admin/reports/:id/resolve with {"action": "hide", "reason": "..."} closes every open report of the target
+// of the report. The action is dismiss, hide or delete, and it is written to the audit log.
+func (server *Server) AdminResolveReport(c *gin.Context) {
+	errList := map[string]string{}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	actorID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	requestBody := map[string]string{}
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err == nil && len(body) > 0 {
+		if err := json.Unmarshal(body, &requestBody); err != nil {
+			errList["Unmarshal_error"] = "Cannot unmarshal body"
+			handleError(c, http.StatusUnprocessableEntity, errList)
+			return
+		}
+	}
+	action := requestBody["action"]
+	switch action {
+	case models.ReportActionDismiss, models.ReportActionHide, models.ReportActionDelete:
+	default:
+		errList["Invalid_action"] = "Action should be dismiss, hide or delete"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	report := models.Report{}
+	err = server.DB.Debug().Model(models.Report{}).Where("id = ?", id).Take(&report).Error
+	if err != nil {
+		errList["No_record"] = "No Record Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	// the resolution and its audit log are written together, or neither is
+	auditLog := models.AuditLog{
+		ActorID:    actorID,
+		Action:     models.AuditActionResolve,
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		Details:    action + ": " + requestBody["reason"],
+	}
+	err = server.DB.Transaction(func(tx *gorm.DB) error {
+		if action == models.ReportActionDelete {
+			err := adminResources[reportResources[report.TargetType]].hardDelete(tx, report.TargetID)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := report.ResolveReports(tx, action, actorID)
+		if err != nil {
+			return err
+		}
+		_, err = auditLog.SaveAuditLog(tx)
+		return err
+	})
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": auditLog,
+	})
+}
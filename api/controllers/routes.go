@@ -3,6 +3,7 @@ package controllers
 import (
 	"github.com/Mdromi/exp-blog-backend/api/middlewares"
 	_ "github.com/Mdromi/exp-blog-backend/api/middlewares"
+	"github.com/Mdromi/exp-blog-backend/api/models"
 )
 
 func (s *Server) initializeRoutes() {
@@ -12,6 +13,9 @@ func (s *Server) initializeRoutes() {
 	{
 		// Login Route
 		v1.POST("/login", s.Login)
+		v1.POST("/token/refresh", s.RefreshToken)
+		v1.POST("/logout", middlewares.TokenAuthMiddleware(), s.Logout)
+		v1.POST("/logout/all", middlewares.TokenAuthMiddleware(), s.LogoutAllSessions)
 
 		// Reset Password
 		v1.POST("/password/forgot", s.ForgotPassword)
@@ -24,6 +28,7 @@ func (s *Server) initializeRoutes() {
 		v1.PUT("/users/:id", middlewares.TokenAuthMiddleware(), s.UpdateUser)
 		v1.PUT("/avatar/users/:id", middlewares.TokenAuthMiddleware(), s.UpdateAvatar)
 		v1.DELETE("/users/:id", middlewares.TokenAuthMiddleware(), s.DeleteUser)
+		v1.PUT("/users/:id/role", middlewares.TokenAuthMiddleware(), middlewares.RequireRole(models.RoleAdmin), s.UpdateUserRole)
 
 		// Profiles routes
 		v1.POST("/profiles", middlewares.TokenAuthMiddleware(), s.CreateUserProfile)
@@ -34,28 +39,99 @@ func (s *Server) initializeRoutes() {
 		v1.DELETE("/profiles/:id", middlewares.TokenAuthMiddleware(), s.DeleteUserProfile)
 
 		// Posts routes
-		v1.POST("/posts", middlewares.TokenAuthMiddleware(), s.CreatePost)
+		v1.POST("/posts", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionCreatePost), s.CreatePost)
 		v1.GET("/posts", s.GetPosts)
 		v1.GET("/posts/:id", s.GetPost)
+		v1.GET("/posts/slug/:permalink", s.GetPostBySlug)
 		v1.PUT("/posts/:id", middlewares.TokenAuthMiddleware(), s.UpdatePost)
 		v1.DELETE("/posts/:id", middlewares.TokenAuthMiddleware(), s.DeletePost)
 		v1.GET("/user_posts/:id", s.GetUserProfilePosts)
 
+		// Post revisions routes
+		v1.GET("/posts/:id/revisions", middlewares.TokenAuthMiddleware(), s.GetPostRevisions)
+		v1.GET("/posts/:id/revisions/diff", middlewares.TokenAuthMiddleware(), s.GetPostRevisionsDiff)
+		v1.POST("/posts/:id/revisions/:rev/restore", middlewares.TokenAuthMiddleware(), s.RestorePostRevision)
+
+		// Post collaborators routes
+		v1.GET("/posts/:id/collaborators", middlewares.TokenAuthMiddleware(), s.GetPostCollaborators)
+		v1.POST("/posts/:id/collaborators", middlewares.TokenAuthMiddleware(), s.InviteCollaborator)
+		v1.DELETE("/posts/:id/collaborators/:profile_id", middlewares.TokenAuthMiddleware(), s.RemoveCollaborator)
+		v1.GET("/invitations", middlewares.TokenAuthMiddleware(), s.GetInvitations)
+		v1.POST("/invitations/:id/accept", middlewares.TokenAuthMiddleware(), s.AcceptInvitation)
+		v1.DELETE("/invitations/:id", middlewares.TokenAuthMiddleware(), s.DeclineInvitation)
+
+		// Post media routes
+		v1.POST("/posts/:id/media", middlewares.TokenAuthMiddleware(), s.UploadPostMedia)
+		v1.GET("/media/*key", s.GetMedia)
+
+		// Series routes
+		v1.POST("/series", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionCreatePost), s.CreateSeries)
+		v1.GET("/series", s.GetSeriesList)
+		v1.GET("/series/:id", s.GetSeries)
+		v1.PUT("/series/:id/posts", middlewares.TokenAuthMiddleware(), s.ReorderSeries)
+
+		// Tag routes
+		v1.GET("/tags", s.GetTags)
+		v1.GET("/tags/:slug/posts", s.GetTagPosts)
+		v1.PUT("/tags/:slug", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionManageTags), s.RenameTag)
+		v1.POST("/tags/:slug/merge", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionManageTags), s.MergeTag)
+
+		// Search routes
+		v1.GET("/search/posts", s.SearchPosts)
+
 		// Like Routes
 		v1.GET("/likes/:id", s.GetLikes)
 		v1.POST("/likes/:id", middlewares.TokenAuthMiddleware(), s.LikePost)
 		v1.DELETE("/likes/:id", middlewares.TokenAuthMiddleware(), s.UnLikePost)
 
+		// Reaction routes, a reader has one reaction per post
+		v1.GET("/posts/:id/reactions", s.GetReactions)
+		v1.PUT("/posts/:id/reactions", middlewares.TokenAuthMiddleware(), s.React)
+		v1.DELETE("/posts/:id/reactions", middlewares.TokenAuthMiddleware(), s.RemoveReaction)
+
 		// Comment routes
-		v1.POST("/comments/:id", middlewares.TokenAuthMiddleware(), s.CreateComment)
+		v1.POST("/comments/:id", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionCreateComment), s.CreateComment)
 		v1.GET("/comments/:id", s.GetComments)
 		v1.PUT("/comments/:id/", middlewares.TokenAuthMiddleware(), s.UpdateComment)
 		v1.DELETE("/comments/:id", middlewares.TokenAuthMiddleware(), s.DeleteComment)
 
-		// Comment Replyes routes
-		v1.POST("/comment/replyes/:id", middlewares.TokenAuthMiddleware(), s.CreateCommentReplye)
+		// Comment Replyes routes, the replies are comments with the comment they reply to as their parent
+		v1.POST("/comment/replyes/:id", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionCreateComment), s.CreateCommentReplye)
 		v1.GET("/comments/replyes/:id", s.GetCommentReplyes)
 		v1.PUT("/comments/replyes/:id/", middlewares.TokenAuthMiddleware(), s.UpdateACommentReplyes)
 		v1.DELETE("/comments/replyes/:id", middlewares.TokenAuthMiddleware(), s.DeleteCommentReplye)
+
+		// Comment moderation routes, for the owners of the posts commented on
+		v1.GET("/moderation/comments", middlewares.TokenAuthMiddleware(), s.GetModerationQueue)
+		v1.PUT("/moderation/comments/:id/approve", middlewares.TokenAuthMiddleware(), s.ApproveComment)
+		v1.PUT("/moderation/comments/:id/reject", middlewares.TokenAuthMiddleware(), s.RejectComment)
+		v1.DELETE("/moderation/comments/:id", middlewares.TokenAuthMiddleware(), s.DeleteModeratedComment)
+
+		// Post review routes, for the editors looking at the posts the filters held
+		v1.GET("/moderation/posts", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionModeratePosts), s.GetPostsInReview)
+		v1.PUT("/moderation/posts/:id/approve", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionModeratePosts), s.ApprovePost)
+		v1.PUT("/moderation/posts/:id/reject", middlewares.TokenAuthMiddleware(), middlewares.RequirePermission(models.PermissionModeratePosts), s.RejectPost)
+
+		// Report routes, a reply is reported as a comment
+		v1.POST("/reports", middlewares.TokenAuthMiddleware(), s.CreateReport)
+
+		// Trash routes, :resource is one of posts or comments
+		v1.GET("/trash/:resource", middlewares.TokenAuthMiddleware(), s.GetTrash)
+		v1.PUT("/trash/:resource/:id/restore", middlewares.TokenAuthMiddleware(), s.RestoreFromTrash)
+
+		// Admin routes, :resource is one of users, profiles, posts or comments
+		admin := v1.Group("/admin", middlewares.TokenAuthMiddleware(), middlewares.RequireRole(models.RoleAdmin))
+		{
+			admin.GET("/audit-logs", s.GetAuditLogs)
+			admin.GET("/media/orphans", s.GetMediaOrphans)
+			admin.GET("/reports", s.AdminListReports)
+			admin.PUT("/reports/:id/resolve", s.AdminResolveReport)
+			admin.GET("/:resource", s.AdminList)
+			admin.PUT("/:resource/:id/suspend", s.AdminSuspend)
+			admin.PUT("/:resource/:id/restore", s.AdminRestore)
+			admin.DELETE("/:resource/:id", s.AdminHardDelete)
+			admin.PUT("/:resource/:id/spam", s.AdminLabelSpam)
+			admin.PUT("/:resource/:id/ham", s.AdminLabelHam)
+		}
 	}
 }
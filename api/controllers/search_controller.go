@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// GET /search/posts?q=golang&author_id=3&tag=go&from=2023-01-01&to=2023-12-31
+func (server *Server) SearchPosts(c *gin.Context) {
+	errList := map[string]string{}
+
+	search := models.PostSearch{
+		Query:    strings.TrimSpace(c.Query("q")),
+		ViewerID: GetViewerID(c),
+		Tag:      strings.TrimSpace(c.Query("tag")),
+	}
+	if search.Query == "" {
+		errList["Required_query"] = "Required search query"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	if authorID := c.Query("author_id"); authorID != "" {
+		aid, err := strconv.ParseUint(authorID, 10, 32)
+		if err != nil {
+			errList["Invalid_request"] = "Invalid Request"
+			handleError(c, http.StatusBadRequest, errList)
+			return
+		}
+		search.AuthorID = uint(aid)
+	}
+
+	var err error
+	search.From, err = parseSearchDate(c.Query("from"), false)
+	if err != nil {
+		errList["Invalid_date"] = "Dates should be YYYY-MM-DD or RFC3339"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+	search.To, err = parseSearchDate(c.Query("to"), true)
+	if err != nil {
+		errList["Invalid_date"] = "Dates should be YYYY-MM-DD or RFC3339"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	post := models.Post{}
+	results, err := post.SearchPosts(server.DB, search, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   results,
+		"pagination": pg,
+	})
+}
+
+// parseSearchDate reads a date filter, a plain day given as the end of a range covers the whole day
+func parseSearchDate(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return &t, nil
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+// POST /series with {"title": "Go from scratch", "description": "...", "post_ids": [4, 7, 9]}
+func (server *Server) CreateSeries(c *gin.Context) {
+	errList := map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	series := models.Series{}
+	err = json.Unmarshal(body, &series)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	pid, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+	series.ProfileID = uint(pid) // the series belongs to the authenticated user
+
+	series.Prepare()
+	errorMessages := series.Validate()
+	if len(errorMessages) > 0 {
+		handleError(c, http.StatusUnprocessableEntity, errorMessages)
+		return
+	}
+
+	seriesCreated, err := series.SaveSeries(server.DB)
+	if handleSeriesPostsError(c, err) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":   http.StatusCreated,
+		"response": seriesCreated,
+	})
+}
+
+// GET /series?profile_id=3
+func (server *Server) GetSeriesList(c *gin.Context) {
+	errList := map[string]string{}
+
+	var profileID uint64
+	if value := c.Query("profile_id"); value != "" {
+		var err error
+		profileID, err = strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			errList["Invalid_request"] = "Invalid Request"
+			handleError(c, http.StatusBadRequest, errList)
+			return
+		}
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	series := models.Series{}
+	seriesList, err := series.FindAllSeries(server.DB, uint(profileID), GetViewerID(c), pg)
+	if err != nil {
+		errList["No_series"] = "No Series Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   seriesList,
+		"pagination": pg,
+	})
+}
+
+// GET /series/:id, the series with its posts in order
+func (server *Server) GetSeries(c *gin.Context) {
+	errList := map[string]string{}
+
+	sid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	series := models.Series{}
+	seriesReceived, err := series.FindSeriesByID(server.DB, sid, GetViewerID(c))
+	if err != nil {
+		errList["No_series"] = "No Series Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": seriesReceived,
+	})
+}
+
+// PUT /series/:id/posts with {"post_ids": [7, 4, 9]}, the posts of the series in their new order.
+// Posts left out of the list are taken out of the series, new ones are added.
+func (server *Server) ReorderSeries(c *gin.Context) {
+	errList := map[string]string{}
+
+	sid, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	series := models.Series{}
+	err = server.DB.Debug().Model(models.Series{}).Where("id = ?", sid).Take(&series).Error
+	if err != nil {
+		errList["No_series"] = "No Series Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	if profileID != uint32(series.ProfileID) && !HasPermission(c, models.PermissionModeratePosts) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	requestBody := struct {
+		PostIDs []uint `json:"post_ids"`
+	}{}
+	err = json.Unmarshal(body, &requestBody)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	err = series.ReorderSeriesPosts(server.DB, requestBody.PostIDs)
+	if handleSeriesPostsError(c, err) {
+		return
+	}
+
+	seriesUpdated, err := series.FindSeriesByID(server.DB, sid, GetViewerID(c))
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": seriesUpdated,
+	})
+}
+
+// handleSeriesPostsError writes the error response of a failed change of the posts of a series
+func handleSeriesPostsError(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errList := map[string]string{}
+	switch {
+	case errors.Is(err, models.ErrSeriesPostNotOwned):
+		errList["Invalid_posts"] = "Only your own posts can be part of your series"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+	case errors.Is(err, models.ErrSeriesPostTaken):
+		errList["Post_in_series"] = "A post is already part of another series"
+		handleError(c, http.StatusConflict, errList)
+	default:
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+	}
+	return true
+}
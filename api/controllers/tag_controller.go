@@ -0,0 +1,171 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (server *Server) GetTags(c *gin.Context) {
+	errList := map[string]string{}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{}
+	tags, err := tag.FindAllTags(server.DB, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   tags,
+		"pagination": pg,
+	})
+}
+
+func (server *Server) GetTagPosts(c *gin.Context) {
+	errList := map[string]string{}
+
+	tag := models.Tag{}
+	foundTag, err := tag.FindTagBySlug(server.DB, c.Param("slug"))
+	if err != nil {
+		errList["No_tag"] = "No Tag Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	post := models.Post{}
+	posts, err := post.FindTagPosts(server.DB, foundTag.ID, GetViewerID(c), pg)
+	if err != nil {
+		errList["No_post"] = "No Post Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"response": gin.H{
+			"tag":   foundTag,
+			"posts": posts,
+		},
+		"pagination": pg,
+	})
+}
+
+// PUT /tags/:slug with {"name": "New name"}
+func (server *Server) RenameTag(c *gin.Context) {
+	errList := map[string]string{}
+
+	requestBody, ok := readTagRequest(c)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{}
+	foundTag, err := tag.FindTagBySlug(server.DB, c.Param("slug"))
+	if err != nil {
+		errList["No_tag"] = "No Tag Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	renamedTag, err := foundTag.RenameTag(server.DB, requestBody["name"])
+	if errors.Is(err, models.ErrTagInvalidName) {
+		errList["Required_name"] = "Required Name"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+	if errors.Is(err, models.ErrTagExists) {
+		errList["Tag_exists"] = "A tag with this name already exists, merge the tags instead"
+		handleError(c, http.StatusConflict, errList)
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": renamedTag,
+	})
+}
+
+// POST /tags/:slug/merge with {"into": "target-slug"}, the posts of the tag are moved to the target tag
+func (server *Server) MergeTag(c *gin.Context) {
+	errList := map[string]string{}
+
+	requestBody, ok := readTagRequest(c)
+	if !ok {
+		return
+	}
+
+	tag := models.Tag{}
+	sourceTag, err := tag.FindTagBySlug(server.DB, c.Param("slug"))
+	if err != nil {
+		errList["No_tag"] = "No Tag Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	target := models.Tag{}
+	targetTag, err := target.FindTagBySlug(server.DB, requestBody["into"])
+	if err != nil {
+		errList["No_target_tag"] = "No Target Tag Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	_, err = sourceTag.MergeTag(server.DB, targetTag)
+	if errors.Is(err, models.ErrTagMergeSelf) {
+		errList["Invalid_request"] = "Cannot merge a tag into itself"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": targetTag,
+	})
+}
+
+func readTagRequest(c *gin.Context) (map[string]string, bool) {
+	errList := map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return nil, false
+	}
+
+	requestBody := map[string]string{}
+	err = json.Unmarshal(body, &requestBody)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return nil, false
+	}
+	return requestBody, true
+}
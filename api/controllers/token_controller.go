@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+)
+
+func (server *Server) RefreshToken(c *gin.Context) {
+	errList := map[string]string{}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	requestBody := map[string]string{}
+	err = json.Unmarshal(body, &requestBody)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	if requestBody["refresh_token"] == "" {
+		errList["Required_refresh_token"] = "Required Refresh Token"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	refreshToken := models.RefreshToken{}
+	current, err := refreshToken.FindRefreshTokenByHash(server.DB, auth.HashRefreshToken(requestBody["refresh_token"]))
+	if err != nil {
+		errList["Invalid_token"] = "Invalid refresh token"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	err = current.CheckUsable()
+	if errors.Is(err, models.ErrRefreshTokenReused) {
+		// an already rotated token came back, so the session is compromised: end it for everyone holding it
+		_, _ = refreshToken.RevokeRefreshTokenFamily(server.DB, current.FamilyID)
+		errList["Token_reused"] = "Refresh token was already used, please login again"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+	if errors.Is(err, models.ErrRefreshTokenExpired) {
+		errList["Token_expired"] = "Refresh token has expired, please login again"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+	if err != nil {
+		errList["Invalid_token"] = "Invalid refresh token"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	// the user may have been deleted since the token was issued
+	user, err := FindUserByID(server.DB, current.UserID)
+	if err != nil {
+		errList["Not_Found_user"] = "Invalid UserID or user does not exist"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	plainToken, tokenHash, err := auth.NewRefreshToken()
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	next := models.RefreshToken{
+		TokenHash: tokenHash,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenTTL()),
+	}
+	_, err = current.Rotate(server.DB, &next)
+	if errors.Is(err, models.ErrRefreshTokenReused) {
+		_, _ = refreshToken.RevokeRefreshTokenFamily(server.DB, current.FamilyID)
+		errList["Token_reused"] = "Refresh token was already used, please login again"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	accessToken, err := auth.CreateToken(current.UserID, user.Role)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": http.StatusOK,
+		"response": gin.H{
+			"token":         accessToken,
+			"refresh_token": plainToken,
+			"expires_in":    int64(auth.AccessTokenTTL().Seconds()),
+		},
+	})
+}
+
+// issueRefreshToken stores a new refresh token for the user and returns the value handed to the client
+func (server *Server) issueRefreshToken(uid uint32, familyID string) (string, error) {
+	plainToken, tokenHash, err := auth.NewRefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    uid,
+		TokenHash: tokenHash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(auth.RefreshTokenTTL()),
+	}
+	_, err = refreshToken.SaveRefreshToken(server.DB)
+	if err != nil {
+		return "", err
+	}
+	return plainToken, nil
+}
@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// trashResource describes how the trash lists and restores one kind of deleted row of its owner
+type trashResource struct {
+	newModel    func() interface{}
+	newList     func() interface{}
+	ownerColumn string
+	restore     func(db *gorm.DB, record interface{}) error
+}
+
+var trashResources = map[string]trashResource{
+	"posts": {
+		newModel:    func() interface{} { return &models.Post{} },
+		newList:     func() interface{} { return &[]models.Post{} },
+		ownerColumn: "author_id",
+		restore: func(db *gorm.DB, record interface{}) error {
+			return record.(*models.Post).RestorePostCascade(db)
+		},
+	},
+	"comments": {
+		newModel:    func() interface{} { return &models.Comment{} },
+		newList:     func() interface{} { return &[]models.Comment{} },
+		ownerColumn: "profile_id",
+		restore: func(db *gorm.DB, record interface{}) error {
+			return record.(*models.Comment).RestoreCommentCascade(db)
+		},
+	},
+}
+
+// GET /trash/:resource lists what the authenticated profile deleted and can still restore
+func (server *Server) GetTrash(c *gin.Context) {
+	errList := map[string]string{}
+
+	resource, ok := trashResources[c.Param("resource")]
+	if !ok {
+		errList["Invalid_resource"] = "Unknown resource"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
+	list := resource.newList()
+	err = models.FindTrashed(server.DB, resource.newModel(), list, resource.ownerColumn, profileID, pg)
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":     http.StatusOK,
+		"response":   list,
+		"pagination": pg,
+		"retention":  models.TrashRetention().String(),
+	})
+}
+
+// PUT /trash/:resource/:id/restore brings back a deleted row of the authenticated profile
+func (server *Server) RestoreFromTrash(c *gin.Context) {
+	errList := map[string]string{}
+
+	resource, ok := trashResources[c.Param("resource")]
+	if !ok {
+		errList["Invalid_resource"] = "Unknown resource"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	profileID, err := auth.ExtractTokenID(c.Request)
+	if err != nil {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	// only the deleted rows of the profile still in the retention window can be restored
+	record := resource.newModel()
+	err = models.FindTrashedRecord(server.DB, record, id, resource.ownerColumn, profileID)
+	if err != nil {
+		errList["No_record"] = "No Record Found"
+		handleError(c, http.StatusNotFound, errList)
+		return
+	}
+
+	err = resource.restore(server.DB, record)
+	if errors.Is(err, models.ErrTrashParentDeleted) {
+		errList["Parent_deleted"] = err.Error()
+		handleError(c, http.StatusConflict, errList)
+		return
+	}
+	if err != nil {
+		errList["Other_error"] = "Please try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": record,
+	})
+}
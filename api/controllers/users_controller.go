@@ -1,14 +1,10 @@
 package controllers
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
-	"log"
-	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"strconv"
 	"strings"
 
@@ -17,12 +13,7 @@ import (
 	"github.com/Mdromi/exp-blog-backend/api/security"
 	"github.com/Mdromi/exp-blog-backend/api/utils/fileformat"
 	"github.com/Mdromi/exp-blog-backend/api/utils/formaterror"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -47,7 +38,9 @@ func (server *Server) CreateUser(c *gin.Context) {
 	}
 
 	// Set default avatar path
-	user.AvatarPath = "static/uploads/default.png"
+	user.AvatarPath = models.DefaultAvatar
+	// nobody can sign up with a privileged role, admins change it afterwards
+	user.Role = models.RoleAuthor
 
 	user.Prepare()
 	errorMessages := user.Validate("")
@@ -75,16 +68,22 @@ func (server *Server) GetUsers(c *gin.Context) {
 	// clear previous error if any
 	errList = map[string]string{}
 
+	pg, ok := GetPagination(c)
+	if !ok {
+		return
+	}
+
 	user := models.User{}
-	users, err := user.FindAllUsers(server.DB)
+	users, err := user.FindAllUsers(server.DB, pg)
 	if err != nil {
 		errList["No_user"] = "No User Found"
 		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"status":   http.StatusOK,
-		"response": users,
+		"status":     http.StatusOK,
+		"response":   users,
+		"pagination": pg,
 	})
 }
 
@@ -112,45 +111,10 @@ func (server *Server) GetUser(c *gin.Context) {
 	})
 }
 
-// IF YOU ARE USING AMAZON S3
-func SaveProfileImage(s *session.Session, file *multipart.FileHeader) (string, error) {
-	size := file.Size
-	buffer := make([]byte, size)
-	f, err := file.Open()
-	if err != nil {
-		fmt.Println(err)
-	}
-	defer f.Close()
-	filePath := "/profile-photos/" + fileformat.UniqueFormat(file.Filename)
-	f.Read(buffer)
-	fileBytes := bytes.NewReader(buffer)
-	fileType := http.DetectContentType(buffer)
-
-	_, err = s3.New(s).PutObject(&s3.PutObjectInput{
-		ACL:           aws.String("public-read"),
-		Body:          fileBytes,
-		Bucket:        aws.String("chodapibucket"),
-		ContentLength: aws.Int64(size),
-		ContentType:   aws.String(fileType),
-		Key:           aws.String(filePath),
-	})
-	if err != nil {
-		return "", err
-	}
-
-	return filePath, err
-}
-
 func (server *Server) UpdateAvatar(c *gin.Context) {
 	// clear previous error if any
 	errList = map[string]string{}
 
-	var err error
-	err = godotenv.Load()
-	if err != nil {
-		log.Fatalf("Error gotting env, %v", err)
-	}
-
 	userID := c.Param("id")
 	// check if the user id is valid
 	uid, err := strconv.ParseUint(userID, 10, 32)
@@ -169,7 +133,7 @@ func (server *Server) UpdateAvatar(c *gin.Context) {
 	}
 
 	// if the id is not the authenticated user id
-	if tokenID != 0 && tokenID != uint32(uid) {
+	if tokenID != 0 && tokenID != uint32(uid) && !HasPermission(c, models.PermissionManageUsers) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
@@ -199,7 +163,6 @@ func (server *Server) UpdateAvatar(c *gin.Context) {
 	}
 	buffer := make([]byte, size)
 	f.Read(buffer)
-	fileBytes := bytes.NewReader(buffer)
 	fileType := http.DetectContentType(buffer)
 	// if the image is valid
 	if !strings.HasPrefix(fileType, "image") {
@@ -207,53 +170,24 @@ func (server *Server) UpdateAvatar(c *gin.Context) {
 		handleError(c, http.StatusUnprocessableEntity, errList)
 		return
 	}
-	filePath := fileformat.UniqueFormat(file.Filename)
-	path := "/profile-photos/" + filePath
-	params := &s3.PutObjectInput{
-		Bucket:        aws.String("chodapi"),
-		Key:           aws.String(path),
-		Body:          fileBytes,
-		ContentLength: aws.Int64(size),
-		ContentType:   aws.String(fileType),
-		ACL:           aws.String("public-read"),
-	}
-	s3Config := &aws.Config{
-		Credentials: credentials.NewStaticCredentials(
-			os.Getenv("DO_SPACES_KEY"), os.Getenv("DO_SPACES_SECRET"), os.Getenv("DO_SPACES_TOKEN")),
-		Endpoint: aws.String(os.Getenv("DO_SPACES_ENDPOINT")),
-		Region:   aws.String(os.Getenv("DO_SPACES_REGION")),
+	key := path.Join("avatars", strconv.Itoa(int(uid)), fileformat.UniqueFormat(file.Filename))
+	err = server.Storage.Put(key, buffer, fileType)
+	if err != nil {
+		errList["Cannot_Save"] = "Cannot Save Image, Pls try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
 	}
-	newSession := session.New(s3Config)
-	s3Client := s3.New(newSession)
-
-	_, err = s3Client.PutObject(params)
+	object := models.MediaObject{Key: key, ContentType: fileType, Size: size, OwnerType: models.MediaOwnerAvatar, OwnerID: uint(uid)}
+	_, err = object.SaveMediaObject(server.DB)
 	if err != nil {
-		fmt.Println(err.Error())
-		return
-	}
-
-	//IF YOU PREFER TO USE AMAZON S3
-	// s, err := session.NewSession(&aws.Config{Too_large
-	// 	Region: aws.String("us-east-1"),
-	// 	Credentials: credentials.NewStaticCredentials(
-	// 		os.Getenv("AWS_KEY"),
-	// 		os.Getenv("AWS_SECRET"),
-	// 		os.Getenv("AWS_TOKEN"),
-	// 		),
-	// })
-	// if err != nil {
-	// 	fmt.Printf("Could not upload file first error: %s\n", err)
-	// }
-	// fileName, err := SaveProfileImage(s, file)
-	// if err != nil {
-	// 	fmt.Printf("Could not upload file %s\n", err)
-	// } else {
-	// 	fmt.Printf("Image uploaded: %s\n", fileName)
-	// }
+		errList["Cannot_Save"] = "Cannot Save Image, Pls try again later"
+		handleError(c, http.StatusInternalServerError, errList)
+		return
+	}
 
 	// save The iamge path to the database
 	user := models.User{}
-	user.AvatarPath = filePath
+	user.AvatarPath = server.Storage.URL(key)
 	user.Prepare()
 	updatedUser, err := user.UpdateAUserAvatar(server.DB, uint32(uid))
 
@@ -262,6 +196,7 @@ func (server *Server) UpdateAvatar(c *gin.Context) {
 		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
+	server.releaseReplacedMedia(uint32(uid), models.MediaOwnerAvatar)
 	c.JSON(http.StatusOK, gin.H{
 		"status":   http.StatusOK,
 		"response": updatedUser,
@@ -290,7 +225,7 @@ func (server *Server) UpdateUser(c *gin.Context) {
 	}
 
 	// if the id is not the authentiacation user id
-	if tokenID != 0 && tokenID != uint32(uid) {
+	if tokenID != 0 && tokenID != uint32(uid) && !HasPermission(c, models.PermissionManageUsers) {
 		errList["Unauthorized"] = "Unauthorized"
 		handleError(c, http.StatusUnauthorized, errList)
 		return
@@ -378,13 +313,11 @@ func (server *Server) UpdateUser(c *gin.Context) {
 	})
 }
 
-func (server *Server) DeleteUser(c *gin.Context) {
+func (server *Server) UpdateUserRole(c *gin.Context) {
 	// clear previous error if any
 	errList = map[string]string{}
-	var tokenID uint32
-	userID := c.Param("id")
 
-	// check if the user id is valid
+	userID := c.Param("id")
 	uid, err := strconv.ParseUint(userID, 10, 32)
 	if err != nil {
 		errList["Invalid_request"] = "Invalid Request"
@@ -392,50 +325,85 @@ func (server *Server) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// get user id from the token for valid tokens
-	tokenID, err = auth.ExtractTokenID(c.Request)
+	body, err := ioutil.ReadAll(c.Request.Body)
 	if err != nil {
-		errList["Unauthorized"] = "Unauthorized"
-		handleError(c, http.StatusUnauthorized, errList)
+		errList["Invalid_body"] = "Unable to get request"
+		handleError(c, http.StatusUnprocessableEntity, errList)
 		return
 	}
 
-	// If the id is not the authenticated user id
-	if tokenID != 0 && tokenID != uint32(uid) {
-		errList["Unauthorized"] = "Unauthorized"
-		handleError(c, http.StatusUnauthorized, errList)
+	requestBody := map[string]string{}
+	err = json.Unmarshal(body, &requestBody)
+	if err != nil {
+		errList["Unmarshal_error"] = "Cannot unmarshal body"
+		handleError(c, http.StatusUnprocessableEntity, errList)
 		return
 	}
 
 	user := models.User{}
-	_, err = user.DeleteAUser(server.DB, uint32(uid))
+	user.Role = strings.ToLower(strings.TrimSpace(requestBody["role"]))
+	if !models.IsValidRole(user.Role) {
+		errList["Invalid_role"] = "Role should be one of reader, author, editor or admin"
+		handleError(c, http.StatusUnprocessableEntity, errList)
+		return
+	}
+
+	updatedUser, err := user.UpdateAUserRole(server.DB, uint32(uid))
 	if err != nil {
-		errList["Other_error"] = "Please try again later"
+		errList["No_user"] = "No User Found"
 		handleError(c, http.StatusNotFound, errList)
 		return
 	}
 
-	// Also delete the posts, likes and the comments that this user created if any:
-	comment := models.Comment{}
-	likeDislike := models.LikeDislike{}
-	post := models.Post{}
-
-	_, err = post.DeleteUserPosts(server.DB, uint32(uid))
+	// the role is part of the access token, so the old tokens have to be refreshed to pick up the new one
+	err = auth.Revocations.RevokeAllSessions(uint32(uid))
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
 		handleError(c, http.StatusInternalServerError, errList)
 		return
 	}
-	_, err = comment.DeleteUserComments(server.DB, uint32(uid))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":   http.StatusOK,
+		"response": updatedUser,
+	})
+}
+
+func (server *Server) DeleteUser(c *gin.Context) {
+	// clear previous error if any
+	errList = map[string]string{}
+	var tokenID uint32
+	userID := c.Param("id")
+
+	// check if the user id is valid
+	uid, err := strconv.ParseUint(userID, 10, 32)
+	if err != nil {
+		errList["Invalid_request"] = "Invalid Request"
+		handleError(c, http.StatusBadRequest, errList)
+		return
+	}
+
+	// get user id from the token for valid tokens
+	tokenID, err = auth.ExtractTokenID(c.Request)
 	if err != nil {
-		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusInternalServerError, errList)
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
+		return
+	}
+
+	// If the id is not the authenticated user id
+	if tokenID != 0 && tokenID != uint32(uid) && !HasPermission(c, models.PermissionManageUsers) {
+		errList["Unauthorized"] = "Unauthorized"
+		handleError(c, http.StatusUnauthorized, errList)
 		return
 	}
-	_, err = likeDislike.DeleteUserLikes(server.DB, uint32(uid))
+
+	// Also delete the profile, posts, likes, comments and replies that this user created if any:
+	user := models.User{}
+	err = user.DeleteUserCascade(server.DB, uint32(uid))
 	if err != nil {
 		errList["Other_error"] = "Please try again later"
-		handleError(c, http.StatusInternalServerError, errList)
+		handleError(c, http.StatusNotFound, errList)
 		return
 	}
 
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Every runs fn right away and then once per interval in its own goroutine, until the returned stop func is called.
+// A failing run is logged and the job keeps going.
+func Every(interval time.Duration, name string, fn func() error) (stop func()) {
+	done := make(chan struct{})
+	var once sync.Once
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := fn(); err != nil {
+				log.Printf("job %s failed: %v", name, err)
+			}
+
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
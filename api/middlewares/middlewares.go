@@ -1,18 +1,27 @@
 package middlewares
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
 	"github.com/gin-gonic/gin"
 )
 
 func TokenAuthMiddleware() gin.HandlerFunc {
-	errList := make(map[string]string)
 	return func(c *gin.Context) {
+		errList := make(map[string]string)
 		err := auth.TokenValid(c.Request)
 		if err != nil {
-			errList["Unauthorized"] = "Unauthorized"
+			if errors.Is(err, auth.ErrTokenExpired) {
+				// clients use this code to know they should call /token/refresh instead of login again
+				errList["Token_expired"] = "Token has expired"
+			} else if errors.Is(err, auth.ErrTokenRevoked) {
+				errList["Token_revoked"] = "Token has been revoked"
+			} else {
+				errList["Unauthorized"] = "Unauthorized"
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"status": http.StatusUnauthorized,
 				"error":  errList,
@@ -24,6 +33,56 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RequireRole only lets through tokens issued to one of the roles, use it after TokenAuthMiddleware
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := auth.ExtractTokenRole(c.Request)
+		if err != nil {
+			abortUnauthorized(c)
+			return
+		}
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		abortForbidden(c)
+	}
+}
+
+// RequirePermission only lets through tokens whose role grants the permission, use it after TokenAuthMiddleware
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := auth.ExtractTokenRole(c.Request)
+		if err != nil {
+			abortUnauthorized(c)
+			return
+		}
+		if !models.RoleHasPermission(role, permission) {
+			abortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+func abortUnauthorized(c *gin.Context) {
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"status": http.StatusUnauthorized,
+		"error":  map[string]string{"Unauthorized": "Unauthorized"},
+	})
+	c.Abort()
+}
+
+func abortForbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{
+		"status": http.StatusForbidden,
+		"error":  map[string]string{"Forbidden": "You do not have permission to do this"},
+	})
+	c.Abort()
+}
+
 // This enables us interact with the React Frontend
 func CORSMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
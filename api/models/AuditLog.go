@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+// Actions recorded in the audit log
+const (
+	AuditActionSuspend    = "suspend"
+	AuditActionRestore    = "restore"
+	AuditActionHardDelete = "hard_delete"
+	AuditActionLabelSpam  = "label_spam"
+	AuditActionLabelHam   = "label_ham"
+	AuditActionResolve    = "resolve_reports"
+	AuditActionAutoHide   = "auto_hide" // taken by the reports themselves, it has no actor
+)
+
+// AuditLog records every action taken from the admin api
+type AuditLog struct {
+	gorm.Model
+	ActorID    uint32 `gorm:"not null;index" json:"actor_id"`
+	Action     string `gorm:"size:50;not null" json:"action"`
+	TargetType string `gorm:"size:30;not null;index" json:"target_type"`
+	TargetID   uint64 `gorm:"not null" json:"target_id"`
+	Details    string `gorm:"type:text" json:"details"`
+}
+
+func (al *AuditLog) SaveAuditLog(db *gorm.DB) (*AuditLog, error) {
+	err := db.Debug().Create(&al).Error
+	if err != nil {
+		return &AuditLog{}, err
+	}
+	return al, nil
+}
+
+func (al *AuditLog) FindAuditLogs(db *gorm.DB, targetType string, actorID uint32, pg *pagination.Pagination) (*[]AuditLog, error) {
+	auditLogs := []AuditLog{}
+	query := db.Debug().Model(&AuditLog{})
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if actorID != 0 {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	query, err := pg.Paginate(query, "")
+	if err != nil {
+		return &[]AuditLog{}, err
+	}
+	err = query.Find(&auditLogs).Error
+	if err != nil {
+		return &[]AuditLog{}, err
+	}
+	pg.SetNextCursor(auditLogs)
+	return &auditLogs, nil
+}
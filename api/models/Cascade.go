@@ -0,0 +1,207 @@
+package models
+
+import (
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// The cascades delete a post, profile or user with everything belonging to it in one transaction, so a failure
+// halfway leaves no orphans. The rows are soft deleted and can be restored, on a db from HardDeleteDB they are
+// removed for good along with the rows that have no soft delete: tags, permalinks, revisions, series, collaborators
+// and media. The pictures of a deleted profile are released either way, the media of a post only when it is removed
+// for good, so a soft deleted post keeps its images.
+
+// HardDeleteDB returns the db the cascades remove the rows for good with. It is a new session, so the queries built
+// on it each get their own conditions, like on the db gorm opens.
+func HardDeleteDB(db *gorm.DB) *gorm.DB {
+	return db.Unscoped().Session(&gorm.Session{})
+}
+
+// DeletePostCascade deletes the post with its comments and its likes
+func (p *Post) DeletePostCascade(db *gorm.DB) error {
+	hard := db.Statement.Unscoped
+	return db.Transaction(func(tx *gorm.DB) error {
+		if hard {
+			tx = HardDeleteDB(tx)
+		}
+		return deletePost(tx, p, hard)
+	})
+}
+
+// DeleteProfileCascade deletes the profile, its user, its posts, and the comments and likes it made
+func (p *Profile) DeleteProfileCascade(db *gorm.DB, pid uint32) error {
+	hard := db.Statement.Unscoped
+	return db.Transaction(func(tx *gorm.DB) error {
+		if hard {
+			tx = HardDeleteDB(tx)
+		}
+		return deleteProfile(tx, pid, hard)
+	})
+}
+
+// DeleteUserCascade deletes the user with its profile, or with the content it made when it has none
+func (u *User) DeleteUserCascade(db *gorm.DB, uid uint32) error {
+	hard := db.Statement.Unscoped
+	return db.Transaction(func(tx *gorm.DB) error {
+		if hard {
+			tx = HardDeleteDB(tx)
+		}
+		user := User{}
+		err := tx.Debug().Model(&User{}).Where("id = ?", uid).Take(&user).Error
+		if err != nil {
+			return err
+		}
+		if user.ProfileID != 0 {
+			// deleting the profile deletes its user as well
+			return deleteProfile(tx, user.ProfileID, hard)
+		}
+
+		if err := deleteProfileContent(tx, uid, hard); err != nil {
+			return err
+		}
+		mediaObject := MediaObject{}
+		if _, err := mediaObject.ReleaseMediaObjects(tx, uint(uid), MediaOwnerAvatar); err != nil {
+			return err
+		}
+		_, err = user.DeleteAUser(tx, uid)
+		return err
+	})
+}
+
+func deletePost(tx *gorm.DB, p *Post, hard bool) error {
+	comment := Comment{DeletedBy: p.DeletedBy}
+	likeDislike := LikeDislike{}
+
+	// a soft deleted post goes first, so the rows deleted with it are not older than it and are restored with it
+	if !hard {
+		if _, err := p.DeleteAPost(tx); err != nil {
+			return err
+		}
+	}
+	if _, err := comment.DeletePostComments(tx, uint64(p.ID)); err != nil {
+		return err
+	}
+	if _, err := likeDislike.DeletePostLikes(tx, uint64(p.ID)); err != nil {
+		return err
+	}
+	if hard {
+		if _, err := p.DeletePostTags(tx); err != nil {
+			return err
+		}
+		if _, err := p.DeletePostSlugs(tx); err != nil {
+			return err
+		}
+		if _, err := p.DeletePostRevisions(tx); err != nil {
+			return err
+		}
+		if _, err := p.DeletePostSeries(tx); err != nil {
+			return err
+		}
+		if _, err := p.DeletePostCollaborators(tx); err != nil {
+			return err
+		}
+		if _, err := p.DeletePostMedia(tx); err != nil {
+			return err
+		}
+		mediaObject := MediaObject{}
+		if _, err := mediaObject.ReleaseMediaObjects(tx, p.ID, MediaOwnerPost); err != nil {
+			return err
+		}
+		if _, err := p.DeleteAPost(tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteProfileContent deletes the posts of the profile and what it wrote or liked on the other posts
+func deleteProfileContent(tx *gorm.DB, pid uint32, hard bool) error {
+	comment := Comment{DeletedBy: pid}
+	likeDislike := LikeDislike{}
+
+	posts := []Post{}
+	err := tx.Debug().Model(&Post{}).Where("author_id = ?", pid).Find(&posts).Error
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].DeletedBy = pid
+		if err := deletePost(tx, &posts[i], hard); err != nil {
+			return err
+		}
+	}
+
+	// the replies to the comments of the profile go with them
+	if _, err := comment.DeleteUserComments(tx, pid); err != nil {
+		return err
+	}
+	if _, err := likeDislike.DeleteUserLikes(tx, pid); err != nil {
+		return err
+	}
+	if hard {
+		series := Series{}
+		if _, err := series.DeleteProfileSeries(tx, pid); err != nil {
+			return err
+		}
+		collaborator := PostCollaborator{}
+		if _, err := collaborator.DeleteProfileCollaborations(tx, pid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deleteProfile(tx *gorm.DB, pid uint32, hard bool) error {
+	profile := Profile{}
+	err := tx.Debug().Model(&Profile{}).Where("id = ?", pid).Take(&profile).Error
+	if err != nil {
+		return err
+	}
+	if err := deleteProfileContent(tx, pid, hard); err != nil {
+		return err
+	}
+
+	// the avatar belongs to the user of the profile, the pictures to the profile
+	mediaObject := MediaObject{}
+	if _, err := mediaObject.ReleaseMediaObjects(tx, profile.UserID, MediaOwnerAvatar); err != nil {
+		return err
+	}
+	if _, err := mediaObject.ReleaseMediaObjects(tx, uint(pid), MediaOwnerProfilePic, MediaOwnerCoverPic); err != nil {
+		return err
+	}
+	_, err = profile.DeleteAUserProfile(tx, pid)
+	return err
+}
+
+// cascadeForeignKeys are the references between the tables that gorm does not create from the associations. They
+// delete the rows with the row they point to, which only happens on a hard delete, as a safety net for the cascades.
+var cascadeForeignKeys = []struct {
+	Name, Table, Column, References string
+}{
+	{"fk_comments_post", "comments", "post_id", "posts"},
+	{"fk_comments_parent", "comments", "parent_id", "comments"},
+	{"fk_like_dislikes_post", "like_dislikes", "post_id", "posts"},
+	{"fk_like_dislikes_profile", "like_dislikes", "profile_id", "profiles"},
+	{"fk_post_slugs_post", "post_slugs", "post_id", "posts"},
+	{"fk_post_revisions_post", "post_revisions", "post_id", "posts"},
+	{"fk_series_posts_post", "series_posts", "post_id", "posts"},
+	{"fk_series_posts_series", "series_posts", "series_id", "series"},
+	{"fk_post_collaborators_post", "post_collaborators", "post_id", "posts"},
+}
+
+// AddForeignKeys adds the missing foreign keys after the migration. A key is skipped when the table still has
+// orphaned rows, or with mysql when the columns differ in their type, and the others are added anyway.
+func AddForeignKeys(db *gorm.DB) {
+	for _, fk := range cascadeForeignKeys {
+		if db.Migrator().HasConstraint(fk.Table, fk.Name) {
+			continue
+		}
+		err := db.Debug().Exec(fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s) REFERENCES %s (id) ON DELETE CASCADE",
+			fk.Table, fk.Name, fk.Column, fk.References)).Error
+		if err != nil {
+			log.Printf("cannot add the foreign key %s: %v\n", fk.Name, err)
+		}
+	}
+}
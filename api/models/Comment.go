@@ -2,20 +2,78 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"html"
 	"strings"
+	"time"
 
+	"github.com/Mdromi/exp-blog-backend/api/moderation"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"gorm.io/gorm"
 )
 
-// Comment represents a comment on a post
+// The deepest a reply can be nested, the path of a comment has a segment for every level
+const MaxCommentDepth = 50
+
+// DefaultCommentThreadDepth is how many levels of replies are returned with a thread when none is asked for
+const DefaultCommentThreadDepth = 3
+
+var (
+	ErrCommentParentNotFound = errors.New("the comment replied to does not exist on this post")
+	ErrCommentTooDeep        = fmt.Errorf("replies cannot be nested deeper than %d levels", MaxCommentDepth)
+)
+
+// Comment represents a comment on a post. Replies are comments with a parent, every comment keeps the path of
+// the ids from the top of its thread so a whole thread is loaded with one query and sorted in reading order.
 type Comment struct {
 	gorm.Model
-	ProfileID uint32  `gorm:"not null" json:"profile_id"`
-	PostID    uint64  `gorm:"not null" json:"post_id"`
-	Body      string  `gorm:"type:text;not null" json:"body"`
-	Profile   Profile `json:"profile"`
-	// Replyes *Replyes `gorm:"foreignKey:CommentID" json:"replyes"`
+	ProfileID   uint32     `gorm:"not null" json:"profile_id"`
+	PostID      uint64     `gorm:"not null" json:"post_id"`
+	ParentID    *uint64    `gorm:"index" json:"parent_id"`
+	Path        string     `gorm:"size:600;index" json:"path"`
+	Depth       int        `gorm:"not null;default:0" json:"depth"`
+	Body        string     `gorm:"type:text;not null" json:"body"`
+	Status      string     `gorm:"size:20;not null;default:approved;index" json:"status"`
+	FlagReason  string     `gorm:"type:text" json:"flag_reason"` // why the filters held the comment for a review
+	Profile     Profile    `json:"profile"`
+	SuspendedAt *time.Time `json:"suspended_at"`
+	ReplyCount  int64      `gorm:"-" json:"reply_count"`
+	Replies     []Comment  `gorm:"-" json:"replies"`
+	DeletedBy   uint32     `json:"-"` // the profile that deleted the comment, only its author restores what it deleted itself
+}
+
+// commentPathSegment is the part of the path for the comment id, padded so the paths sort like the ids
+func commentPathSegment(id uint) string {
+	return fmt.Sprintf("%010d/", id)
+}
+
+// BeforeCreate places a reply under its parent, the parent may be deleted when old replies are migrated
+func (c *Comment) BeforeCreate(tx *gorm.DB) error {
+	c.Depth = 0
+	c.Path = ""
+	if c.ParentID == nil {
+		return nil
+	}
+	parent := Comment{}
+	err := tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Comment{}).Where("id = ?", *c.ParentID).Take(&parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && parent.PostID != c.PostID) {
+		return ErrCommentParentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if parent.Depth+1 > MaxCommentDepth {
+		return ErrCommentTooDeep
+	}
+	c.Depth = parent.Depth + 1
+	c.Path = parent.Path
+	return nil
+}
+
+// AfterCreate ends the path with the id the comment got
+func (c *Comment) AfterCreate(tx *gorm.DB) error {
+	c.Path += commentPathSegment(c.ID)
+	return tx.Session(&gorm.Session{NewDB: true}).Unscoped().Model(&Comment{}).Where("id = ?", c.ID).UpdateColumn("path", c.Path).Error
 }
 
 func (c *Comment) Preapre() {
@@ -43,10 +101,41 @@ func (c *Comment) Validate(action string) map[string]string {
 }
 
 func (c *Comment) SaveComment(db *gorm.DB) (*Comment, error) {
-	err := db.Debug().Create(&c).Error
+	// a reply goes under a comment that is still there
+	if c.ParentID != nil {
+		err := db.Debug().Model(&Comment{}).Scopes(NotSuspended, CommentApproved).Where("id = ? AND post_id = ?", *c.ParentID, c.PostID).Take(&Comment{}).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &Comment{}, ErrCommentParentNotFound
+		}
+		if err != nil {
+			return &Comment{}, err
+		}
+	}
+
+	// the owners of the post approve the comment first when they moderate it
+	post := Post{}
+	err := db.Debug().Model(&Post{}).Where("id = ?", c.PostID).Take(&post).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+	held, err := post.HoldsComments(db, c.ProfileID)
 	if err != nil {
 		return &Comment{}, err
 	}
+	c.FlagReason, err = moderation.Check(c.moderationContent())
+	if err != nil {
+		return &Comment{}, err
+	}
+	c.Status = CommentStatusApproved
+	if held || c.FlagReason != "" {
+		c.Status = CommentStatusPending
+	}
+
+	err = db.Debug().Create(&c).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+	moderation.Saved(c.moderationContent())
 	if c.ID != 0 {
 		err = db.Debug().Model(&Profile{}).Where("id = ?", c.ProfileID).Take(&c.Profile).Error
 		if err != nil {
@@ -56,34 +145,168 @@ func (c *Comment) SaveComment(db *gorm.DB) (*Comment, error) {
 	return c, nil
 }
 
-func (c *Comment) GetComments(db *gorm.DB, pid uint64) (*[]Comment, error) {
+func (c *Comment) GetComments(db *gorm.DB, pid uint64, pg *pagination.Pagination) (*[]Comment, error) {
+	return c.GetCommentThreads(db, pid, 0, DefaultCommentThreadDepth, pg)
+}
+
+// GetCommentThreads pages through the comments of the post, or the replies to the parent comment, each with
+// its replies nested up to depth levels below it. The reply count tells when a comment has more replies.
+func (c *Comment) GetCommentThreads(db *gorm.DB, pid uint64, parentID uint64, depth int, pg *pagination.Pagination) (*[]Comment, error) {
+	query := db.Debug().Model(&Comment{}).Scopes(NotSuspended, CommentApproved).Where("post_id = ?", pid)
+	if parentID != 0 {
+		query = query.Where("parent_id = ?", parentID)
+	} else {
+		query = query.Where("parent_id IS NULL")
+	}
+	query, err := pg.Paginate(query, "")
+	if err != nil {
+		return &[]Comment{}, err
+	}
 	comments := []Comment{}
-	err := db.Debug().Model(&Comment{}).Where("post_id = ?", pid).Order("created_at desc").Find(&comments).Error
+	err = query.Find(&comments).Error
+	if err != nil {
+		return &[]Comment{}, err
+	}
+	if len(comments) == 0 {
+		return &comments, nil
+	}
+	pg.SetNextCursor(comments)
+
+	// every reply of the threads, down to the depth asked for, in reading order
+	replies := []Comment{}
+	if depth > 0 {
+		conditions := make([]string, len(comments))
+		values := make([]interface{}, len(comments))
+		for i := range comments {
+			conditions[i] = "path LIKE ?"
+			values[i] = comments[i].Path + "%"
+		}
+		err = db.Debug().Model(&Comment{}).Scopes(NotSuspended, CommentApproved).
+			Where("post_id = ? AND depth > ? AND depth <= ?", pid, comments[0].Depth, comments[0].Depth+depth).
+			Where(strings.Join(conditions, " OR "), values...).
+			Order("path asc").Find(&replies).Error
+		if err != nil {
+			return &[]Comment{}, err
+		}
+	}
+
+	all := make([]*Comment, 0, len(comments)+len(replies))
+	for i := range comments {
+		all = append(all, &comments[i])
+	}
+	for i := range replies {
+		all = append(all, &replies[i])
+	}
+	err = loadCommentProfilesAndReplyCounts(db, all)
 	if err != nil {
 		return &[]Comment{}, err
 	}
 
-	// changed comments[i].UserID
-	if len(comments) > 0 {
-		for _, comment := range comments {
-			err = db.Debug().Model(&Profile{}).Where("id = ?", comment.ProfileID).Take(&comment.Profile).Error
-			if err != nil {
-				return &[]Comment{}, err
-			}
+	// the replies come after their parent, a reply of a hidden comment has no parent here and is left out
+	children := make(map[uint][]*Comment)
+	for i := range replies {
+		if replies[i].ParentID != nil {
+			parentID := uint(*replies[i].ParentID)
+			children[parentID] = append(children[parentID], &replies[i])
 		}
-		return &comments, err
 	}
-	return &comments, err
+	for i := range comments {
+		comments[i] = nestCommentReplies(&comments[i], children)
+	}
+	return &comments, nil
+}
+
+func nestCommentReplies(c *Comment, children map[uint][]*Comment) Comment {
+	c.Replies = []Comment{}
+	for _, child := range children[c.ID] {
+		c.Replies = append(c.Replies, nestCommentReplies(child, children))
+	}
+	return *c
+}
+
+func loadCommentProfilesAndReplyCounts(db *gorm.DB, comments []*Comment) error {
+	ids := make([]uint, len(comments))
+	profileIDs := make([]uint32, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+		profileIDs[i] = comment.ProfileID
+	}
+
+	profiles := []Profile{}
+	err := db.Debug().Model(&Profile{}).Where("id IN ?", profileIDs).Find(&profiles).Error
+	if err != nil {
+		return err
+	}
+	profilesByID := make(map[uint]Profile, len(profiles))
+	for _, profile := range profiles {
+		profilesByID[profile.ID] = profile
+	}
+
+	counts := []struct {
+		ParentID uint
+		Count    int64
+	}{}
+	err = db.Debug().Model(&Comment{}).Scopes(NotSuspended, CommentApproved).Select("parent_id, COUNT(*) AS count").
+		Where("parent_id IN ?", ids).Group("parent_id").Scan(&counts).Error
+	if err != nil {
+		return err
+	}
+	countsByID := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		countsByID[count.ParentID] = count.Count
+	}
+
+	for _, comment := range comments {
+		comment.Profile = profilesByID[uint(comment.ProfileID)]
+		comment.ReplyCount = countsByID[comment.ID]
+	}
+	return nil
 }
 
 func (c *Comment) UpdateAComment(db *gorm.DB) (*Comment, error) {
 	var err error
 
-	err = db.Debug().Model(&Comment{}).Where("id = ?", c.ID).Updates(Comment{Body: c.Body}).Error
+	orig := Comment{}
+	err = db.Debug().Model(&Comment{}).Where("id = ?", c.ID).Take(&orig).Error
+	if err != nil {
+		return &Comment{}, err
+	}
 
+	// an edit the filters flag is hidden again until it is approved, and a clean edit of a comment only the
+	// filters held shows it again
+	c.FlagReason, err = moderation.Check(c.moderationContent())
 	if err != nil {
 		return &Comment{}, err
 	}
+	columns := map[string]interface{}{
+		"body":        c.Body,
+		"flag_reason": c.FlagReason,
+	}
+	if c.FlagReason != "" {
+		c.Status = CommentStatusPending
+		columns["status"] = c.Status
+	} else if orig.Status == CommentStatusPending && orig.FlagReason != "" {
+		post := Post{}
+		err = db.Debug().Model(&Post{}).Where("id = ?", orig.PostID).Take(&post).Error
+		if err != nil {
+			return &Comment{}, err
+		}
+		held, err := post.HoldsComments(db, orig.ProfileID)
+		if err != nil {
+			return &Comment{}, err
+		}
+		if !held {
+			c.Status = CommentStatusApproved
+			columns["status"] = c.Status
+		}
+	}
+
+	err = db.Debug().Model(&Comment{}).Where("id = ?", c.ID).Updates(columns).Error
+
+	if err != nil {
+		return &Comment{}, err
+	}
+	moderation.Saved(c.moderationContent())
 
 	if c.ID != 0 {
 		err = db.Debug().Model(&Profile{}).Where("id = ?", c.ProfileID).Take(&c.Profile).Error
@@ -94,35 +317,84 @@ func (c *Comment) UpdateAComment(db *gorm.DB) (*Comment, error) {
 	return c, nil
 }
 
+func (c *Comment) moderationContent() moderation.Content {
+	return moderation.Content{Kind: moderation.KindComment, ID: uint64(c.ID), AuthorID: c.ProfileID, Text: c.Body}
+}
+
+// DeleteAComment deletes the comment with the replies below it
 func (c *Comment) DeleteAComment(db *gorm.DB) (int64, error) {
-	// Delete the comment replies first
-	replyesModel := Replyes{}
-	_, err := replyesModel.DeleteACommentReplyes(db, uint32(c.ID))
+	var rowsAffected int64
+	hard := db.Statement.Unscoped
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if hard {
+			tx = HardDeleteDB(tx)
+		}
+		comment := Comment{}
+		err := tx.Debug().Model(&Comment{}).Where("id = ?", c.ID).Take(&comment).Error
+		if err != nil {
+			return err
+		}
+		comment.DeletedBy = c.DeletedBy
+		rowsAffected, err = comment.deleteThread(tx)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
+	return rowsAffected, nil
+}
 
-	// Now, delete the comment
-	db = db.Debug().Model(&Comment{}).Where("id = ?", c.ID).Take(&Comment{}).Delete(&Comment{})
-
-	if db.Error != nil {
-		return 0, db.Error
+// deleteThread deletes the comment first, so its replies are not older than it and are restored with it
+func (c *Comment) deleteThread(tx *gorm.DB) (int64, error) {
+	err := markDeletedBy(tx, &Comment{}, c.DeletedBy, "id = ?", c.ID)
+	if err != nil {
+		return 0, err
 	}
-	return db.RowsAffected, nil
+	if c.Path != "" {
+		err = markDeletedBy(tx, &Comment{}, c.DeletedBy, "path LIKE ?", c.Path+"%")
+		if err != nil {
+			return 0, err
+		}
+	}
+	result := tx.Debug().Model(&Comment{}).Where("id = ?", c.ID).Delete(&Comment{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if c.Path == "" {
+		return result.RowsAffected, nil
+	}
+	replies := tx.Debug().Model(&Comment{}).Where("path LIKE ? AND id <> ?", c.Path+"%", c.ID).Delete(&Comment{})
+	if replies.Error != nil {
+		return 0, replies.Error
+	}
+	return result.RowsAffected + replies.RowsAffected, nil
 }
 
-// When a profile deleted, we also delete the comments that the profile had
+// When a profile deleted, we also delete the comments that the profile had, with the replies to them
 func (c *Comment) DeleteUserComments(db *gorm.DB, profileID uint32) (int64, error) {
-	commetns := []Comment{}
-	db = db.Debug().Model(&Comment{}).Where("profile_id", profileID).Find(&commetns).Delete(&commetns)
-	if db.Error != nil {
-		return 0, db.Error
+	comments := []Comment{}
+	err := db.Debug().Model(&Comment{}).Where("profile_id = ?", profileID).Order("path asc").Find(&comments).Error
+	if err != nil {
+		return 0, err
 	}
-	return db.RowsAffected, nil
+	var deleted int64
+	for i := range comments {
+		comments[i].DeletedBy = c.DeletedBy
+		rowsAffected, err := comments[i].deleteThread(db)
+		if err != nil {
+			return 0, err
+		}
+		deleted += rowsAffected
+	}
+	return deleted, nil
 }
 
 // When a post is deleted, we also delete the comments that the post had
 func (c *Comment) DeletePostComments(db *gorm.DB, postID uint64) (int64, error) {
+	err := markDeletedBy(db, &Comment{}, c.DeletedBy, "post_id = ?", postID)
+	if err != nil {
+		return 0, err
+	}
 	comments := []Comment{}
 	db = db.Debug().Model(&Comment{}).Where("post_id = ?", postID).Find(&comments).Delete(&comments)
 	if db.Error != nil {
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+// States of a comment, only approved comments are shown to readers
+const (
+	CommentStatusPending  = "pending"
+	CommentStatusApproved = "approved"
+	CommentStatusRejected = "rejected"
+)
+
+func IsValidCommentStatus(status string) bool {
+	switch status {
+	case CommentStatusPending, CommentStatusApproved, CommentStatusRejected:
+		return true
+	}
+	return false
+}
+
+// CommentApproved keeps the comments the readers can see, use it with db.Scopes on public queries
+func CommentApproved(db *gorm.DB) *gorm.DB {
+	return db.Where("status = ?", CommentStatusApproved)
+}
+
+// HoldsComments tells if a comment of the profile on the post waits for the approval of its owners. The setting of
+// the post wins over the one of its author, and the comments of the author are never held.
+func (p *Post) HoldsComments(db *gorm.DB, profileID uint32) (bool, error) {
+	if p.AuthorID == uint(profileID) {
+		return false, nil
+	}
+	if p.ModerateComments != nil {
+		return *p.ModerateComments, nil
+	}
+	author := Profile{}
+	err := db.Debug().Model(&Profile{}).Select("moderate_comments").Where("id = ?", p.AuthorID).Take(&author).Error
+	if err != nil {
+		return false, err
+	}
+	return author.ModerateComments != nil && *author.ModerateComments, nil
+}
+
+// SetCommentStatus approves or rejects the comment
+func (c *Comment) SetCommentStatus(db *gorm.DB, status string) (*Comment, error) {
+	err := db.Debug().Model(&Comment{}).Where("id = ?", c.ID).UpdateColumn("status", status).Error
+	if err != nil {
+		return &Comment{}, err
+	}
+	c.Status = status
+	return c, nil
+}
+
+// FindModerationQueue pages through the comments in the status on every post of the author
+func FindModerationQueue(db *gorm.DB, authorID uint32, status string, pg *pagination.Pagination) (*[]Comment, error) {
+	comments := []Comment{}
+	posts := db.Session(&gorm.Session{NewDB: true}).Model(&Post{}).Select("id").Where("author_id = ?", authorID)
+	query, err := pg.Paginate(db.Debug().Model(&Comment{}).Scopes(NotSuspended).Where("status = ? AND post_id IN (?)", status, posts), "")
+	if err != nil {
+		return &[]Comment{}, err
+	}
+	err = query.Preload("Profile").Find(&comments).Error
+	if err != nil {
+		return &[]Comment{}, err
+	}
+	pg.SetNextCursor(comments)
+	return &comments, nil
+}
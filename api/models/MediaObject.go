@@ -0,0 +1,184 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// What a media object is used by, the owner id is the id of that record
+const (
+	MediaOwnerAvatar     = "avatar"
+	MediaOwnerProfilePic = "profile_pic"
+	MediaOwnerCoverPic   = "cover_pic"
+	MediaOwnerPost       = "post"
+	MediaOwnerNone       = "none" // a file of the storage no record uses
+)
+
+// DefaultAvatar is the avatar of the users who did not upload one, it is shared and never collected
+const DefaultAvatar = "static/uploads/default.png"
+
+// MediaObject tracks a file of the storage backend and the record using it. The file is released when
+// the record stops using it, and the media garbage collection deletes it once the grace period has passed.
+type MediaObject struct {
+	ID          uint       `gorm:"primarykey" json:"id"`
+	Key         string     `gorm:"size:255;not null;uniqueIndex" json:"key"`
+	ContentType string     `gorm:"size:100" json:"content_type"`
+	Size        int64      `json:"size"`
+	OwnerType   string     `gorm:"size:30;not null;index:idx_media_owner" json:"owner_type"`
+	OwnerID     uint       `gorm:"not null;index:idx_media_owner" json:"owner_id"`
+	ReleasedAt  *time.Time `gorm:"index" json:"released_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// MediaGracePeriod is how long a released file is kept before it is deleted, MEDIA_GC_GRACE or a day
+func MediaGracePeriod() time.Duration {
+	grace, err := time.ParseDuration(os.Getenv("MEDIA_GC_GRACE"))
+	if err != nil || grace < 0 {
+		return 24 * time.Hour
+	}
+	return grace
+}
+
+// SaveMediaObject tracks an uploaded file. A row the media sweep registered for the key in the meantime is taken over.
+func (m *MediaObject) SaveMediaObject(db *gorm.DB) (*MediaObject, error) {
+	err := db.Debug().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content_type", "size", "owner_type", "owner_id", "released_at"}),
+	}).Create(&m).Error
+	if err != nil {
+		return &MediaObject{}, err
+	}
+	return m, nil
+}
+
+// ReleaseMediaObjects releases all the files of the owner, for the given kinds of use
+func (m *MediaObject) ReleaseMediaObjects(db *gorm.DB, ownerID uint, ownerTypes ...string) (int64, error) {
+	db = db.Debug().Model(&MediaObject{}).Where("owner_id = ? AND owner_type IN ? AND released_at IS NULL", ownerID, ownerTypes).UpdateColumn("released_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// TrackMediaObject registers a file found in the storage, a file already tracked is left as it is
+func (m *MediaObject) TrackMediaObject(db *gorm.DB) (int64, error) {
+	db = db.Debug().Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "key"}}, DoNothing: true}).Create(&m)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// ReleaseMediaObjectKeys releases the files stored under the keys, whatever uses them
+func (m *MediaObject) ReleaseMediaObjectKeys(db *gorm.DB, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	db = db.Debug().Model(&MediaObject{}).Where("key IN ? AND released_at IS NULL", keys).UpdateColumn("released_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// FindMediaObjectKeys lists the keys of all the tracked files
+func (m *MediaObject) FindMediaObjectKeys(db *gorm.DB) ([]string, error) {
+	keys := []string{}
+	err := db.Debug().Model(&MediaObject{}).Pluck("key", &keys).Error
+	if err != nil {
+		return []string{}, err
+	}
+	return keys, nil
+}
+
+// MediaReference is a path, url or key of a file stored in a record, with the record using it
+type MediaReference struct {
+	Path      string
+	OwnerType string
+	OwnerID   uint
+}
+
+// FindMediaReferences lists the files the records point to, the trashed records included since they can be restored.
+// The records kept paths and urls before the files were tracked, only the uploads of posts are always keys.
+func FindMediaReferences(db *gorm.DB) ([]MediaReference, error) {
+	references := []MediaReference{}
+	columns := []struct {
+		model     interface{}
+		column    string
+		ownerType string
+	}{
+		{&User{}, "avatar_path", MediaOwnerAvatar},
+		{&Profile{}, "profile_pic", MediaOwnerProfilePic},
+		{&Profile{}, "cover_pic", MediaOwnerCoverPic},
+		{&Post{}, "thumbnails", MediaOwnerPost},
+		{&Post{}, "thumbnail_key", MediaOwnerPost},
+	}
+	for _, c := range columns {
+		found := []MediaReference{}
+		err := db.Debug().Unscoped().Model(c.model).Select(c.column+" AS path, ? AS owner_type, id AS owner_id", c.ownerType).Where(c.column + " <> ''").Scan(&found).Error
+		if err != nil {
+			return []MediaReference{}, err
+		}
+		references = append(references, found...)
+	}
+
+	medias := []PostMedia{}
+	err := db.Debug().Model(&PostMedia{}).Find(&medias).Error
+	if err != nil {
+		return []MediaReference{}, err
+	}
+	for _, media := range medias {
+		for _, variant := range media.Variants {
+			references = append(references, MediaReference{Path: variant.Key, OwnerType: MediaOwnerPost, OwnerID: media.PostID})
+		}
+	}
+	return references, nil
+}
+
+// ReleaseReplacedMediaObjects releases the files of the owner replaced by its latest upload
+func (m *MediaObject) ReleaseReplacedMediaObjects(db *gorm.DB, ownerID uint, ownerType string) (int64, error) {
+	latest := MediaObject{}
+	err := db.Debug().Model(&MediaObject{}).Where("owner_id = ? AND owner_type = ?", ownerID, ownerType).Order("id desc").Take(&latest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	db = db.Debug().Model(&MediaObject{}).Where("owner_id = ? AND owner_type = ? AND released_at IS NULL AND id <> ?", ownerID, ownerType, latest.ID).UpdateColumn("released_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// FindReleasedMediaObjects lists the files released before the time, the oldest first
+func (m *MediaObject) FindReleasedMediaObjects(db *gorm.DB, before time.Time, limit int) (*[]MediaObject, error) {
+	objects := []MediaObject{}
+	err := db.Debug().Model(&MediaObject{}).Where("released_at IS NOT NULL AND released_at < ?", before).Order("released_at asc").Limit(limit).Find(&objects).Error
+	if err != nil {
+		return &[]MediaObject{}, err
+	}
+	return &objects, nil
+}
+
+// PostponeMediaObject releases the file again from now, after the storage failed to delete it
+func (m *MediaObject) PostponeMediaObject(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&MediaObject{}).Where("id = ?", m.ID).UpdateColumn("released_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+func (m *MediaObject) DeleteMediaObject(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&MediaObject{}).Where("id = ?", m.ID).Delete(&MediaObject{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
@@ -1,32 +1,67 @@
 package models
 
 import (
+	"encoding/json"
 	"errors"
 	"html"
+	"log"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/lib/pq"
+	"github.com/Mdromi/exp-blog-backend/api/moderation"
+	"github.com/Mdromi/exp-blog-backend/api/storage"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/Mdromi/exp-blog-backend/api/utils/postformator"
 	"gorm.io/gorm"
 )
 
 // Post model represents a post
 type Post struct {
 	gorm.Model
-	Title          string         `gorm:"size:255;not null;unique" json:"title"`
-	PostPermalinks string         `gorm:"size:255" json:"post_permalinks"`
-	Content        string         `gorm:"type:text;not null" json:"content"`
-	AuthorID       uint           `gorm:"not null" json:"author_id"`
-	Author         Profile        `gorm:"foreignKey:AuthorID" json:"author"`
-	Tags           pq.StringArray `gorm:"type:text[]" json:"tags"`
-	Thumbnails     string         `gorm:"size:255" json:"thumbnails"`
-	ReadTime       string         `json:"read_time"`
+	Title            string                 `gorm:"size:255;not null;unique" json:"title"`
+	PostPermalinks   string                 `gorm:"size:255" json:"post_permalinks"`
+	Content          string                 `gorm:"type:text;not null" json:"content"`
+	ContentHTML      string                 `gorm:"type:text" json:"content_html"`   // rendered from Content on every save
+	Rendered         bool                   `gorm:"not null;default:false" json:"-"` // false for the posts saved before the markdown pipeline
+	Excerpt          string                 `gorm:"type:text" json:"excerpt"`
+	TOC              []postformator.Heading `gorm:"serializer:json;type:text" json:"toc"`
+	WordCount        int                    `json:"word_count"`
+	AuthorID         uint                   `gorm:"not null" json:"author_id"`
+	Author           Profile                `gorm:"foreignKey:AuthorID" json:"author"`
+	Tags             []string               `gorm:"-" json:"tags"`
+	TagList          []Tag                  `gorm:"many2many:post_tags" json:"-"`
+	Thumbnails       string                 `gorm:"size:255" json:"thumbnails"`
+	ThumbnailKey     string                 `gorm:"size:255" json:"-"` // an uploaded thumbnail, its url is signed when the post is loaded
+	ReadTime         string                 `json:"read_time"`
+	Status           string                 `gorm:"size:20;not null;default:published;index" json:"status"`
+	PublishAt        *time.Time             `json:"publish_at"`
+	SuspendedAt      *time.Time             `json:"suspended_at"`
+	FlagReason       string                 `gorm:"type:text" json:"flag_reason"` // why the filters held the post for a review
+	ModerateComments *bool                  `json:"moderate_comments"`            // hold the comments of others for approval, null follows the author
+	Reactions        ReactionCounts         `gorm:"embedded" json:"reactions"`
+	Media            []PostMedia            `gorm:"foreignKey:PostID;constraint:OnDelete:CASCADE" json:"media"`
+	EditedBy         uint                   `gorm:"-" json:"-"`                 // the user saving the post, recorded in its revision
+	DeletedBy        uint32                 `json:"-"`                          // the profile that deleted the post, only its author restores what it deleted itself
+	SearchVector     SearchVector           `gorm:"<-:false;->:false" json:"-"` // kept by refreshSearchVectors
+}
+
+// MarshalJSON returns the Markdown as content_markdown next to content, which the existing clients read
+func (p Post) MarshalJSON() ([]byte, error) {
+	type post Post // without the method, so it is not called again
+	return json.Marshal(struct {
+		post
+		ContentMarkdown string `json:"content_markdown"`
+	}{post(p), p.Content})
 }
 
 func (p *Post) Prepare() {
 	// Sanitize and trim strings
 	p.Title = html.EscapeString(strings.TrimSpace(p.Title))
 	p.PostPermalinks = html.EscapeString(strings.TrimSpace(p.PostPermalinks))
-	p.Content = html.EscapeString(strings.TrimSpace(p.Content))
+	// the content is markdown, it is kept as written and only its rendered HTML is sanitized
+	p.Content = strings.TrimSpace(p.Content)
 
 	if p.Tags == nil {
 		p.Tags = make([]string, 0) // Initialize Tags field as an empty string slice
@@ -39,6 +74,19 @@ func (p *Post) Prepare() {
 	if p.ReadTime == "" {
 		p.ReadTime = "" // Initialize ReadTime field as an empty string
 	}
+
+	// posts are published right away unless the author asks for something else
+	p.Status = strings.ToLower(strings.TrimSpace(p.Status))
+	if p.Status == "" {
+		p.Status = PostStatusPublished
+	}
+	now := time.Now()
+	if p.Status == PostStatusScheduled && p.PublishAt != nil && !p.PublishAt.After(now) {
+		p.Status = PostStatusPublished
+	}
+	if p.Status == PostStatusPublished && p.PublishAt == nil {
+		p.PublishAt = &now
+	}
 }
 
 func (p *Post) Validate() map[string]string {
@@ -59,34 +107,119 @@ func (p *Post) Validate() map[string]string {
 		err = errors.New("Required Author")
 		errorMessages["Required_author"] = err.Error()
 	}
+
+	if !IsValidPostStatus(p.Status) {
+		err = errors.New("Status should be draft, scheduled, published, archived or review")
+		errorMessages["Invalid_status"] = err.Error()
+	}
+	if p.Status == PostStatusScheduled && p.PublishAt == nil {
+		err = errors.New("Required Publish Time")
+		errorMessages["Required_publish_at"] = err.Error()
+	}
 	return errorMessages
 }
 
+// defaultExcerptWords is the length of the post excerpts, it can be changed with POST_EXCERPT_WORDS
+const defaultExcerptWords = 50
+
+// ExcerptWords is the number of words kept in the excerpt of a post
+func ExcerptWords() int {
+	value := os.Getenv("POST_EXCERPT_WORDS")
+	if value == "" {
+		return defaultExcerptWords
+	}
+	words, err := strconv.Atoi(value)
+	if err != nil || words <= 0 {
+		log.Printf("invalid POST_EXCERPT_WORDS %q, using %d", value, defaultExcerptWords)
+		return defaultExcerptWords
+	}
+	return words
+}
+
+// renderContent derives the HTML, the table of contents, the excerpt, the word count and the reading time from the markdown
+func (p *Post) renderContent() {
+	p.ContentHTML = postformator.RenderMarkdown(p.Content)
+	p.Rendered = true
+	summary := postformator.Summarize(p.Content, ExcerptWords())
+	p.TOC = summary.TOC
+	p.Excerpt = summary.Excerpt
+	p.WordCount = summary.WordCount
+	p.ReadTime = summary.ReadTime()
+}
+
+// AfterCreate makes the post searchable, its tags are added to the search when they are synced
+func (p *Post) AfterCreate(tx *gorm.DB) error {
+	return refreshSearchVectors(tx, "id = ?", p.ID)
+}
+
+// AfterFind exposes the names of the preloaded tags as the post tags, and the url of an uploaded thumbnail
+func (p *Post) AfterFind(tx *gorm.DB) (err error) {
+	if p.TagList != nil {
+		p.Tags = tagNames(p.TagList)
+	}
+	if p.ThumbnailKey != "" {
+		p.Thumbnails = storage.Signer.URL(p.ThumbnailKey)
+	}
+	return nil
+}
+
 func (p *Post) SavePost(db *gorm.DB) (*Post, error) {
 	var err error
-	err = db.Debug().Model(&Post{}).Create(&p).Preload("Author").Error
+	err = db.Debug().Transaction(func(tx *gorm.DB) error {
+		slug, err := UniquePostSlug(tx, p.Title, 0)
+		if err != nil {
+			return err
+		}
+		p.PostPermalinks = slug
+		p.renderContent()
+		err = p.screenContent()
+		if err != nil {
+			return err
+		}
+
+		p.Reactions = ReactionCounts{} // the counters only change with the reactions
+		err = tx.Model(&Post{}).Omit("TagList").Create(&p).Error
+		if err != nil {
+			return err
+		}
+		err = p.saveSlug(tx)
+		if err != nil {
+			return err
+		}
+		err = p.SyncTags(tx, p.Tags)
+		if err != nil {
+			return err
+		}
+		return p.saveRevision(tx)
+	})
 	if err != nil {
 		return &Post{}, err
 	}
+	moderation.Saved(p.moderationContent())
 
 	return p, nil
 }
 
-func (p *Post) FindAllPosts(db *gorm.DB) (*[]Post, error) {
-	var err error
+// FindAllPosts lists the published posts, and the other posts of the viewer
+func (p *Post) FindAllPosts(db *gorm.DB, viewerID uint, pg *pagination.Pagination) (*[]Post, error) {
 	posts := []Post{}
 
-	err = db.Debug().Model(&Post{}).Limit(100).Order("created_at desc").Preload("Author").Find(&posts).Error
+	query, err := pg.Paginate(db.Debug().Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(viewerID)), "")
+	if err != nil {
+		return &[]Post{}, err
+	}
+	err = query.Preload("Author").Preload("TagList").Find(&posts).Error
 	if err != nil {
 		return &[]Post{}, err
 	}
+	pg.SetNextCursor(posts)
 
 	return &posts, nil
 }
 
 func (p *Post) FindPostById(db *gorm.DB, pid uint64) (*Post, error) {
 	var err error
-	err = db.Debug().Model(&Post{}).Where("id = ?", pid).Take(&p).Error
+	err = db.Debug().Model(&Post{}).Scopes(NotSuspended).Preload("TagList").Preload("Media").Where("id = ?", pid).Take(&p).Error
 	if err != nil {
 		return &Post{}, err
 	}
@@ -102,11 +235,55 @@ func (p *Post) FindPostById(db *gorm.DB, pid uint64) (*Post, error) {
 func (p *Post) UpdateAPost(db *gorm.DB) (*Post, error) {
 	var err error
 
-	err = db.Debug().Model(&Post{}).Where("id = ?", p.ID).Updates(Post{Title: p.Title, Content: p.Content, PostPermalinks: p.PostPermalinks, Tags: p.Tags, Thumbnails: p.Thumbnails, ReadTime: p.ReadTime}).Error
+	err = db.Debug().Transaction(func(tx *gorm.DB) error {
+		if p.ID != 0 {
+			err := p.saveFirstRevision(tx)
+			if err != nil {
+				return err
+			}
+
+			// a new title gets a new permalink, the old one stays in the history
+			slug, err := UniquePostSlug(tx, p.Title, p.ID)
+			if err != nil {
+				return err
+			}
+			p.PostPermalinks = slug
+		}
+		p.renderContent()
+		err := p.screenContent()
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&Post{}).Where("id = ?", p.ID).Updates(Post{Title: p.Title, Content: p.Content, PostPermalinks: p.PostPermalinks, Thumbnails: p.Thumbnails, Status: p.Status, PublishAt: p.PublishAt}).Error
+		if err != nil || p.ID == 0 {
+			return err
+		}
+		// what is derived from the content is written even when empty, a content losing its headings loses its toc,
+		// and a null moderate_comments makes the post follow its author again
+		err = tx.Model(&Post{}).Where("id = ?", p.ID).Select("content_html", "rendered", "excerpt", "toc", "word_count", "read_time", "moderate_comments").Updates(Post{ContentHTML: p.ContentHTML, Rendered: p.Rendered, Excerpt: p.Excerpt, TOC: p.TOC, WordCount: p.WordCount, ReadTime: p.ReadTime, ModerateComments: p.ModerateComments}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Model(&Post{}).Where("id = ?", p.ID).UpdateColumn("flag_reason", p.FlagReason).Error
+		if err != nil {
+			return err
+		}
+		err = p.saveSlug(tx)
+		if err != nil {
+			return err
+		}
+		err = p.SyncTags(tx, p.Tags)
+		if err != nil {
+			return err
+		}
+		return p.saveRevision(tx)
+	})
 
 	if err != nil {
 		return &Post{}, err
 	}
+	moderation.Saved(p.moderationContent())
 	if p.ID != 0 {
 		err = db.Debug().Model(&Profile{}).Where("user_id = ?", p.AuthorID).Take(&p.Author).Error
 		if err != nil {
@@ -116,28 +293,35 @@ func (p *Post) UpdateAPost(db *gorm.DB) (*Post, error) {
 	return p, nil
 }
 func (p *Post) DeleteAPost(db *gorm.DB) (int64, error) {
+	err := markDeletedBy(db, &Post{}, p.DeletedBy, "id = ?", p.ID)
+	if err != nil {
+		return 0, err
+	}
 	db = db.Debug().Model(&Post{}).Where("id = ?", p.ID).Take(&Post{}).Delete(&Post{})
 	if db.Error != nil {
 		return 0, db.Error
 	}
 	return db.RowsAffected, nil
 }
-func (p *Post) FindUserPosts(db *gorm.DB, uid uint32) (*[]Post, error) {
-	var err error
+func (p *Post) FindUserPosts(db *gorm.DB, uid uint32, viewerID uint, pg *pagination.Pagination) (*[]Post, error) {
 	posts := []Post{}
-	err = db.Debug().Model(&Post{}).Where("author_id = ?", uid).Limit(100).Order("created_at desc").Find(&posts).Error
-
+	query, err := pg.Paginate(db.Debug().Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(viewerID)).Where("author_id = ?", uid), "")
+	if err != nil {
+		return &[]Post{}, err
+	}
+	err = query.Preload("TagList").Find(&posts).Error
 	if err != nil {
 		return &[]Post{}, err
 	}
 
 	if len(posts) > 0 {
-		for _, post := range posts {
-			err := db.Debug().Model(&Profile{}).Where("id = ?", post.AuthorID).Take(&post.Author).Error
+		for i := range posts {
+			err := db.Debug().Model(&Profile{}).Where("id = ?", posts[i].AuthorID).Take(&posts[i].Author).Error
 			if err != nil {
 				return &[]Post{}, err
 			}
 		}
+		pg.SetNextCursor(posts)
 	}
 	return &posts, nil
 }
@@ -151,3 +335,38 @@ func (c *Post) DeleteUserPosts(db *gorm.DB, uid uint32) (int64, error) {
 	}
 	return db.RowsAffected, nil
 }
+
+// RenderLegacyPosts renders the posts saved before the markdown pipeline, once, they are marked as rendered.
+// The legacy escaper stored their content HTML escaped and left them without HTML, only these are unescaped back
+// to what the author wrote first. The ones saved with HTML but no excerpt are only summarized.
+func RenderLegacyPosts(db *gorm.DB) error {
+	posts := []Post{}
+	err := db.Debug().Unscoped().Model(&Post{}).Where("rendered = ?", false).Find(&posts).Error
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		if post.ContentHTML == "" {
+			post.Content = html.UnescapeString(post.Content)
+		}
+		post.renderContent()
+		err = db.Debug().Unscoped().Model(&Post{}).Where("id = ?", post.ID).UpdateColumns(Post{
+			Content:     post.Content,
+			ContentHTML: post.ContentHTML,
+			Rendered:    post.Rendered,
+			Excerpt:     post.Excerpt,
+			TOC:         post.TOC,
+			WordCount:   post.WordCount,
+			ReadTime:    post.ReadTime,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = refreshSearchVectors(db, "id = ?", post.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
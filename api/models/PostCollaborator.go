@@ -0,0 +1,166 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Roles of a profile on a post. The author of a post is always one of its owners, owners manage the collaborators
+// and can delete the post, editors can update it and viewers can read it before it is published.
+const (
+	CollaboratorOwner  = "owner"
+	CollaboratorEditor = "editor"
+	CollaboratorViewer = "viewer"
+)
+
+var (
+	ErrCollaboratorExists  = errors.New("this profile is already invited to the post")
+	ErrCollaboratorAuthor  = errors.New("the author of a post cannot be invited to it")
+	ErrInvalidCollaborator = errors.New("role should be owner, editor or viewer")
+)
+
+// PostCollaborator is a profile invited to work on a post, the invitation counts once it is accepted
+type PostCollaborator struct {
+	ID         uint       `gorm:"primarykey" json:"id"`
+	PostID     uint       `gorm:"not null;uniqueIndex:idx_post_collaborator" json:"post_id"`
+	ProfileID  uint       `gorm:"not null;uniqueIndex:idx_post_collaborator;index" json:"profile_id"`
+	Profile    Profile    `gorm:"foreignKey:ProfileID" json:"profile"`
+	Role       string     `gorm:"size:20;not null" json:"role"`
+	InvitedBy  uint       `gorm:"not null" json:"invited_by"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func IsValidCollaboratorRole(role string) bool {
+	switch role {
+	case CollaboratorOwner, CollaboratorEditor, CollaboratorViewer:
+		return true
+	}
+	return false
+}
+
+// CanEditPost tells if the role lets a profile update the post
+func CanEditPost(role string) bool {
+	return role == CollaboratorOwner || role == CollaboratorEditor
+}
+
+// CanManagePost tells if the role lets a profile delete the post and manage its collaborators
+func CanManagePost(role string) bool {
+	return role == CollaboratorOwner
+}
+
+// RoleOf returns the role of the profile on the post, an empty role when it is not working on it
+func (p *Post) RoleOf(db *gorm.DB, profileID uint) (string, error) {
+	if profileID == 0 {
+		return "", nil
+	}
+	if p.AuthorID == profileID {
+		return CollaboratorOwner, nil
+	}
+
+	collaborator := PostCollaborator{}
+	err := db.Debug().Model(&PostCollaborator{}).Where("post_id = ? AND profile_id = ? AND accepted_at IS NOT NULL", p.ID, profileID).Take(&collaborator).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return collaborator.Role, nil
+}
+
+// InviteCollaborator invites the profile to the post, the profile has no role on it until it accepts
+func (c *PostCollaborator) InviteCollaborator(db *gorm.DB, post *Post) (*PostCollaborator, error) {
+	if !IsValidCollaboratorRole(c.Role) {
+		return &PostCollaborator{}, ErrInvalidCollaborator
+	}
+	if c.ProfileID == post.AuthorID {
+		return &PostCollaborator{}, ErrCollaboratorAuthor
+	}
+
+	var count int64
+	err := db.Debug().Model(&PostCollaborator{}).Where("post_id = ? AND profile_id = ?", post.ID, c.ProfileID).Count(&count).Error
+	if err != nil {
+		return &PostCollaborator{}, err
+	}
+	if count > 0 {
+		return &PostCollaborator{}, ErrCollaboratorExists
+	}
+
+	c.PostID = post.ID
+	c.AcceptedAt = nil
+	err = db.Debug().Omit("Profile").Create(&c).Error
+	if err != nil {
+		return &PostCollaborator{}, err
+	}
+	return c, nil
+}
+
+// FindPostCollaborators lists the profiles invited to the post, accepted or not
+func (c *PostCollaborator) FindPostCollaborators(db *gorm.DB, postID uint64) (*[]PostCollaborator, error) {
+	collaborators := []PostCollaborator{}
+	err := db.Debug().Model(&PostCollaborator{}).Preload("Profile").Where("post_id = ?", postID).Order("id asc").Find(&collaborators).Error
+	if err != nil {
+		return &[]PostCollaborator{}, err
+	}
+	return &collaborators, nil
+}
+
+// FindProfileInvitations lists the invitations the profile has not accepted yet
+func (c *PostCollaborator) FindProfileInvitations(db *gorm.DB, profileID uint32) (*[]PostCollaborator, error) {
+	invitations := []PostCollaborator{}
+	err := db.Debug().Model(&PostCollaborator{}).Where("profile_id = ? AND accepted_at IS NULL", profileID).Order("id desc").Find(&invitations).Error
+	if err != nil {
+		return &[]PostCollaborator{}, err
+	}
+	return &invitations, nil
+}
+
+// FindInvitation returns the invitation of the profile with this id
+func (c *PostCollaborator) FindInvitation(db *gorm.DB, id uint64, profileID uint32) (*PostCollaborator, error) {
+	err := db.Debug().Model(&PostCollaborator{}).Where("id = ? AND profile_id = ?", id, profileID).Take(&c).Error
+	if err != nil {
+		return &PostCollaborator{}, err
+	}
+	return c, nil
+}
+
+func (c *PostCollaborator) AcceptInvitation(db *gorm.DB) (*PostCollaborator, error) {
+	now := time.Now()
+	err := db.Debug().Model(&PostCollaborator{}).Where("id = ?", c.ID).UpdateColumn("accepted_at", now).Error
+	if err != nil {
+		return &PostCollaborator{}, err
+	}
+	c.AcceptedAt = &now
+	return c, nil
+}
+
+// RemoveCollaborator takes the profile off the post, it also declines a pending invitation
+func (c *PostCollaborator) RemoveCollaborator(db *gorm.DB, postID uint64, profileID uint64) (int64, error) {
+	db = db.Debug().Model(&PostCollaborator{}).Where("post_id = ? AND profile_id = ?", postID, profileID).Delete(&PostCollaborator{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// DeletePostCollaborators removes the collaborators of a post deleted for good
+func (p *Post) DeletePostCollaborators(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&PostCollaborator{}).Where("post_id = ?", p.ID).Delete(&PostCollaborator{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// DeleteProfileCollaborations removes the invitations of a profile deleted for good
+func (c *PostCollaborator) DeleteProfileCollaborations(db *gorm.DB, profileID uint32) (int64, error) {
+	db = db.Debug().Model(&PostCollaborator{}).Where("profile_id = ?", profileID).Delete(&PostCollaborator{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
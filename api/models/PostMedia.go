@@ -0,0 +1,85 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/storage"
+	"gorm.io/gorm"
+)
+
+// MediaVariant is one stored version of an uploaded image
+type MediaVariant struct {
+	Name        string `json:"name"`
+	Key         string `json:"key"` // where it is kept in the storage backend
+	URL         string `json:"url"` // signed again every time the media is loaded
+	ContentType string `json:"content_type"`
+	Width       int    `json:"width"`
+	Height      int    `json:"height"`
+	Size        int    `json:"size"`
+}
+
+// PostMedia is an image uploaded for a post, stored in several sizes and as WebP
+type PostMedia struct {
+	ID           uint           `gorm:"primarykey" json:"id"`
+	PostID       uint           `gorm:"not null;index" json:"post_id"`
+	ProfileID    uint           `gorm:"not null" json:"profile_id"` // the profile that uploaded it
+	OriginalName string         `gorm:"size:255" json:"original_name"`
+	ContentType  string         `gorm:"size:50;not null" json:"content_type"`
+	Width        int            `json:"width"`
+	Height       int            `json:"height"`
+	Variants     []MediaVariant `gorm:"serializer:json;type:text" json:"variants"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// Variant returns the version with this name, nil when there is none
+func (m *PostMedia) Variant(name string) *MediaVariant {
+	for i := range m.Variants {
+		if m.Variants[i].Name == name {
+			return &m.Variants[i]
+		}
+	}
+	return nil
+}
+
+// AfterFind gives every version a fresh url, the media of a post are private until they are downloaded through a signed url
+func (m *PostMedia) AfterFind(tx *gorm.DB) (err error) {
+	m.signURLs()
+	return nil
+}
+
+func (m *PostMedia) signURLs() {
+	for i, variant := range m.Variants {
+		if storage.IsPrivate(variant.Key) {
+			m.Variants[i].URL = storage.Signer.URL(variant.Key)
+		}
+	}
+}
+
+func (m *PostMedia) SavePostMedia(db *gorm.DB) (*PostMedia, error) {
+	err := db.Debug().Create(&m).Error
+	if err != nil {
+		return &PostMedia{}, err
+	}
+	m.signURLs()
+	return m, nil
+}
+
+// SetThumbnail makes the stored object the thumbnail of the post, it takes precedence over the thumbnails set by the clients
+func (p *Post) SetThumbnail(db *gorm.DB, key string) error {
+	err := db.Debug().Model(&Post{}).Where("id = ?", p.ID).UpdateColumn("thumbnail_key", key).Error
+	if err != nil {
+		return err
+	}
+	p.ThumbnailKey = key
+	p.Thumbnails = storage.Signer.URL(key)
+	return nil
+}
+
+// DeletePostMedia removes the media records of a post deleted for good
+func (p *Post) DeletePostMedia(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&PostMedia{}).Where("post_id = ?", p.ID).Delete(&PostMedia{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
@@ -0,0 +1,115 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/Mdromi/exp-blog-backend/api/utils/textdiff"
+	"gorm.io/gorm"
+)
+
+// diffContextLines is the number of unchanged lines shown around every change of a revision diff
+const diffContextLines = 3
+
+// PostRevision is the state of a post after one of its saves, revisions are numbered from 1 for every post
+type PostRevision struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_post_revision" json:"post_id"`
+	Revision  int       `gorm:"not null;uniqueIndex:idx_post_revision" json:"revision"`
+	EditorID  uint      `gorm:"not null" json:"editor_id"`
+	Title     string    `gorm:"size:255;not null" json:"title"`
+	Content   string    `gorm:"type:text;not null" json:"content"`
+	Tags      []string  `gorm:"serializer:json;type:text" json:"tags"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// saveRevision records the current state of the post as its next revision
+func (p *Post) saveRevision(db *gorm.DB) error {
+	var last int
+	err := db.Debug().Model(&PostRevision{}).Where("post_id = ?", p.ID).Select("COALESCE(MAX(revision), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+
+	editorID := p.EditedBy
+	if editorID == 0 {
+		editorID = p.AuthorID
+	}
+	tags := p.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	revision := PostRevision{
+		PostID:   p.ID,
+		Revision: last + 1,
+		EditorID: editorID,
+		Title:    p.Title,
+		Content:  p.Content,
+		Tags:     tags,
+	}
+	return db.Debug().Create(&revision).Error
+}
+
+// saveFirstRevision keeps the stored state of a post created before the revisions existed, so its first update can be undone
+func (p *Post) saveFirstRevision(db *gorm.DB) error {
+	var count int64
+	err := db.Debug().Model(&PostRevision{}).Where("post_id = ?", p.ID).Count(&count).Error
+	if err != nil || count > 0 {
+		return err
+	}
+
+	stored := Post{}
+	err = db.Debug().Model(&Post{}).Preload("TagList").Where("id = ?", p.ID).Take(&stored).Error
+	if err != nil {
+		return err
+	}
+	return stored.saveRevision(db)
+}
+
+func (r *PostRevision) FindPostRevisions(db *gorm.DB, postID uint64, pg *pagination.Pagination) (*[]PostRevision, error) {
+	revisions := []PostRevision{}
+	query, err := pg.Paginate(db.Debug().Model(&PostRevision{}).Where("post_id = ?", postID), "")
+	if err != nil {
+		return &[]PostRevision{}, err
+	}
+	err = query.Find(&revisions).Error
+	if err != nil {
+		return &[]PostRevision{}, err
+	}
+	pg.SetNextCursor(revisions)
+	return &revisions, nil
+}
+
+func (r *PostRevision) FindPostRevision(db *gorm.DB, postID uint64, revision int) (*PostRevision, error) {
+	err := db.Debug().Model(&PostRevision{}).Where("post_id = ? AND revision = ?", postID, revision).Take(&r).Error
+	if err != nil {
+		return &PostRevision{}, err
+	}
+	return r, nil
+}
+
+// Diff returns the unified diff going from this revision to the other one
+func (r *PostRevision) Diff(other *PostRevision) string {
+	return textdiff.Unified(
+		fmt.Sprintf("revision %d", r.Revision),
+		fmt.Sprintf("revision %d", other.Revision),
+		r.text(), other.text(), diffContextLines,
+	)
+}
+
+// text lays the revision out as one document, so a single diff covers the title, the tags and the content
+func (r *PostRevision) text() string {
+	return "Title: " + r.Title + "\nTags: " + strings.Join(r.Tags, ", ") + "\n\n" + r.Content
+}
+
+// DeletePostRevisions removes the history of a post deleted for good
+func (p *Post) DeletePostRevisions(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&PostRevision{}).Where("post_id = ?", p.ID).Delete(&PostRevision{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"html"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/Mdromi/exp-blog-backend/api/utils/postformator"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// postSearchVector weights the title above the tags and the tags above the content, it is stored in the
+// search_vector column of the posts
+const postSearchVector = `(setweight(to_tsvector('english', coalesce(posts.title, '')), 'A') || ` +
+	`setweight(to_tsvector('english', coalesce((SELECT string_agg(tags.name, ' ') FROM tags JOIN post_tags ON post_tags.tag_id = tags.id WHERE post_tags.post_id = posts.id), '')), 'B') || ` +
+	`setweight(to_tsvector('english', coalesce(posts.content, '')), 'C'))`
+
+const (
+	// the headlines are raw text, they are marked with the sentinels and escaped before the mark tags go in
+	titleHeadlineOptions   = `StartSel="` + postformator.SentinelStart + `", StopSel="` + postformator.SentinelStop + `", HighlightAll=true`
+	contentHeadlineOptions = `StartSel="` + postformator.SentinelStart + `", StopSel="` + postformator.SentinelStop + `", MaxWords=35, MinWords=15`
+
+	snippetWords = 35
+)
+
+// SearchVector is the column the posts are searched with, a tsvector with a GIN index on postgres. The other
+// databases search with LIKE and leave it empty.
+type SearchVector string
+
+func (SearchVector) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "tsvector"
+	}
+	return "text"
+}
+
+// refreshSearchVectors computes the search column of the posts matching the condition again, whenever their title,
+// content or tags change
+func refreshSearchVectors(db *gorm.DB, query string, args ...interface{}) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return db.Session(&gorm.Session{NewDB: true}).Debug().Exec("UPDATE posts SET search_vector = "+postSearchVector+" WHERE "+query, args...).Error
+}
+
+// MigratePostSearch indexes the search column of the posts and fills it for the posts saved before it
+func MigratePostSearch(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	err := db.Debug().Exec("CREATE INDEX IF NOT EXISTS idx_posts_search_vector ON posts USING GIN (search_vector)").Error
+	if err != nil {
+		return err
+	}
+	return refreshSearchVectors(db, "search_vector IS NULL")
+}
+
+// PostSearch is a full text query over the posts and the filters it is combined with
+type PostSearch struct {
+	Query    string
+	ViewerID uint
+	AuthorID uint
+	Tag      string
+	From     *time.Time
+	To       *time.Time
+}
+
+// PostSearchResult is a post matched by a search, with its rank and the highlighted title and content
+type PostSearchResult struct {
+	Post
+	Rank             float64 `gorm:"column:search_rank;->" json:"rank"`
+	TitleHighlight   string  `gorm:"column:title_highlight;->" json:"title_highlight"`
+	ContentHighlight string  `gorm:"column:content_highlight;->" json:"content_highlight"`
+}
+
+// SearchPosts ranks the posts matching the query, postgres uses its text search and the other databases a LIKE match
+func (p *Post) SearchPosts(db *gorm.DB, search PostSearch, pg *pagination.Pagination) (*[]PostSearchResult, error) {
+	results := []PostSearchResult{}
+
+	terms := postformator.SearchTerms(search.Query)
+	if len(terms) == 0 {
+		return &results, nil
+	}
+
+	query := db.Debug().Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(search.ViewerID))
+	if search.AuthorID != 0 {
+		query = query.Where("posts.author_id = ?", search.AuthorID)
+	}
+	if search.Tag != "" {
+		query = query.Where("posts.id IN (?)", db.Table("post_tags").Select("post_tags.post_id").Joins("JOIN tags ON tags.id = post_tags.tag_id").Where("tags.slug = ?", postformator.CreateTagSlug(search.Tag)))
+	}
+	if search.From != nil {
+		query = query.Where("posts.created_at >= ?", *search.From)
+	}
+	if search.To != nil {
+		query = query.Where("posts.created_at <= ?", *search.To)
+	}
+
+	postgres := db.Dialector.Name() == "postgres"
+	var selectQuery string
+	var selectArgs []interface{}
+	if postgres {
+		tsQuery := "plainto_tsquery('english', ?)"
+		query = query.Where("posts.search_vector @@ "+tsQuery, search.Query)
+
+		selectQuery = "posts.*, ts_rank(posts.search_vector, " + tsQuery + ") AS search_rank, " +
+			"ts_headline('english', posts.title, " + tsQuery + ", ?) AS title_highlight, " +
+			"ts_headline('english', posts.content, " + tsQuery + ", ?) AS content_highlight"
+		selectArgs = []interface{}{search.Query, search.Query, titleHeadlineOptions, search.Query, contentHeadlineOptions}
+	} else {
+		// every term has to be found in the title, the tags or the content, and is ranked in that order
+		taggedWith := "posts.id IN (SELECT post_tags.post_id FROM post_tags JOIN tags ON tags.id = post_tags.tag_id WHERE LOWER(tags.name) LIKE ?)"
+		rankParts := make([]string, len(terms))
+		for i, term := range terms {
+			pattern := "%" + escapeLike(term) + "%"
+			query = query.Where("(LOWER(posts.title) LIKE ? OR "+taggedWith+" OR LOWER(posts.content) LIKE ?)", pattern, pattern, pattern)
+
+			rankParts[i] = "(CASE WHEN LOWER(posts.title) LIKE ? THEN 3 ELSE 0 END) + (CASE WHEN " + taggedWith + " THEN 2 ELSE 0 END) + (CASE WHEN LOWER(posts.content) LIKE ? THEN 1 ELSE 0 END)"
+			selectArgs = append(selectArgs, pattern, pattern, pattern)
+		}
+		selectQuery = "posts.*, " + strings.Join(rankParts, " + ") + " AS search_rank"
+	}
+
+	query, err := pg.PaginateOrdered(query, "search_rank desc, posts.id desc")
+	if err != nil {
+		return &[]PostSearchResult{}, err
+	}
+	err = query.Select(selectQuery, selectArgs...).Preload("TagList").Find(&results).Error
+	if err != nil {
+		return &[]PostSearchResult{}, err
+	}
+
+	for i := range results {
+		// the titles are saved escaped, they are unescaped so they are not escaped twice
+		if postgres {
+			results[i].TitleHighlight = postformator.EscapeHighlight(html.UnescapeString(results[i].TitleHighlight))
+			results[i].ContentHighlight = postformator.EscapeHighlight(results[i].ContentHighlight)
+		} else {
+			results[i].TitleHighlight = postformator.Highlight(html.UnescapeString(results[i].Title), terms, 0)
+			results[i].ContentHighlight = postformator.Highlight(results[i].Content, terms, snippetWords)
+		}
+		err = db.Debug().Model(&Profile{}).Where("id = ?", results[i].AuthorID).Take(&results[i].Author).Error
+		if err != nil {
+			return &[]PostSearchResult{}, err
+		}
+	}
+	pg.SetNextCursor(results)
+	return &results, nil
+}
+
+// escapeLike makes the LIKE wildcards of a search term match literally
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(term)
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/postformator"
+	"gorm.io/gorm"
+)
+
+// PostSlug keeps every permalink a post ever had, so links to a renamed post keep resolving.
+// The current one is also stored on the post as PostPermalinks.
+type PostSlug struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	PostID    uint      `gorm:"not null;index" json:"post_id"`
+	Slug      string    `gorm:"size:255;not null;uniqueIndex" json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UniquePostSlug builds the permalink of a title, suffixed with -2, -3... when another post already uses it.
+// Slugs the post itself had before are free to take back.
+func UniquePostSlug(db *gorm.DB, title string, postID uint) (string, error) {
+	base := postformator.CreatePostPermalinks(html.UnescapeString(title))
+	if base == "" {
+		base = "post"
+	}
+
+	taken := []string{}
+	err := db.Debug().Model(&PostSlug{}).Where("(slug = ? OR slug LIKE ?) AND post_id <> ?", base, escapeLike(base)+"-%", postID).Pluck("slug", &taken).Error
+	if err != nil {
+		return "", err
+	}
+	used := map[string]bool{}
+	for _, slug := range taken {
+		used[slug] = true
+	}
+
+	slug := base
+	for i := 2; used[slug]; i++ {
+		slug = base + "-" + strconv.Itoa(i)
+	}
+	return slug, nil
+}
+
+// saveSlug stores the post permalink in the history, it is a no-op when the post already had it
+func (p *Post) saveSlug(db *gorm.DB) error {
+	postSlug := PostSlug{}
+	return db.Debug().Where(PostSlug{PostID: p.ID, Slug: p.PostPermalinks}).FirstOrCreate(&postSlug).Error
+}
+
+// FindPostBySlug returns the post that has or had this permalink
+func (p *Post) FindPostBySlug(db *gorm.DB, slug string) (*Post, error) {
+	postSlug := PostSlug{}
+	err := db.Debug().Model(&PostSlug{}).Where("slug = ?", strings.ToLower(slug)).Take(&postSlug).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	return p.FindPostById(db, uint64(postSlug.PostID))
+}
+
+// DeletePostSlugs frees the permalinks of a post deleted for good
+func (p *Post) DeletePostSlugs(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&PostSlug{}).Where("post_id = ?", p.ID).Delete(&PostSlug{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// BackfillPostSlugs gives a unique permalink to the posts created before the slug history existed,
+// the oldest post keeps the plain slug when two of them collide
+func BackfillPostSlugs(db *gorm.DB) error {
+	posts := []Post{}
+	err := db.Debug().Unscoped().Model(&Post{}).
+		Where("id NOT IN (?)", db.Model(&PostSlug{}).Select("post_id")).
+		Order("id asc").Find(&posts).Error
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		slug, err := UniquePostSlug(db, posts[i].Title, posts[i].ID)
+		if err != nil {
+			return err
+		}
+		posts[i].PostPermalinks = slug
+		err = db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Unscoped().Model(&Post{}).Where("id = ?", posts[i].ID).UpdateColumn("post_permalinks", slug).Error
+			if err != nil {
+				return err
+			}
+			return posts[i].saveSlug(tx)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
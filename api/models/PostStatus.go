@@ -0,0 +1,125 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/moderation"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+// States of a post, only published posts are shown to readers. A post the filters flag when it is published or
+// scheduled waits in review for an editor.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+	PostStatusArchived  = "archived"
+	PostStatusReview    = "review"
+)
+
+func IsValidPostStatus(status string) bool {
+	switch status {
+	case PostStatusDraft, PostStatusScheduled, PostStatusPublished, PostStatusArchived, PostStatusReview:
+		return true
+	}
+	return false
+}
+
+// IsRequestablePostStatus tells if a client can ask for the status, only the filters put a post in review
+func IsRequestablePostStatus(status string) bool {
+	return strings.ToLower(strings.TrimSpace(status)) != PostStatusReview
+}
+
+// PostVisibleTo keeps the published posts, and every post of the viewer when it is their own or they collaborate on it.
+// viewerID is 0 for an anonymous request.
+func PostVisibleTo(viewerID uint) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewerID == 0 {
+			return db.Where("posts.status = ?", PostStatusPublished)
+		}
+		return db.Where("(posts.status = ? OR posts.author_id = ? OR posts.id IN (SELECT post_id FROM post_collaborators WHERE profile_id = ? AND accepted_at IS NOT NULL))", PostStatusPublished, viewerID, viewerID)
+	}
+}
+
+// VisibleTo tells if the viewer can read the post, see PostVisibleTo
+func (p *Post) VisibleTo(db *gorm.DB, viewerID uint) (bool, error) {
+	if p.Status == PostStatusPublished {
+		return true, nil
+	}
+	role, err := p.RoleOf(db, viewerID)
+	if err != nil {
+		return false, err
+	}
+	return role != "", nil
+}
+
+// PublishDuePosts publishes the scheduled posts whose publish time has come
+func PublishDuePosts(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&Post{}).
+		Where("status = ? AND publish_at <= ?", PostStatusScheduled, time.Now()).
+		UpdateColumn("status", PostStatusPublished)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+func (p *Post) moderationContent() moderation.Content {
+	return moderation.Content{Kind: moderation.KindPost, ID: uint64(p.ID), AuthorID: uint32(p.AuthorID), Text: p.Title + "\n" + p.Content}
+}
+
+// screenContent runs the filters on the post, a flagged post going out to the readers is held in review instead
+func (p *Post) screenContent() error {
+	var err error
+	p.FlagReason, err = moderation.Check(p.moderationContent())
+	if err != nil {
+		return err
+	}
+	if p.FlagReason != "" && (p.Status == PostStatusPublished || p.Status == PostStatusScheduled) {
+		p.Status = PostStatusReview
+	}
+	return nil
+}
+
+// FindPostsInReview pages through the posts the filters held for the editors
+func FindPostsInReview(db *gorm.DB, pg *pagination.Pagination) (*[]Post, error) {
+	posts := []Post{}
+	query, err := pg.Paginate(db.Debug().Model(&Post{}).Scopes(NotSuspended).Where("status = ?", PostStatusReview), "")
+	if err != nil {
+		return &[]Post{}, err
+	}
+	err = query.Preload("Author").Find(&posts).Error
+	if err != nil {
+		return &[]Post{}, err
+	}
+	pg.SetNextCursor(posts)
+	return &posts, nil
+}
+
+// EndReview publishes the post held in review, or schedules it when its publish time is still to come, or sends it
+// back to its author as a draft when it is rejected
+func (p *Post) EndReview(db *gorm.DB, approve bool) (*Post, error) {
+	p.Status = PostStatusDraft
+	if approve {
+		p.Status = PostStatusPublished
+		if p.PublishAt != nil && p.PublishAt.After(time.Now()) {
+			p.Status = PostStatusScheduled
+		}
+		if p.PublishAt == nil {
+			now := time.Now()
+			p.PublishAt = &now
+		}
+	}
+	err := db.Debug().Model(&Post{}).Where("id = ?", p.ID).UpdateColumns(
+		map[string]interface{}{
+			"status":     p.Status,
+			"publish_at": p.PublishAt,
+		},
+	).Error
+	if err != nil {
+		return &Post{}, err
+	}
+	return p, nil
+}
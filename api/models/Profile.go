@@ -5,21 +5,25 @@ import (
 	"fmt"
 	"html"
 	"strings"
+	"time"
 
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"gorm.io/gorm"
 )
 
 // Profile model represents user's profile details
 type Profile struct {
 	gorm.Model
-	UserID      uint        `gorm:"not null" json:"user_id"`
-	Name        string      `gorm:"type:varchar(50);not null" json:"name" validate:"min=2,max=50"`
-	Title       string      `gorm:"type:varchar(100);not null" json:"title" validate:"max=100"`
-	Bio         string      `gorm:"type:text;not null" json:"bio" validate:"max=500"`
-	ProfilePic  string      `gorm:"type:varchar(255)" json:"profile_pic"`
-	SocialLinks *SocialLink `json:"social_links"`
-	Username    string      `gorm:"type:varchar(50)" json:"username"`
-	CoverPic    string      `gorm:"type:varchar(255)" json:"cover_pic"`
+	UserID           uint        `gorm:"not null" json:"user_id"`
+	Name             string      `gorm:"type:varchar(50);not null" json:"name" validate:"min=2,max=50"`
+	Title            string      `gorm:"type:varchar(100);not null" json:"title" validate:"max=100"`
+	Bio              string      `gorm:"type:text;not null" json:"bio" validate:"max=500"`
+	ProfilePic       string      `gorm:"type:varchar(255)" json:"profile_pic"`
+	SocialLinks      *SocialLink `json:"social_links"`
+	Username         string      `gorm:"type:varchar(50)" json:"username"`
+	CoverPic         string      `gorm:"type:varchar(255)" json:"cover_pic"`
+	SuspendedAt      *time.Time  `json:"suspended_at"`
+	ModerateComments *bool       `gorm:"not null;default:false" json:"moderate_comments"` // hold the comments on its posts for approval, left as is when not sent
 }
 
 func (p *Profile) Prepare() {
@@ -115,19 +119,23 @@ func (p *Profile) SaveUserProfile(db *gorm.DB) (*Profile, error) {
 }
 
 // THE ONLY PERSON THAT NEED TO DO THIS IS THE ADMIN, SO I HAVE COMMENTED THE ROUTES, SO SOMEONE ELSE DONT VIES THIS DEATAILS
-func (p *Profile) FindAllUsersProfile(db *gorm.DB) (*[]Profile, error) {
-	var err error
+func (p *Profile) FindAllUsersProfile(db *gorm.DB, pg *pagination.Pagination) (*[]Profile, error) {
 	profiles := []Profile{}
-	err = db.Debug().Model(&Profile{}).Limit(100).Find(&profiles).Error
+	query, err := pg.Paginate(db.Debug().Model(&Profile{}).Scopes(NotSuspended), "")
+	if err != nil {
+		return &[]Profile{}, err
+	}
+	err = query.Find(&profiles).Error
 	if err != nil {
 		return &[]Profile{}, err
 	}
+	pg.SetNextCursor(profiles)
 	return &profiles, nil
 }
 
 func (p *Profile) FindUserProfileByID(db *gorm.DB, pid uint32) (*Profile, error) {
 	var err error
-	err = db.Debug().Model(Profile{}).Where("id = ?", pid).Take(&p).Error
+	err = db.Debug().Model(Profile{}).Scopes(NotSuspended).Where("id = ?", pid).Take(&p).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return &Profile{}, errors.New("profile not found")
@@ -139,14 +147,16 @@ func (p *Profile) FindUserProfileByID(db *gorm.DB, pid uint32) (*Profile, error)
 }
 
 func (p *Profile) UpdateAUserProfile(db *gorm.DB, pid uint32) (*Profile, error) {
-	db = db.Debug().Model(&Profile{}).Where("id = ?", pid).Take(&Profile{}).UpdateColumns(
-		map[string]interface{}{
-			"name":         p.Name,
-			"title":        p.Title,
-			"bio":          p.Bio,
-			"social_links": p.SocialLinks,
-		},
-	)
+	updateColumns := map[string]interface{}{
+		"name":         p.Name,
+		"title":        p.Title,
+		"bio":          p.Bio,
+		"social_links": p.SocialLinks,
+	}
+	if p.ModerateComments != nil {
+		updateColumns["moderate_comments"] = *p.ModerateComments
+	}
+	db = db.Debug().Model(&Profile{}).Where("id = ?", pid).Take(&Profile{}).UpdateColumns(updateColumns)
 
 	if db.Error != nil {
 		return &Profile{}, db.Error
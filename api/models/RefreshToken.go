@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenExpired  = errors.New("refresh token has expired")
+	ErrRefreshTokenRevoked  = errors.New("refresh token has been revoked")
+	ErrRefreshTokenReused   = errors.New("refresh token reuse detected")
+)
+
+// RefreshToken is one refresh token of a login, every rotation creates a new row in the same family
+type RefreshToken struct {
+	gorm.Model
+	UserID     uint32     `gorm:"not null;index" json:"user_id"`
+	TokenHash  string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	FamilyID   string     `gorm:"size:64;not null;index" json:"family_id"`
+	ExpiresAt  time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	ReplacedBy uint       `json:"replaced_by"`
+}
+
+func (rt *RefreshToken) SaveRefreshToken(db *gorm.DB) (*RefreshToken, error) {
+	err := db.Debug().Create(&rt).Error
+	if err != nil {
+		return &RefreshToken{}, err
+	}
+	return rt, nil
+}
+
+func (rt *RefreshToken) FindRefreshTokenByHash(db *gorm.DB, tokenHash string) (*RefreshToken, error) {
+	err := db.Debug().Model(&RefreshToken{}).Where("token_hash = ?", tokenHash).Take(&rt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &RefreshToken{}, ErrRefreshTokenNotFound
+		}
+		return &RefreshToken{}, err
+	}
+	return rt, nil
+}
+
+// CheckUsable tells if the token can still be exchanged, a revoked token that was already
+// replaced means someone is replaying an old token so the whole family has to be revoked
+func (rt *RefreshToken) CheckUsable() error {
+	if rt.RevokedAt != nil {
+		if rt.ReplacedBy != 0 {
+			return ErrRefreshTokenReused
+		}
+		return ErrRefreshTokenRevoked
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return ErrRefreshTokenExpired
+	}
+	return nil
+}
+
+// Rotate revokes this token and saves next as its replacement in the same family
+func (rt *RefreshToken) Rotate(db *gorm.DB, next *RefreshToken) (*RefreshToken, error) {
+	next.UserID = rt.UserID
+	next.FamilyID = rt.FamilyID
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Debug().Create(next).Error; err != nil {
+			return err
+		}
+		// only rotate a token that is still active, so two concurrent refreshes cannot both win
+		result := tx.Debug().Model(&RefreshToken{}).Where("id = ? AND revoked_at IS NULL", rt.ID).UpdateColumns(
+			map[string]interface{}{
+				"revoked_at":  time.Now(),
+				"replaced_by": next.ID,
+			},
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+		return nil
+	})
+	if err != nil {
+		return &RefreshToken{}, err
+	}
+	return next, nil
+}
+
+// RevokeRefreshTokenFamily revokes every active token that was rotated from the same login
+func (rt *RefreshToken) RevokeRefreshTokenFamily(db *gorm.DB, familyID string) (int64, error) {
+	db = db.Debug().Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).UpdateColumn("revoked_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// RevokeUserRefreshTokens revokes every active token of the user
+func (rt *RefreshToken) RevokeUserRefreshTokens(db *gorm.DB, uid uint32) (int64, error) {
+	db = db.Debug().Model(&RefreshToken{}).Where("user_id = ? AND revoked_at IS NULL", uid).UpdateColumn("revoked_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
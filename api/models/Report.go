@@ -0,0 +1,264 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+// Reasons a reader reports content for
+const (
+	ReportReasonSpam           = "spam"
+	ReportReasonHarassment     = "harassment"
+	ReportReasonHateSpeech     = "hate_speech"
+	ReportReasonViolence       = "violence"
+	ReportReasonSexualContent  = "sexual_content"
+	ReportReasonMisinformation = "misinformation"
+	ReportReasonOther          = "other"
+)
+
+// What can be reported, a reply is reported as the comment it is
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetReply   = "reply"
+	ReportTargetProfile = "profile"
+)
+
+// States of a report, an open report waits for an admin
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Actions an admin resolves the reports of a target with
+const (
+	ReportActionDismiss = "dismiss"
+	ReportActionHide    = "hide"
+	ReportActionDelete  = "delete"
+)
+
+var ErrAlreadyReported = errors.New("you have already reported this")
+
+// Report is a reader telling the admins about abusive content, a reader reports the same content once
+type Report struct {
+	gorm.Model
+	ReporterID uint32     `gorm:"not null;uniqueIndex:idx_reports_reporter_target" json:"reporter_id"`
+	TargetType string     `gorm:"size:20;not null;uniqueIndex:idx_reports_reporter_target;index:idx_reports_target" json:"target_type"`
+	TargetID   uint64     `gorm:"not null;uniqueIndex:idx_reports_reporter_target;index:idx_reports_target" json:"target_id"`
+	Reason     string     `gorm:"size:30;not null" json:"reason"`
+	Details    string     `gorm:"type:text" json:"details"`
+	Status     string     `gorm:"size:20;not null;default:open;index" json:"status"`
+	Resolution string     `gorm:"size:20" json:"resolution"`
+	ResolvedBy uint32     `json:"resolved_by"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	HidTarget  bool       `gorm:"not null;default:false" json:"hid_target"` // this report was the one to hide the target
+}
+
+func IsValidReportReason(reason string) bool {
+	switch reason {
+	case ReportReasonSpam, ReportReasonHarassment, ReportReasonHateSpeech, ReportReasonViolence,
+		ReportReasonSexualContent, ReportReasonMisinformation, ReportReasonOther:
+		return true
+	}
+	return false
+}
+
+// ReportTargetModel returns a model of the reported table, nil when the target type cannot be reported
+func ReportTargetModel(targetType string) interface{} {
+	switch targetType {
+	case ReportTargetPost:
+		return &Post{}
+	case ReportTargetComment:
+		return &Comment{}
+	case ReportTargetProfile:
+		return &Profile{}
+	}
+	return nil
+}
+
+// ReportHideThreshold is how many open reports hide their target until an admin looks at it,
+// REPORT_HIDE_THRESHOLD or 5, 0 never hides anything
+func ReportHideThreshold() int64 {
+	threshold, err := strconv.ParseInt(os.Getenv("REPORT_HIDE_THRESHOLD"), 10, 64)
+	if err != nil || threshold < 0 {
+		return 5
+	}
+	return threshold
+}
+
+func (r *Report) Prepare() {
+	r.TargetType = strings.ToLower(strings.TrimSpace(r.TargetType))
+	if r.TargetType == ReportTargetReply {
+		r.TargetType = ReportTargetComment
+	}
+	r.Reason = strings.ToLower(strings.TrimSpace(r.Reason))
+	r.Details = html.EscapeString(strings.TrimSpace(r.Details))
+}
+
+func (r *Report) Validate() map[string]string {
+	var errorMessages = make(map[string]string)
+	var err error
+
+	if ReportTargetModel(r.TargetType) == nil {
+		err = errors.New("Target type should be post, comment, reply or profile")
+		errorMessages["Invalid_target"] = err.Error()
+	}
+	if r.TargetID == 0 {
+		err = errors.New("Required Target")
+		errorMessages["Required_target"] = err.Error()
+	}
+	if !IsValidReportReason(r.Reason) {
+		err = errors.New("Reason should be spam, harassment, hate_speech, violence, sexual_content, misinformation or other")
+		errorMessages["Invalid_reason"] = err.Error()
+	}
+	if r.Reason == ReportReasonOther && r.Details == "" {
+		err = errors.New("Tell what is wrong when the reason is other")
+		errorMessages["Required_details"] = err.Error()
+	}
+	return errorMessages
+}
+
+// SaveReport saves the report, and hides its target once it has as many open reports as the threshold. It tells
+// if the target was hidden.
+func (r *Report) SaveReport(db *gorm.DB) (*Report, bool, error) {
+	hidden := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Model(&Report{}).Where("reporter_id = ? AND target_type = ? AND target_id = ?", r.ReporterID, r.TargetType, r.TargetID).Take(&Report{}).Error
+		if err == nil {
+			return ErrAlreadyReported
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		r.Status = ReportStatusOpen
+		err = tx.Debug().Create(&r).Error
+		if err != nil {
+			return err
+		}
+
+		threshold := ReportHideThreshold()
+		if threshold == 0 {
+			return nil
+		}
+		var reports int64
+		err = tx.Debug().Model(&Report{}).Where("target_type = ? AND target_id = ? AND status = ?", r.TargetType, r.TargetID, ReportStatusOpen).Count(&reports).Error
+		if err != nil || reports < threshold {
+			return err
+		}
+
+		// the target is hidden once, the admins see it in the audit log
+		rows, err := SuspendRecord(tx.Where("suspended_at IS NULL"), ReportTargetModel(r.TargetType), r.TargetID)
+		if err != nil || rows == 0 {
+			return err
+		}
+		hidden = true
+		r.HidTarget = true
+		err = tx.Debug().Model(&Report{}).Where("id = ?", r.ID).UpdateColumn("hid_target", true).Error
+		if err != nil {
+			return err
+		}
+		auditLog := AuditLog{
+			Action:     AuditActionAutoHide,
+			TargetType: r.TargetType,
+			TargetID:   r.TargetID,
+			Details:    strconv.FormatInt(reports, 10) + " open reports",
+		}
+		_, err = auditLog.SaveAuditLog(tx)
+		return err
+	})
+	if err != nil {
+		return &Report{}, false, err
+	}
+	return r, hidden, nil
+}
+
+// ReportFilter narrows the report list of the admins
+type ReportFilter struct {
+	Status     string
+	TargetType string
+	TargetID   uint64
+	Reason     string
+}
+
+func (r *Report) FindReports(db *gorm.DB, filter ReportFilter, pg *pagination.Pagination) (*[]Report, error) {
+	reports := []Report{}
+	query := db.Debug().Model(&Report{})
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != 0 {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.Reason != "" {
+		query = query.Where("reason = ?", filter.Reason)
+	}
+	query, err := pg.Paginate(query, "")
+	if err != nil {
+		return &[]Report{}, err
+	}
+	err = query.Find(&reports).Error
+	if err != nil {
+		return &[]Report{}, err
+	}
+	pg.SetNextCursor(reports)
+	return &reports, nil
+}
+
+// ResolveReports closes every open report of the target of this report with the action the admin took on it.
+// Dismissed reports show the target again when they hid it, a target an admin suspended stays so.
+func (r *Report) ResolveReports(db *gorm.DB, action string, adminID uint32) (int64, error) {
+	status := ReportStatusResolved
+	if action == ReportActionDismiss {
+		status = ReportStatusDismissed
+	}
+
+	var resolved int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var hid int64
+		err := tx.Debug().Model(&Report{}).Where("target_type = ? AND target_id = ? AND status = ? AND hid_target = ?", r.TargetType, r.TargetID, ReportStatusOpen, true).Count(&hid).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Debug().Model(&Report{}).Where("target_type = ? AND target_id = ? AND status = ?", r.TargetType, r.TargetID, ReportStatusOpen).UpdateColumns(
+			map[string]interface{}{
+				"status":      status,
+				"resolution":  action,
+				"resolved_by": adminID,
+				"resolved_at": time.Now(),
+			},
+		)
+		if result.Error != nil {
+			return result.Error
+		}
+		resolved = result.RowsAffected
+
+		switch action {
+		case ReportActionDismiss:
+			if hid == 0 {
+				return nil
+			}
+			return tx.Debug().Model(ReportTargetModel(r.TargetType)).Where("id = ?", r.TargetID).UpdateColumn("suspended_at", nil).Error
+		case ReportActionHide:
+			_, err = SuspendRecord(tx, ReportTargetModel(r.TargetType), r.TargetID)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resolved, nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RevokedToken blocks an access token before its exp claim. A row with AllSessions set
+// blocks every token of the user issued up to its CreatedAt ("log out all sessions")
+type RevokedToken struct {
+	gorm.Model
+	JTI         string    `gorm:"size:64;index" json:"jti"`
+	UserID      uint32    `gorm:"not null;index" json:"user_id"`
+	AllSessions bool      `gorm:"not null;default:false" json:"all_sessions"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+}
+
+func (rt *RevokedToken) SaveRevokedToken(db *gorm.DB) (*RevokedToken, error) {
+	err := db.Debug().Create(&rt).Error
+	if err != nil {
+		return &RevokedToken{}, err
+	}
+	return rt, nil
+}
+
+// FindActiveRevokedTokens returns the revocations that can still match a valid token
+func (rt *RevokedToken) FindActiveRevokedTokens(db *gorm.DB) (*[]RevokedToken, error) {
+	revokedTokens := []RevokedToken{}
+	err := db.Debug().Model(&RevokedToken{}).Where("expires_at > ?", time.Now()).Find(&revokedTokens).Error
+	if err != nil {
+		return &[]RevokedToken{}, err
+	}
+	return &revokedTokens, nil
+}
+
+// Once every token a row could match has expired, the row is useless
+func (rt *RevokedToken) DeleteExpiredRevokedTokens(db *gorm.DB) (int64, error) {
+	db = db.Debug().Unscoped().Where("expires_at <= ?", time.Now()).Delete(&RevokedToken{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
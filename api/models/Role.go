@@ -0,0 +1,63 @@
+package models
+
+// Roles a user can have, every registered user starts as an author
+const (
+	RoleReader = "reader"
+	RoleAuthor = "author"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Permissions checked by the routes and the controllers
+const (
+	PermissionCreateComment    = "comments:create"
+	PermissionCreatePost       = "posts:create"
+	PermissionModeratePosts    = "posts:moderate"
+	PermissionModerateComments = "comments:moderate"
+	PermissionManageUsers      = "users:manage"
+	PermissionManageTags       = "tags:manage"
+)
+
+var rolePermissions = map[string][]string{
+	RoleReader: {
+		PermissionCreateComment,
+	},
+	RoleAuthor: {
+		PermissionCreateComment,
+		PermissionCreatePost,
+	},
+	RoleEditor: {
+		PermissionCreateComment,
+		PermissionCreatePost,
+		PermissionModeratePosts,
+		PermissionModerateComments,
+		PermissionManageTags,
+	},
+	RoleAdmin: {
+		PermissionCreateComment,
+		PermissionCreatePost,
+		PermissionModeratePosts,
+		PermissionModerateComments,
+		PermissionManageUsers,
+		PermissionManageTags,
+	},
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns the permissions granted to the role, an unknown role has none
+func RolePermissions(role string) []string {
+	return rolePermissions[role]
+}
+
+func RoleHasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,258 @@
+package models
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSeriesPostNotOwned = errors.New("only the posts of the series owner can be added to it")
+	ErrSeriesPostTaken    = errors.New("a post can only be part of one series")
+)
+
+// Series ties the parts of a multi-part post together, in the order of their position
+type Series struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	Title       string    `gorm:"size:255;not null" json:"title"`
+	Description string    `gorm:"type:text" json:"description"`
+	ProfileID   uint      `gorm:"not null;index" json:"profile_id"`
+	Profile     Profile   `gorm:"foreignKey:ProfileID" json:"profile"`
+	PostIDs     []uint    `gorm:"-" json:"post_ids,omitempty"` // the posts of the series in their order, as sent by a client
+	Posts       []Post    `gorm:"-" json:"posts"`
+	PostCount   int64     `gorm:"->;-:migration" json:"post_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// SeriesPost is the membership of a post in a series, a post is part of one series at most
+type SeriesPost struct {
+	ID       uint `gorm:"primarykey" json:"id"`
+	SeriesID uint `gorm:"not null;index" json:"series_id"`
+	PostID   uint `gorm:"not null;uniqueIndex" json:"post_id"`
+	Position int  `gorm:"not null" json:"position"`
+}
+
+// SeriesNavigation is where a post stands in its series, with links to the parts around it
+type SeriesNavigation struct {
+	ID       uint            `json:"id"`
+	Title    string          `json:"title"`
+	Position int             `json:"position"`
+	Total    int             `json:"total"`
+	Previous *SeriesPostLink `json:"previous"`
+	Next     *SeriesPostLink `json:"next"`
+}
+
+type SeriesPostLink struct {
+	ID             uint   `json:"id"`
+	Title          string `json:"title"`
+	PostPermalinks string `json:"post_permalinks"`
+}
+
+func (s *Series) Prepare() {
+	s.Title = html.EscapeString(strings.TrimSpace(s.Title))
+	s.Description = html.EscapeString(strings.TrimSpace(s.Description))
+}
+
+func (s *Series) Validate() map[string]string {
+	var err error
+
+	var errorMessages = make(map[string]string)
+	if s.Title == "" {
+		err = errors.New("Title is required")
+		errorMessages["Required_title"] = err.Error()
+	}
+	if s.ProfileID < 1 {
+		err = errors.New("Required Profile")
+		errorMessages["Required_profile"] = err.Error()
+	}
+	return errorMessages
+}
+
+func (s *Series) SaveSeries(db *gorm.DB) (*Series, error) {
+	err := db.Debug().Transaction(func(tx *gorm.DB) error {
+		err := tx.Omit("Profile").Create(&s).Error
+		if err != nil {
+			return err
+		}
+		return s.SetSeriesPosts(tx, s.PostIDs)
+	})
+	if err != nil {
+		return &Series{}, err
+	}
+	return s, nil
+}
+
+// SetSeriesPosts replaces the posts of the series with these ones, in this order
+func (s *Series) SetSeriesPosts(db *gorm.DB, postIDs []uint) error {
+	ids := []uint{}
+	seen := map[uint]bool{}
+	for _, id := range postIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) > 0 {
+		var owned int64
+		err := db.Debug().Model(&Post{}).Where("id IN ? AND author_id = ?", ids, s.ProfileID).Count(&owned).Error
+		if err != nil {
+			return err
+		}
+		if owned != int64(len(ids)) {
+			return ErrSeriesPostNotOwned
+		}
+
+		var taken int64
+		err = db.Debug().Model(&SeriesPost{}).Where("post_id IN ? AND series_id <> ?", ids, s.ID).Count(&taken).Error
+		if err != nil {
+			return err
+		}
+		if taken > 0 {
+			return ErrSeriesPostTaken
+		}
+	}
+
+	err := db.Debug().Where("series_id = ?", s.ID).Delete(&SeriesPost{}).Error
+	if err != nil {
+		return err
+	}
+	seriesPosts := make([]SeriesPost, len(ids))
+	for i, id := range ids {
+		seriesPosts[i] = SeriesPost{SeriesID: s.ID, PostID: id, Position: i + 1}
+	}
+	if len(seriesPosts) > 0 {
+		err = db.Debug().Create(&seriesPosts).Error
+		if err != nil {
+			return err
+		}
+	}
+	s.PostIDs = ids
+	return nil
+}
+
+// ReorderSeriesPosts sets the posts of an existing series, see SetSeriesPosts
+func (s *Series) ReorderSeriesPosts(db *gorm.DB, postIDs []uint) error {
+	return db.Debug().Transaction(func(tx *gorm.DB) error {
+		return s.SetSeriesPosts(tx, postIDs)
+	})
+}
+
+// FindSeriesByID returns the series with the posts the viewer can see, in their order
+func (s *Series) FindSeriesByID(db *gorm.DB, id uint64, viewerID uint) (*Series, error) {
+	err := db.Debug().Model(&Series{}).Preload("Profile").Where("id = ?", id).Take(&s).Error
+	if err != nil {
+		return &Series{}, err
+	}
+
+	s.Posts = []Post{}
+	err = db.Debug().Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(viewerID)).
+		Select("posts.*").
+		Joins("JOIN series_posts ON series_posts.post_id = posts.id").
+		Where("series_posts.series_id = ?", s.ID).
+		Order("series_posts.position asc").
+		Preload("Author").Preload("TagList").Find(&s.Posts).Error
+	if err != nil {
+		return &Series{}, err
+	}
+	s.PostCount = int64(len(s.Posts))
+	return s, nil
+}
+
+// FindAllSeries lists the series, only the ones of a profile when profileID is not 0. Their post count is the
+// number of posts the viewer sees in them, like FindSeriesByID.
+func (s *Series) FindAllSeries(db *gorm.DB, profileID uint, viewerID uint, pg *pagination.Pagination) (*[]Series, error) {
+	series := []Series{}
+	query := db.Debug().Model(&Series{})
+	if profileID != 0 {
+		query = query.Where("profile_id = ?", profileID)
+	}
+	query, err := pg.Paginate(query, "series.id")
+	if err != nil {
+		return &[]Series{}, err
+	}
+	visiblePosts := db.Session(&gorm.Session{NewDB: true}).Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(viewerID)).
+		Select("COUNT(*)").
+		Joins("JOIN series_posts ON series_posts.post_id = posts.id").
+		Where("series_posts.series_id = series.id")
+	err = query.Select("series.*, (?) AS post_count", visiblePosts).
+		Preload("Profile").Find(&series).Error
+	if err != nil {
+		return &[]Series{}, err
+	}
+	pg.SetNextCursor(series)
+	return &series, nil
+}
+
+// FindSeriesNavigation returns the series of the post with the previous and next parts the viewer can see,
+// or nil when the post is not part of a series
+func FindSeriesNavigation(db *gorm.DB, postID uint, viewerID uint) (*SeriesNavigation, error) {
+	seriesPost := SeriesPost{}
+	err := db.Debug().Model(&SeriesPost{}).Where("post_id = ?", postID).Take(&seriesPost).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	series := Series{}
+	err = db.Debug().Model(&Series{}).Where("id = ?", seriesPost.SeriesID).Take(&series).Error
+	if err != nil {
+		return nil, err
+	}
+
+	links := []SeriesPostLink{}
+	err = db.Debug().Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(viewerID)).
+		Select("posts.id, posts.title, posts.post_permalinks").
+		Joins("JOIN series_posts ON series_posts.post_id = posts.id").
+		Where("series_posts.series_id = ?", series.ID).
+		Order("series_posts.position asc").
+		Scan(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	navigation := SeriesNavigation{ID: series.ID, Title: series.Title, Total: len(links)}
+	for i := range links {
+		if links[i].ID != postID {
+			continue
+		}
+		navigation.Position = i + 1
+		if i > 0 {
+			navigation.Previous = &links[i-1]
+		}
+		if i < len(links)-1 {
+			navigation.Next = &links[i+1]
+		}
+	}
+	return &navigation, nil
+}
+
+// DeletePostSeries takes a post deleted for good out of its series
+func (p *Post) DeletePostSeries(db *gorm.DB) (int64, error) {
+	db = db.Debug().Model(&SeriesPost{}).Where("post_id = ?", p.ID).Delete(&SeriesPost{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// DeleteProfileSeries removes the series of a profile deleted for good, the posts themselves are left alone
+func (s *Series) DeleteProfileSeries(db *gorm.DB, profileID uint32) (int64, error) {
+	profileSeries := db.Session(&gorm.Session{NewDB: true}).Model(&Series{}).Select("id").Where("profile_id = ?", profileID)
+	err := db.Debug().Where("series_id IN (?)", profileSeries).Delete(&SeriesPost{}).Error
+	if err != nil {
+		return 0, err
+	}
+	db = db.Debug().Model(&Series{}).Where("profile_id = ?", profileID).Delete(&Series{})
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
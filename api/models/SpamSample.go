@@ -0,0 +1,60 @@
+package models
+
+import (
+	"errors"
+
+	"github.com/Mdromi/exp-blog-backend/api/moderation"
+	"gorm.io/gorm"
+)
+
+// spamTrainingBatch is how many samples the classifier loads at once when it is trained
+const spamTrainingBatch = 500
+
+// SpamSample is a comment or post an admin labelled as spam or not, the spam classifier learns from them.
+// The text is kept as it was labelled, so the sample outlives its comment or post.
+type SpamSample struct {
+	gorm.Model
+	Kind       string `gorm:"size:20;not null;uniqueIndex:idx_spam_samples_record" json:"kind"`
+	RecordID   uint64 `gorm:"not null;uniqueIndex:idx_spam_samples_record" json:"record_id"`
+	Text       string `gorm:"type:text;not null" json:"text"`
+	Spam       bool   `gorm:"not null" json:"spam"`
+	LabelledBy uint32 `gorm:"not null" json:"labelled_by"`
+}
+
+// LabelSpam saves the label of the comment or post and teaches it to the classifier, labelling it again replaces
+// what the classifier learned from it before
+func (s *SpamSample) LabelSpam(db *gorm.DB) (*SpamSample, error) {
+	previous := SpamSample{}
+	err := db.Debug().Model(&SpamSample{}).Where("kind = ? AND record_id = ?", s.Kind, s.RecordID).Take(&previous).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return &SpamSample{}, err
+	}
+
+	if err == nil {
+		s.ID = previous.ID
+		s.CreatedAt = previous.CreatedAt
+		err = db.Debug().Save(&s).Error
+	} else {
+		err = db.Debug().Create(&s).Error
+	}
+	if err != nil {
+		return &SpamSample{}, err
+	}
+
+	if previous.ID != 0 {
+		moderation.Classifier.Untrain(previous.Text, previous.Spam)
+	}
+	moderation.Classifier.Train(s.Text, s.Spam)
+	return s, nil
+}
+
+// TrainSpamClassifier teaches every saved sample to the classifier, when the server starts
+func TrainSpamClassifier(db *gorm.DB) error {
+	samples := []SpamSample{}
+	return db.Debug().Model(&SpamSample{}).FindInBatches(&samples, spamTrainingBatch, func(tx *gorm.DB, batch int) error {
+		for _, sample := range samples {
+			moderation.Classifier.Train(sample.Text, sample.Spam)
+		}
+		return nil
+	}).Error
+}
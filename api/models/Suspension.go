@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+// Status values accepted by the admin list filters
+const (
+	StatusActive    = "active"
+	StatusSuspended = "suspended"
+	StatusDeleted   = "deleted"
+)
+
+// NotSuspended hides the rows an admin suspended, use it with db.Scopes on public queries
+func NotSuspended(db *gorm.DB) *gorm.DB {
+	return db.Where("suspended_at IS NULL")
+}
+
+// SuspendRecord hides the row with this id from every public endpoint without deleting it
+func SuspendRecord(db *gorm.DB, model interface{}, id uint64) (int64, error) {
+	db = db.Debug().Model(model).Where("id = ?", id).UpdateColumn("suspended_at", time.Now())
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// RestoreRecord brings back a suspended or soft deleted row
+func RestoreRecord(db *gorm.DB, model interface{}, id uint64) (int64, error) {
+	db = db.Debug().Unscoped().Model(model).Where("id = ?", id).UpdateColumns(
+		map[string]interface{}{
+			"suspended_at": nil,
+			"deleted_at":   nil,
+		},
+	)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// AdminFilter narrows the admin lists
+type AdminFilter struct {
+	Status        string
+	Query         string
+	SearchColumns []string
+	OwnerColumn   string
+	OwnerID       uint64
+	Omit          []string // columns never sent to the admins, like the password hashes
+}
+
+// AdminFind loads the rows of model matching the filter into dest, suspended and deleted rows included
+func AdminFind(db *gorm.DB, model interface{}, dest interface{}, filter AdminFilter, pg *pagination.Pagination) error {
+	query := db.Debug().Unscoped().Model(model)
+	if len(filter.Omit) > 0 {
+		query = query.Omit(filter.Omit...)
+	}
+
+	switch filter.Status {
+	case StatusActive:
+		query = query.Where("deleted_at IS NULL AND suspended_at IS NULL")
+	case StatusSuspended:
+		query = query.Where("deleted_at IS NULL AND suspended_at IS NOT NULL")
+	case StatusDeleted:
+		query = query.Where("deleted_at IS NOT NULL")
+	}
+
+	if filter.Query != "" && len(filter.SearchColumns) > 0 {
+		conditions := make([]string, len(filter.SearchColumns))
+		values := make([]interface{}, len(filter.SearchColumns))
+		for i, column := range filter.SearchColumns {
+			conditions[i] = "LOWER(" + column + ") LIKE ?"
+			values[i] = "%" + strings.ToLower(filter.Query) + "%"
+		}
+		query = query.Where(strings.Join(conditions, " OR "), values...)
+	}
+
+	if filter.OwnerID != 0 && filter.OwnerColumn != "" {
+		query = query.Where(filter.OwnerColumn+" = ?", filter.OwnerID)
+	}
+
+	query, err := pg.Paginate(query, "")
+	if err != nil {
+		return err
+	}
+	err = query.Find(dest).Error
+	if err != nil {
+		return err
+	}
+	pg.SetNextCursor(dest)
+	return nil
+}
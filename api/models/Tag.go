@@ -0,0 +1,224 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/Mdromi/exp-blog-backend/api/utils/postformator"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTagExists      = errors.New("a tag with this name already exists")
+	ErrTagInvalidName = errors.New("tag name is required")
+	ErrTagMergeSelf   = errors.New("cannot merge a tag into itself")
+)
+
+// Tag is shared by every post using it, the posts are linked to their tags through the post_tags table.
+// Tags are never soft deleted, so the slug of a merged tag can be used again right away.
+type Tag struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	Name      string    `gorm:"size:100;not null" json:"name"`
+	Slug      string    `gorm:"size:100;not null;uniqueIndex" json:"slug"`
+	PostCount int64     `gorm:"->;-:migration" json:"post_count"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NormalizeTags turns the tag names sent by a client in tags without duplicates,
+// the first spelling of a name is kept for display
+func NormalizeTags(names []string) []Tag {
+	tags := []Tag{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.Join(strings.Fields(name), " ")
+		slug := postformator.CreateTagSlug(name)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		tags = append(tags, Tag{Name: name, Slug: slug})
+	}
+	return tags
+}
+
+// FindOrCreateTags returns the tags with these names, creating the ones used for the first time
+func FindOrCreateTags(db *gorm.DB, names []string) ([]Tag, error) {
+	tags := NormalizeTags(names)
+	for i := range tags {
+		err := db.Debug().Where(Tag{Slug: tags[i].Slug}).Attrs(Tag{Name: tags[i].Name}).FirstOrCreate(&tags[i]).Error
+		if err != nil {
+			return []Tag{}, err
+		}
+	}
+	return tags, nil
+}
+
+// SyncTags replaces the tags of the post with these names
+func (p *Post) SyncTags(db *gorm.DB, names []string) error {
+	tags, err := FindOrCreateTags(db, names)
+	if err != nil {
+		return err
+	}
+	err = db.Debug().Model(p).Association("TagList").Replace(tags)
+	if err != nil {
+		return err
+	}
+	p.TagList = tags
+	p.Tags = tagNames(tags)
+	return refreshSearchVectors(db, "id = ?", p.ID)
+}
+
+func tagNames(tags []Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+// DeletePostTags unlinks the post from all its tags, it has to run before the post is deleted for good
+func (p *Post) DeletePostTags(db *gorm.DB) (int64, error) {
+	db = db.Debug().Exec("DELETE FROM post_tags WHERE post_id = ?", p.ID)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
+// FindAllTags lists the tags with the number of published posts using them, the most used first
+func (t *Tag) FindAllTags(db *gorm.DB, pg *pagination.Pagination) (*[]Tag, error) {
+	tags := []Tag{}
+	query, err := pg.PaginateOrdered(db.Debug().Model(&Tag{}), "post_count desc, tags.name asc")
+	if err != nil {
+		return &[]Tag{}, err
+	}
+	err = query.Select("tags.*, COUNT(posts.id) AS post_count").
+		Joins("LEFT JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("LEFT JOIN posts ON posts.id = post_tags.post_id AND posts.deleted_at IS NULL AND posts.suspended_at IS NULL AND posts.status = ?", PostStatusPublished).
+		Group("tags.id").
+		Find(&tags).Error
+	if err != nil {
+		return &[]Tag{}, err
+	}
+	pg.SetNextCursor(tags)
+	return &tags, nil
+}
+
+func (t *Tag) FindTagBySlug(db *gorm.DB, slug string) (*Tag, error) {
+	err := db.Debug().Model(&Tag{}).Where("slug = ?", postformator.CreateTagSlug(slug)).Take(&t).Error
+	if err != nil {
+		return &Tag{}, err
+	}
+	return t, nil
+}
+
+// FindTagPosts lists the posts using the tag
+func (p *Post) FindTagPosts(db *gorm.DB, tagID uint, viewerID uint, pg *pagination.Pagination) (*[]Post, error) {
+	posts := []Post{}
+	query, err := pg.Paginate(db.Debug().Model(&Post{}).Scopes(NotSuspended, PostVisibleTo(viewerID)).Where("id IN (?)", db.Table("post_tags").Select("post_id").Where("tag_id = ?", tagID)), "")
+	if err != nil {
+		return &[]Post{}, err
+	}
+	err = query.Preload("Author").Preload("TagList").Find(&posts).Error
+	if err != nil {
+		return &[]Post{}, err
+	}
+	pg.SetNextCursor(posts)
+	return &posts, nil
+}
+
+// RenameTag changes the name of the tag and its slug with it, the new slug must not belong to another tag
+func (t *Tag) RenameTag(db *gorm.DB, name string) (*Tag, error) {
+	renamed := NormalizeTags([]string{name})
+	if len(renamed) == 0 {
+		return &Tag{}, ErrTagInvalidName
+	}
+
+	var count int64
+	err := db.Debug().Model(&Tag{}).Where("slug = ? AND id <> ?", renamed[0].Slug, t.ID).Count(&count).Error
+	if err != nil {
+		return &Tag{}, err
+	}
+	if count > 0 {
+		return &Tag{}, ErrTagExists
+	}
+
+	err = db.Debug().Model(&Tag{}).Where("id = ?", t.ID).Updates(Tag{Name: renamed[0].Name, Slug: renamed[0].Slug}).Error
+	if err != nil {
+		return &Tag{}, err
+	}
+	t.Name = renamed[0].Name
+	t.Slug = renamed[0].Slug
+	err = refreshSearchVectors(db, "id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)", t.ID)
+	if err != nil {
+		return &Tag{}, err
+	}
+	return t, nil
+}
+
+// MergeTag moves every post of the tag to target, then deletes the tag
+func (t *Tag) MergeTag(db *gorm.DB, target *Tag) (int64, error) {
+	if t.ID == target.ID {
+		return 0, ErrTagMergeSelf
+	}
+
+	var moved int64
+	err := db.Debug().Transaction(func(tx *gorm.DB) error {
+		// posts already having both tags keep a single link
+		result := tx.Exec("INSERT INTO post_tags (post_id, tag_id) SELECT post_id, ? FROM post_tags WHERE tag_id = ? AND post_id NOT IN (SELECT post_id FROM (SELECT post_id FROM post_tags WHERE tag_id = ?) AS tagged)", target.ID, t.ID, target.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		moved = result.RowsAffected
+
+		err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", t.ID).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Delete(&Tag{}, t.ID).Error
+		if err != nil {
+			return err
+		}
+		return refreshSearchVectors(tx, "id IN (SELECT post_id FROM post_tags WHERE tag_id = ?)", target.ID)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// MigrateLegacyPostTags moves the tags of the old posts.tags text[] column to the post_tags table, then drops the column
+func MigrateLegacyPostTags(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Post{}, "tags") {
+		return nil
+	}
+
+	type legacyPost struct {
+		ID   uint
+		Tags pq.StringArray
+	}
+	legacyPosts := []legacyPost{}
+	err := db.Debug().Table("posts").Select("id, tags").Where("tags IS NOT NULL").Find(&legacyPosts).Error
+	if err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, legacy := range legacyPosts {
+			post := Post{}
+			post.ID = legacy.ID
+			if err := post.SyncTags(tx, legacy.Tags); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&Post{}, "tags")
+}
@@ -0,0 +1,158 @@
+package models
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"gorm.io/gorm"
+)
+
+// ErrTrashParentDeleted is returned when a comment is restored while its post or the comment it replies to is still deleted
+var ErrTrashParentDeleted = errors.New("restore what it belongs to first")
+
+// trashPurgeBatch is how many posts or comments a purge deletes for good at most, the next run takes the rest
+const trashPurgeBatch = 500
+
+// TrashRetention is how long deleted rows can be restored before they are purged, TRASH_RETENTION or 30 days
+func TrashRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		return 30 * 24 * time.Hour
+	}
+	return retention
+}
+
+// Trashed matches the rows deleted within the retention window, use it with db.Unscoped().Scopes
+func Trashed(db *gorm.DB) *gorm.DB {
+	return db.Where("deleted_at IS NOT NULL AND deleted_at > ?", time.Now().Add(-TrashRetention()))
+}
+
+// markDeletedBy records who deletes the rows matching the query before a soft delete hides them, the trash only
+// gives back to their owner the rows it deleted itself
+func markDeletedBy(db *gorm.DB, model interface{}, deletedBy uint32, query string, args ...interface{}) error {
+	if db.Statement.Unscoped {
+		return nil
+	}
+	return db.Debug().Model(model).Where(query, args...).UpdateColumn("deleted_by", deletedBy).Error
+}
+
+// FindTrashed loads the rows of model the owner deleted itself within the retention window into dest
+func FindTrashed(db *gorm.DB, model interface{}, dest interface{}, ownerColumn string, ownerID uint32, pg *pagination.Pagination) error {
+	query, err := pg.Paginate(db.Debug().Unscoped().Model(model).Scopes(Trashed).Where(ownerColumn+" = ? AND deleted_by = ?", ownerID, ownerID), "")
+	if err != nil {
+		return err
+	}
+	err = query.Find(dest).Error
+	if err != nil {
+		return err
+	}
+	pg.SetNextCursor(dest)
+	return nil
+}
+
+// FindTrashedRecord loads the row of model with this id into dest when the owner deleted it itself within the
+// retention window
+func FindTrashedRecord(db *gorm.DB, dest interface{}, id uint64, ownerColumn string, ownerID uint32) error {
+	return db.Debug().Unscoped().Model(dest).Scopes(Trashed).Where("id = ? AND "+ownerColumn+" = ? AND deleted_by = ?", id, ownerID, ownerID).Take(dest).Error
+}
+
+// restoredColumns bring a deleted row back
+var restoredColumns = map[string]interface{}{"deleted_at": nil, "deleted_by": 0}
+
+// The cascades soft delete the post or comment before the rows belonging to it, so what was deleted with it is not
+// older than it. Restoring brings these rows back and leaves the ones that were deleted on their own before.
+
+// RestorePostCascade brings back the deleted post with the comments and likes deleted with it
+func (p *Post) RestorePostCascade(db *gorm.DB) error {
+	deletedAt := p.DeletedAt.Time
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Unscoped().Model(&Comment{}).Where("post_id = ? AND deleted_at >= ?", p.ID, deletedAt).UpdateColumns(restoredColumns).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Debug().Unscoped().Model(&LikeDislike{}).Where("post_id = ? AND deleted_at >= ?", p.ID, deletedAt).UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Debug().Unscoped().Model(&Post{}).Where("id = ?", p.ID).UpdateColumns(restoredColumns).Error
+		if err != nil {
+			return err
+		}
+		p.DeletedAt = gorm.DeletedAt{}
+		p.DeletedBy = 0
+		return nil
+	})
+}
+
+// RestoreCommentCascade brings back the deleted comment with the replies deleted with it, its post and the
+// comment it replies to have to be there
+func (c *Comment) RestoreCommentCascade(db *gorm.DB) error {
+	deletedAt := c.DeletedAt.Time
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Model(&Post{}).Where("id = ?", c.PostID).Take(&Post{}).Error
+		if err == nil && c.ParentID != nil {
+			err = tx.Debug().Model(&Comment{}).Where("id = ?", *c.ParentID).Take(&Comment{}).Error
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTrashParentDeleted
+		}
+		if err != nil {
+			return err
+		}
+		err = tx.Debug().Unscoped().Model(&Comment{}).Where("path LIKE ? AND deleted_at >= ?", c.Path+"%", deletedAt).UpdateColumns(restoredColumns).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Debug().Unscoped().Model(&Comment{}).Where("id = ?", c.ID).UpdateColumns(restoredColumns).Error
+		if err != nil {
+			return err
+		}
+		c.DeletedAt = gorm.DeletedAt{}
+		c.DeletedBy = 0
+		return nil
+	})
+}
+
+// PurgeTrash deletes for good the posts, comments and likes deleted before the time, the posts and comments with
+// everything belonging to them. It returns how many posts, comments and likes were purged.
+func PurgeTrash(db *gorm.DB, before time.Time) (int64, error) {
+	var purged int64
+
+	posts := []Post{}
+	err := db.Debug().Unscoped().Model(&Post{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Limit(trashPurgeBatch).Find(&posts).Error
+	if err != nil {
+		return purged, err
+	}
+	for i := range posts {
+		if err := posts[i].DeletePostCascade(HardDeleteDB(db)); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	// a reply deleted with its comment is purged with it, so it can be gone by the time its turn comes
+	comments := []Comment{}
+	err = db.Debug().Unscoped().Model(&Comment{}).Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Order("path asc").Limit(trashPurgeBatch).Find(&comments).Error
+	if err != nil {
+		return purged, err
+	}
+	for i := range comments {
+		_, err := comments[i].DeleteAComment(HardDeleteDB(db))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	result := db.Debug().Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(&LikeDislike{})
+	if result.Error != nil {
+		return purged, result.Error
+	}
+	purged += result.RowsAffected
+	return purged, nil
+}
@@ -6,8 +6,10 @@ import (
 	"log"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/Mdromi/exp-blog-backend/api/security"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/badoux/checkmail"
 	"gorm.io/gorm"
 )
@@ -19,6 +21,9 @@ type User struct {
 	Email      string `gorm:"size:100;not null;unique" json:"email"`
 	Password   string `gorm:"size:100;not null;" json:"password"`
 	AvatarPath string `gorm:"size:255" json:"avatar_path"`
+	Role       string `gorm:"size:20;not null;default:author" json:"role"`
+	// SuspendedAt is set by an admin, a suspended user cannot login
+	SuspendedAt *time.Time `json:"suspended_at"`
 	// Profile    Profile `json:"profile"`
 	ProfileID uint32 `gorm:"not null" json:"profile_id"`
 }
@@ -36,6 +41,15 @@ func (u *User) BeforeSave() error {
 func (u *User) Prepare() {
 	u.Username = html.EscapeString(strings.TrimSpace(u.Username))
 	u.Email = html.EscapeString(strings.TrimSpace(u.Email))
+	u.Role = strings.ToLower(strings.TrimSpace(u.Role))
+	if u.Role == "" {
+		u.Role = RoleAuthor
+	}
+}
+
+// Permissions returns what the user's role allows
+func (u *User) Permissions() []string {
+	return RolePermissions(u.Role)
 }
 
 func (u *User) AfterFind() (err error) {
@@ -132,19 +146,23 @@ func (u *User) SaveUser(db *gorm.DB) (*User, error) {
 }
 
 // THE ONLY PERSON THAT NEED TO DO THIS IS THE ADMIN, SO I HAVE COMMENTED THE ROUTES, SO SOMEONE ELSE DONT VIES THIS DEATAILS
-func (u *User) FindAllUsers(db *gorm.DB) (*[]User, error) {
-	var err error
+func (u *User) FindAllUsers(db *gorm.DB, pg *pagination.Pagination) (*[]User, error) {
 	users := []User{}
-	err = db.Debug().Model(&User{}).Limit(100).Find(&users).Error
+	query, err := pg.Paginate(db.Debug().Model(&User{}).Scopes(NotSuspended), "")
 	if err != nil {
 		return &[]User{}, err
 	}
+	err = query.Find(&users).Error
+	if err != nil {
+		return &[]User{}, err
+	}
+	pg.SetNextCursor(users)
 	return &users, nil
 }
 
 func (u *User) FindUserByID(db *gorm.DB, uid uint32) (*User, error) {
 	var err error
-	err = db.Debug().Model(User{}).Where("id = ?", uid).Take(&u).Error
+	err = db.Debug().Model(User{}).Scopes(NotSuspended).Where("id = ?", uid).Take(&u).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return &User{}, errors.New("user not found")
@@ -206,6 +224,36 @@ func (u *User) UpdateAUserAvatar(db *gorm.DB, uid uint32) (*User, error) {
 	return u, nil
 }
 
+func (u *User) UpdateAUserRole(db *gorm.DB, uid uint32) (*User, error) {
+	if !IsValidRole(u.Role) {
+		return &User{}, errors.New("invalid role")
+	}
+
+	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).UpdateColumns(
+		map[string]interface{}{
+			"role": u.Role,
+		},
+	)
+	if db.Error != nil {
+		return &User{}, db.Error
+	}
+
+	err := db.Debug().Model(&User{}).Where("id = ?", uid).Take(&u).Error
+	if err != nil {
+		return &User{}, err
+	}
+	return u, nil
+}
+
+// PromoteAdmin gives the admin role to the user with this email, it is used to bootstrap the first admin
+func (u *User) PromoteAdmin(db *gorm.DB, email string) (int64, error) {
+	db = db.Debug().Model(&User{}).Where("email = ?", email).UpdateColumn("role", RoleAdmin)
+	if db.Error != nil {
+		return 0, db.Error
+	}
+	return db.RowsAffected, nil
+}
+
 func (u *User) DeleteAUser(db *gorm.DB, uid uint32) (int64, error) {
 	db = db.Debug().Model(&User{}).Where("id = ?", uid).Take(&User{}).Delete(&User{})
 
@@ -3,15 +3,18 @@ package models
 import (
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// LikeDislike model represents like/dislike actions
+// LikeDislike model represents the reaction of a profile to a post, a profile has one reaction per post
 type LikeDislike struct {
 	gorm.Model
-	ProfileID uint   `gorm:"not null" json:"profile_id"`
-	PostID    uint   `gorm:"not null" json:"post_id"`
+	ProfileID uint   `gorm:"not null;uniqueIndex:idx_like_dislikes_post_profile,priority:2" json:"profile_id"`
+	PostID    uint   `gorm:"not null;uniqueIndex:idx_like_dislikes_post_profile,priority:1" json:"post_id"`
 	Action    string `gorm:"not null" json:"action"`
 }
 
@@ -23,43 +26,148 @@ const (
 	ActionSad     = "sad"
 )
 
-// SaveLikeDislike saves a like/dislike action to the database.
+// ReactionCounts are the reactions to a post, cached on the post and counted again whenever they change
+type ReactionCounts struct {
+	Like    int64 `gorm:"column:like_count;not null;default:0" json:"like"`
+	Dislike int64 `gorm:"column:dislike_count;not null;default:0" json:"dislike"`
+	Hard    int64 `gorm:"column:hard_count;not null;default:0" json:"hard"`
+	Sad     int64 `gorm:"column:sad_count;not null;default:0" json:"sad"`
+}
+
+// ReactionSummary is what a reader sees of the reactions to a post
+type ReactionSummary struct {
+	PostID   uint           `json:"post_id"`
+	Counts   ReactionCounts `json:"counts"`
+	Reaction string         `json:"reaction"` // the reaction of the reader asking, empty when they have none
+}
+
+// reactionCountColumns are the counters of the posts by the action they count
+var reactionCountColumns = map[string]string{
+	ActionLike:    "like_count",
+	ActionDislike: "dislike_count",
+	ActionHard:    "hard_count",
+	ActionSad:     "sad_count",
+}
+
+// SaveLike saves the reaction of the profile to the post in one statement, a new action replaces the one the
+// profile had and reacting again with the same one changes nothing
 func (ld *LikeDislike) SaveLike(db *gorm.DB) (*LikeDislike, error) {
-	// Check if the action is a valid one
-	if !isValidAction(ld.Action) {
-		return nil, errors.New("invalid action")
+	if !IsValidReaction(ld.Action) {
+		return &LikeDislike{}, errors.New("Action should be like, dislike, hard or sad")
 	}
 
-	// Check if the user has previously disliked this post
-	dislikedBefore, err := CheckIfDislikedBefore(db, ld.PostID, ld.ProfileID)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// the post is locked so the reactions to it are counted one after the other
+		err := tx.Debug().Model(&Post{}).Select("id").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", ld.PostID).Take(&Post{}).Error
+		if err != nil {
+			return err
+		}
+
+		// a removed reaction is soft deleted, reacting again brings it back
+		reaction := LikeDislike{ProfileID: ld.ProfileID, PostID: ld.PostID, Action: ld.Action}
+		err = tx.Debug().Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "post_id"}, {Name: "profile_id"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"action":     ld.Action,
+				"updated_at": time.Now(),
+				"deleted_at": nil,
+			}),
+		}).Create(&reaction).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Debug().Model(&LikeDislike{}).Where("post_id = ? AND profile_id = ?", ld.PostID, ld.ProfileID).Take(ld).Error
+		if err != nil {
+			return err
+		}
+		return RefreshReactionCounts(tx, ld.PostID)
+	})
 	if err != nil {
-		return nil, err
+		return &LikeDislike{}, err
 	}
+	return ld, nil
+}
 
-	if dislikedBefore != "" {
-		// The user has previously disliked this post, so we need to remove the dislike
-		err = RemoveLikeDislike(db, ld.PostID, ld.ProfileID)
+// RemoveReaction deletes the reaction of the profile to the post, it is not an error when there is none
+func RemoveReaction(db *gorm.DB, postID, profileID uint) (int64, error) {
+	var removed int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Debug().Model(&Post{}).Select("id").Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", postID).Take(&Post{}).Error
 		if err != nil {
-			return nil, err
+			return err
 		}
-		// The user has already performed this like/dislike action before, so return a custom error message
-		if dislikedBefore == ld.Action {
-			return nil, errors.New("you have already disliked this post")
+		result := tx.Debug().Where("post_id = ? AND profile_id = ?", postID, profileID).Delete(&LikeDislike{})
+		if result.Error != nil {
+			return result.Error
 		}
+		removed = result.RowsAffected
+		return RefreshReactionCounts(tx, postID)
+	})
+	if err != nil {
+		return 0, err
 	}
+	return removed, nil
+}
 
-	// The user has not performed this like/dislike action before, so let's save it
-	newLikeDislike := &LikeDislike{
-		ProfileID: ld.ProfileID,
-		PostID:    ld.PostID,
-		Action:    ld.Action,
+// FindReaction returns the action of the profile on the post, empty when it has not reacted to it
+func FindReaction(db *gorm.DB, postID, profileID uint) (string, error) {
+	reaction := LikeDislike{}
+	err := db.Debug().Model(&LikeDislike{}).Where("post_id = ? AND profile_id = ?", postID, profileID).Take(&reaction).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
 	}
-	err = db.Create(newLikeDislike).Error
 	if err != nil {
-		return nil, err
+		return "", err
+	}
+	return reaction.Action, nil
+}
+
+// ReactionSummary returns the cached counts of the reactions to the post and the reaction of the profile, the
+// profile is 0 for a reader who is not logged in
+func (p *Post) ReactionSummary(db *gorm.DB, profileID uint) (*ReactionSummary, error) {
+	summary := ReactionSummary{PostID: p.ID, Counts: p.Reactions}
+	if profileID == 0 {
+		return &summary, nil
+	}
+	reaction, err := FindReaction(db, p.ID, profileID)
+	if err != nil {
+		return &ReactionSummary{}, err
+	}
+	summary.Reaction = reaction
+	return &summary, nil
+}
+
+// RefreshReactionCounts counts the reactions to the posts again into their cached counters, every post when
+// none is given
+func RefreshReactionCounts(db *gorm.DB, postIDs ...uint) error {
+	counts := map[string]interface{}{}
+	for action, column := range reactionCountColumns {
+		counts[column] = gorm.Expr("(SELECT COUNT(*) FROM like_dislikes WHERE like_dislikes.post_id = posts.id AND like_dislikes.action = ? AND like_dislikes.deleted_at IS NULL)", action)
 	}
+	query := db.Debug().Unscoped().Model(&Post{})
+	if len(postIDs) > 0 {
+		query = query.Where("id IN ?", postIDs)
+	} else {
+		query = query.Session(&gorm.Session{AllowGlobalUpdate: true})
+	}
+	return query.UpdateColumns(counts).Error
+}
 
-	return newLikeDislike, nil
+// DedupeReactions keeps the last reaction of each profile to a post, changing a reaction used to save it again,
+// so the unique index can be created. It tells if the counters of the posts are still to be backfilled.
+func DedupeReactions(db *gorm.DB) (bool, error) {
+	migrator := db.Migrator()
+	backfill := migrator.HasTable(&Post{}) && !migrator.HasColumn(&Post{}, "like_count")
+	if !migrator.HasTable(&LikeDislike{}) || migrator.HasIndex(&LikeDislike{}, "idx_like_dislikes_post_profile") {
+		return backfill, nil
+	}
+	// the subquery is wrapped for mysql, it does not delete from a table it selects from
+	err := db.Debug().Exec("DELETE FROM like_dislikes WHERE id NOT IN (SELECT id FROM (SELECT MAX(id) AS id FROM like_dislikes GROUP BY post_id, profile_id) AS latest)").Error
+	if err != nil {
+		return false, err
+	}
+	return backfill, nil
 }
 
 func (l *LikeDislike) DeleteLike(db *gorm.DB) (*LikeDislike, error) {
@@ -72,33 +180,53 @@ func (l *LikeDislike) DeleteLike(db *gorm.DB) (*LikeDislike, error) {
 	} else {
 		// If the like exist, save it in deleted like and delete it
 		deletedLike = l
-		db = db.Debug().Model(&LikeDislike{}).Where("id = ?", l.ID).Take(&LikeDislike{}).Delete(&LikeDislike{})
-		if db.Error != nil {
-			fmt.Println("cant delete like", db.Error)
-			return &LikeDislike{}, db.Error
+		result := db.Debug().Model(&LikeDislike{}).Where("id = ?", l.ID).Take(&LikeDislike{}).Delete(&LikeDislike{})
+		if result.Error != nil {
+			fmt.Println("cant delete like", result.Error)
+			return &LikeDislike{}, result.Error
+		}
+		err = RefreshReactionCounts(db, l.PostID)
+		if err != nil {
+			return &LikeDislike{}, err
 		}
 	}
 
 	return deletedLike, nil
 }
 
-func (l *LikeDislike) GetLikesInfo(db *gorm.DB, pid uint) (*[]LikeDislike, error) {
+func (l *LikeDislike) GetLikesInfo(db *gorm.DB, pid uint, pg *pagination.Pagination) (*[]LikeDislike, error) {
 	likeDislikes := []LikeDislike{}
-	err := db.Debug().Model(&LikeDislike{}).Where("post_id = ?", pid).Find(&likeDislikes).Error
+	query, err := pg.Paginate(db.Debug().Model(&LikeDislike{}).Where("post_id = ?", pid), "")
+	if err != nil {
+		return &[]LikeDislike{}, err
+	}
+	err = query.Find(&likeDislikes).Error
 	if err != nil {
 		return &[]LikeDislike{}, err
 	}
+	pg.SetNextCursor(likeDislikes)
 	return &likeDislikes, err
 }
 
-// When a post is deleted, we also delete the likes that the post had
+// When a profile is deleted, we also delete the likes that the profile made, and count the reactions to the
+// posts it reacted to again
 func (l *LikeDislike) DeleteUserLikes(db *gorm.DB, uid uint32) (int64, error) {
-	likes := []LikeDislike{}
-	db = db.Debug().Model(&LikeDislike{}).Where("profile_id = ?", uid).Find(&likes)
-	if db.Error != nil {
-		return 0, db.Error
+	var postIDs []uint
+	err := db.Debug().Model(&LikeDislike{}).Where("profile_id = ?", uid).Pluck("post_id", &postIDs).Error
+	if err != nil {
+		return 0, err
 	}
-	return db.RowsAffected, nil
+	result := db.Debug().Model(&LikeDislike{}).Where("profile_id = ?", uid).Delete(&LikeDislike{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if len(postIDs) > 0 {
+		err = RefreshReactionCounts(db, postIDs...)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return result.RowsAffected, nil
 }
 
 // When a post deleted, we also delete the likes that the post hat
@@ -111,26 +239,7 @@ func (l *LikeDislike) DeletePostLikes(db *gorm.DB, pid uint64) (int64, error) {
 	return db.RowsAffected, nil
 }
 
-// CheckIfDislikedBefore checks if the user has previously disliked a post.
-func CheckIfDislikedBefore(db *gorm.DB, postID, profileID uint) (string, error) {
-	likeDislike := LikeDislike{}
-
-	err := db.Debug().Table("like_dislikes").Where("post_id = ? AND profile_id = ?", postID, profileID).Take(&likeDislike).Error
-	if err == nil {
-		return likeDislike.Action, nil // The user has previously disliked this post
-	} else if errors.Is(err, gorm.ErrRecordNotFound) {
-		return "", nil // The user has not disliked this post before
-	}
-	return "", err
-}
-
-// RemoveDislike removes a dislike entry from the database for a post by a user.
-func RemoveLikeDislike(db *gorm.DB, postID, profileID uint) error {
-	likeDislike := LikeDislike{}
-	return db.Debug().Delete(&likeDislike, "post_id = ? AND profile_id = ?", postID, profileID).Error
-}
-
-func isValidAction(action string) bool {
+func IsValidReaction(action string) bool {
 	switch action {
 	case ActionLike, ActionDislike, ActionHard, ActionSad:
 		return true
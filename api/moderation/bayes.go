@@ -0,0 +1,112 @@
+package moderation
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Bayes is a naive Bayes classifier telling spam from the rest by the words used, trained with the content
+// the admins labelled. It holds nothing until it has seen enough examples of both.
+type Bayes struct {
+	mu         sync.RWMutex
+	threshold  float64
+	minSamples int
+	samples    [2]int
+	words      [2]map[string]int
+	totals     [2]int
+	vocabulary map[string]int
+}
+
+const (
+	ham  = 0
+	spam = 1
+)
+
+func NewBayes(threshold float64, minSamples int) *Bayes {
+	return &Bayes{
+		threshold:  threshold,
+		minSamples: minSamples,
+		words:      [2]map[string]int{make(map[string]int), make(map[string]int)},
+		vocabulary: make(map[string]int),
+	}
+}
+
+// SetThreshold sets the spam probability from which the content is held
+func (b *Bayes) SetThreshold(threshold float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.threshold = threshold
+}
+
+// Train learns from a text labelled as spam or not
+func (b *Bayes) Train(text string, isSpam bool) {
+	b.learn(text, isSpam, 1)
+}
+
+// Untrain forgets a text learned before, when its label is changed
+func (b *Bayes) Untrain(text string, isSpam bool) {
+	b.learn(text, isSpam, -1)
+}
+
+func (b *Bayes) learn(text string, isSpam bool, delta int) {
+	class := ham
+	if isSpam {
+		class = spam
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.samples[class] += delta
+	for _, word := range words(text) {
+		b.words[class][word] += delta
+		b.totals[class] += delta
+		if b.words[class][word] <= 0 {
+			delete(b.words[class], word)
+		}
+		b.vocabulary[word] += delta
+		if b.vocabulary[word] <= 0 {
+			delete(b.vocabulary, word)
+		}
+	}
+}
+
+// SpamProbability tells how likely the text is spam, from 0 to 1
+func (b *Bayes) SpamProbability(text string) float64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.spamProbability(text)
+}
+
+func (b *Bayes) spamProbability(text string) float64 {
+	samples := b.samples[ham] + b.samples[spam]
+	if samples <= 0 || len(b.vocabulary) == 0 {
+		return 0
+	}
+
+	// the Laplace smoothing gives a small probability to the words a class never saw
+	vocabulary := len(b.vocabulary)
+	var scores [2]float64
+	for class := range scores {
+		scores[class] = math.Log(float64(b.samples[class]+1) / float64(samples+2))
+		for _, word := range words(text) {
+			scores[class] += math.Log(float64(b.words[class][word]+1) / float64(b.totals[class]+vocabulary))
+		}
+	}
+	// the probability of spam is 1 / (1 + e^(ham - spam)), computed from the log scores to avoid underflows
+	return 1 / (1 + math.Exp(scores[ham]-scores[spam]))
+}
+
+func (b *Bayes) Check(content Content) (string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.samples[ham] < b.minSamples || b.samples[spam] < b.minSamples {
+		return "", nil
+	}
+	if probability := b.spamProbability(content.Text); probability >= b.threshold {
+		return fmt.Sprintf("looks like spam (%.2f)", probability), nil
+	}
+	return "", nil
+}
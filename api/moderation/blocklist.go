@@ -0,0 +1,45 @@
+package moderation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Blocklist holds the content using one of its words or phrases, whatever their case
+type Blocklist struct {
+	words   map[string]bool
+	phrases []string
+}
+
+func NewBlocklist(entries []string) *Blocklist {
+	b := &Blocklist{words: make(map[string]bool)}
+	for _, entry := range entries {
+		entry = strings.Join(words(entry), " ")
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, " ") {
+			b.phrases = append(b.phrases, entry)
+		} else {
+			b.words[entry] = true
+		}
+	}
+	return b
+}
+
+func (b *Blocklist) Check(content Content) (string, error) {
+	textWords := words(content.Text)
+	for _, word := range textWords {
+		if b.words[word] {
+			return fmt.Sprintf("uses the blocked word %q", word), nil
+		}
+	}
+	// the phrases are matched on whole words, with the punctuation and spacing of the text left out
+	text := " " + strings.Join(textWords, " ") + " "
+	for _, phrase := range b.phrases {
+		if strings.Contains(text, " "+phrase+" ") {
+			return fmt.Sprintf("uses the blocked phrase %q", phrase), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,118 @@
+package moderation
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// duplicateMinWords leaves the short texts out, everyone writes "thanks for the post"
+	duplicateMinWords = 5
+	// duplicateMaxProfiles is how many profiles can save the same text within the window
+	duplicateMaxProfiles = 3
+)
+
+type sighting struct {
+	kind     string
+	id       uint64
+	authorID uint32
+	at       time.Time
+}
+
+// Duplicate holds the content repeating a text saved within the window, by the same profile or by too many
+// profiles. It remembers the texts in memory, so every server instance keeps its own history.
+type Duplicate struct {
+	window    time.Duration
+	mu        sync.Mutex
+	seen      map[[sha256.Size]byte][]sighting
+	lastSweep time.Time
+}
+
+func NewDuplicate(window time.Duration) *Duplicate {
+	return &Duplicate{
+		window:    window,
+		seen:      make(map[[sha256.Size]byte][]sighting),
+		lastSweep: time.Now(),
+	}
+}
+
+// fingerprint is the same for the texts that only differ in their case, spacing or punctuation
+func fingerprint(text string) ([sha256.Size]byte, bool) {
+	textWords := words(text)
+	if len(textWords) < duplicateMinWords {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256([]byte(strings.Join(textWords, " "))), true
+}
+
+func (d *Duplicate) Check(content Content) (string, error) {
+	key, ok := fingerprint(content.Text)
+	if !ok {
+		return "", nil
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	since := time.Now().Add(-d.window)
+	profiles := map[uint32]bool{content.AuthorID: true}
+	for _, s := range d.seen[key] {
+		// saving the same comment or post again is not a duplicate
+		if s.at.Before(since) || (content.ID != 0 && s.kind == content.Kind && s.id == content.ID) {
+			continue
+		}
+		if s.authorID == content.AuthorID {
+			return fmt.Sprintf("repeats a %s saved %s ago", s.kind, time.Since(s.at).Round(time.Second)), nil
+		}
+		profiles[s.authorID] = true
+	}
+	if len(profiles) > duplicateMaxProfiles {
+		return fmt.Sprintf("was saved by %d profiles", len(profiles)), nil
+	}
+	return "", nil
+}
+
+func (d *Duplicate) Record(content Content) {
+	key, ok := fingerprint(content.Text)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(d.lastSweep) > time.Minute {
+		d.sweep(now.Add(-d.window))
+		d.lastSweep = now
+	}
+	if !ok {
+		return
+	}
+
+	// saving a comment or post again with the same text moves its sighting to now
+	sightings := d.seen[key][:0]
+	for _, s := range d.seen[key] {
+		if s.kind != content.Kind || s.id != content.ID {
+			sightings = append(sightings, s)
+		}
+	}
+	d.seen[key] = append(sightings, sighting{kind: content.Kind, id: content.ID, authorID: content.AuthorID, at: now})
+}
+
+// sweep forgets the texts saved before the time
+func (d *Duplicate) sweep(before time.Time) {
+	for key, sightings := range d.seen {
+		kept := sightings[:0]
+		for _, s := range sightings {
+			if !s.at.Before(before) {
+				kept = append(kept, s)
+			}
+		}
+		if len(kept) == 0 {
+			delete(d.seen, key)
+		} else {
+			d.seen[key] = kept
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkLimit holds the content with more links than its kind allows, a kind missing or set to 0 has no limit
+type LinkLimit map[string]int
+
+func (l LinkLimit) Check(content Content) (string, error) {
+	max := l[content.Kind]
+	if max <= 0 {
+		return "", nil
+	}
+	if links := len(linkPattern.FindAllStringIndex(content.Text, -1)); links > max {
+		return fmt.Sprintf("has %d links, %d are allowed", links, max), nil
+	}
+	return "", nil
+}
@@ -0,0 +1,125 @@
+package moderation
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Kinds of content the filters check
+const (
+	KindComment = "comment"
+	KindPost    = "post"
+)
+
+// Content is the text a profile is saving. ID is 0 while the content is created.
+type Content struct {
+	Kind     string
+	ID       uint64
+	AuthorID uint32
+	Text     string
+}
+
+// Filter checks the content saved by the profiles. Flagged content is held for a review instead of being refused,
+// so a filter only tells why it would hold it.
+type Filter interface {
+	// Check returns why the content needs a review, or an empty reason when it can go through
+	Check(content Content) (string, error)
+}
+
+// Recorder is implemented by the filters keeping a history of what was saved, see Saved
+type Recorder interface {
+	Record(content Content)
+}
+
+// Chain runs several filters and holds the content when any of them flags it
+type Chain []Filter
+
+func (c Chain) Check(content Content) (string, error) {
+	reasons := []string{}
+	for _, filter := range c {
+		reason, err := filter.Check(content)
+		if err != nil {
+			return "", err
+		}
+		if reason != "" {
+			reasons = append(reasons, reason)
+		}
+	}
+	return strings.Join(reasons, "; "), nil
+}
+
+func (c Chain) Record(content Content) {
+	for _, filter := range c {
+		if recorder, ok := filter.(Recorder); ok {
+			recorder.Record(content)
+		}
+	}
+}
+
+// Default is the filter the models run when comments and posts are saved, it lets everything through until the
+// server sets it up with FromEnv
+var Default Filter = Chain{}
+
+// Classifier is the spam classifier of the default filters, trained from the content the admins labelled
+var Classifier = NewBayes(0.9, 5)
+
+// Check runs the default filter on the content
+func Check(content Content) (string, error) {
+	return Default.Check(content)
+}
+
+// Saved tells the default filter the content was saved with its id, for the filters keeping a history
+func Saved(content Content) {
+	if recorder, ok := Default.(Recorder); ok {
+		recorder.Record(content)
+	}
+}
+
+// FromEnv returns the built in filters set up from the environment:
+//   - MODERATION_BLOCKLIST, the comma separated words and phrases to hold
+//   - MODERATION_MAX_COMMENT_LINKS and MODERATION_MAX_POST_LINKS, the links allowed (3 and 50, 0 for no limit)
+//   - MODERATION_DUPLICATE_WINDOW, how long a text is remembered to find duplicates (24h)
+//   - MODERATION_SPAM_THRESHOLD, the spam probability from which the classifier holds the content (0.9)
+func FromEnv() Chain {
+	chain := Chain{}
+
+	if words := strings.Split(os.Getenv("MODERATION_BLOCKLIST"), ","); len(words) > 1 || strings.TrimSpace(words[0]) != "" {
+		chain = append(chain, NewBlocklist(words))
+	}
+
+	chain = append(chain, LinkLimit{
+		KindComment: envInt("MODERATION_MAX_COMMENT_LINKS", 3),
+		KindPost:    envInt("MODERATION_MAX_POST_LINKS", 50),
+	})
+
+	window, err := time.ParseDuration(os.Getenv("MODERATION_DUPLICATE_WINDOW"))
+	if err != nil || window <= 0 {
+		window = 24 * time.Hour
+	}
+	chain = append(chain, NewDuplicate(window))
+
+	if threshold, err := strconv.ParseFloat(os.Getenv("MODERATION_SPAM_THRESHOLD"), 64); err == nil && threshold > 0 && threshold <= 1 {
+		Classifier.SetThreshold(threshold)
+	}
+	chain = append(chain, Classifier)
+
+	return chain
+}
+
+func envInt(key string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return fallback
+	}
+	return value
+}
+
+// words splits the text into its lowercased words
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\''
+	})
+}
@@ -29,6 +29,9 @@ func Run() {
 
 	server.Initialize(os.Getenv("DB_DRIVER"), os.Getenv("DB_USER"), os.Getenv("DB_PASSWORD"), os.Getenv("DB_PORT"), os.Getenv("DB_HOST"), os.Getenv("DB_NAME"))
 
+	// publishes the scheduled posts and cleans up the expired tokens
+	server.StartJobs()
+
 	// This is for testing, when done, do well to comment
 	// seed.Load(server.DB)
 
@@ -0,0 +1,115 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local keeps the objects as files under a directory served by the router at BaseURL.
+// The private objects go to PrivateDir, which must not be served.
+type Local struct {
+	Dir        string
+	PrivateDir string
+	BaseURL    string
+}
+
+func NewLocal(dir string, privateDir string, baseURL string) *Local {
+	return &Local{Dir: dir, PrivateDir: privateDir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (l *Local) path(key string) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	if IsPrivate(key) {
+		return filepath.Join(l.PrivateDir, filepath.FromSlash(strings.TrimPrefix(key, PrivatePrefix))), nil
+	}
+	return filepath.Join(l.Dir, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Put(key string, data []byte, contentType string) error {
+	filePath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return errors.New("could not create upload directory")
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return errors.New("could not save file on server")
+	}
+	return nil
+}
+
+func (l *Local) Open(key string) (io.ReadCloser, error) {
+	filePath, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(key string) error {
+	filePath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) List(prefix string) ([]string, error) {
+	keys, err := walkKeys(l.Dir, "", prefix)
+	if err != nil {
+		return nil, err
+	}
+	private, err := walkKeys(l.PrivateDir, PrivatePrefix, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, private...), nil
+}
+
+// walkKeys lists the files under the directory as keys starting with keyPrefix
+func walkKeys(dir string, keyPrefix string, prefix string) ([]string, error) {
+	keys := []string{}
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filePath)
+		if err != nil {
+			return err
+		}
+		if key := keyPrefix + filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (l *Local) URL(key string) string {
+	if IsPrivate(key) {
+		return ""
+	}
+	return l.BaseURL + "/" + strings.TrimPrefix(key, "/")
+}
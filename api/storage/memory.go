@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Object is a file kept by the Memory backend
+type Object struct {
+	Data        []byte
+	ContentType string
+}
+
+// Memory keeps the objects in a map, it is meant for the tests and for running without a disk
+type Memory struct {
+	BaseURL string
+
+	mu      sync.RWMutex
+	objects map[string]Object
+}
+
+func NewMemory(baseURL string) *Memory {
+	return &Memory{BaseURL: strings.TrimSuffix(baseURL, "/"), objects: map[string]Object{}}
+}
+
+func (m *Memory) Put(key string, data []byte, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = Object{Data: append([]byte(nil), data...), ContentType: contentType}
+	return nil
+}
+
+func (m *Memory) Open(key string) (io.ReadCloser, error) {
+	object, ok := m.Object(key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(object.Data)), nil
+}
+
+// Object returns the object stored under the key
+func (m *Memory) Object(key string) (Object, bool) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return Object{}, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	object, ok := m.objects[key]
+	return object, ok
+}
+
+func (m *Memory) Delete(key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, key)
+	return nil
+}
+
+func (m *Memory) List(prefix string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	keys := []string{}
+	for key := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (m *Memory) URL(key string) string {
+	if IsPrivate(key) {
+		return ""
+	}
+	return m.BaseURL + "/" + strings.TrimPrefix(key, "/")
+}
@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3Config locates a bucket on AWS S3 or on a compatible service such as DigitalOcean Spaces or MinIO
+type S3Config struct {
+	Endpoint  string // empty for AWS itself
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PublicURL string // where the bucket is served from, the endpoint and bucket when empty
+}
+
+// S3 keeps the objects in a bucket of an S3 compatible service, they are uploaded as public except the private ones
+type S3 struct {
+	Client  *s3.S3
+	Bucket  string
+	BaseURL string
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, errors.New("storage: S3_BUCKET is required by the s3 backend")
+	}
+	awsConfig := &aws.Config{
+		Credentials: credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""),
+		Region:      aws.String(config.Region),
+	}
+	if config.Endpoint != "" {
+		awsConfig.Endpoint = aws.String(config.Endpoint)
+	}
+	s, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.New(s)
+	baseURL := config.PublicURL
+	if baseURL == "" {
+		baseURL = strings.TrimSuffix(client.Endpoint, "/") + "/" + config.Bucket
+	}
+	return &S3{Client: client, Bucket: config.Bucket, BaseURL: strings.TrimSuffix(baseURL, "/")}, nil
+}
+
+func (b *S3) Put(key string, data []byte, contentType string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	acl := "public-read"
+	if IsPrivate(key) {
+		acl = "private"
+	}
+	_, err = b.Client.PutObject(&s3.PutObjectInput{
+		ACL:           aws.String(acl),
+		Body:          bytes.NewReader(data),
+		Bucket:        aws.String(b.Bucket),
+		ContentLength: aws.Int64(int64(len(data))),
+		ContentType:   aws.String(contentType),
+		Key:           aws.String(key),
+	})
+	return err
+}
+
+func (b *S3) Open(key string) (io.ReadCloser, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+	output, err := b.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(key),
+	})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return output.Body, nil
+}
+
+func (b *S3) Delete(key string) error {
+	key, err := CleanKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = b.Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+func (b *S3) List(prefix string) ([]string, error) {
+	keys := []string{}
+	err := b.Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(b.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			keys = append(keys, aws.StringValue(object.Key))
+		}
+		return true
+	})
+	return keys, err
+}
+
+func (b *S3) URL(key string) string {
+	if IsPrivate(key) {
+		return ""
+	}
+	return b.BaseURL + "/" + strings.TrimPrefix(key, "/")
+}
+
+// Presign returns a url giving access to the object for the ttl without credentials
+func (b *S3) Presign(key string, ttl time.Duration) (string, error) {
+	key, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	req, _ := b.Client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(b.Bucket),
+		Key:    aws.String(key),
+	})
+	return req.Presign(ttl)
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PrivatePrefix starts the keys of the objects that are not served publicly, they are only
+// downloaded through the time limited urls of a URLSigner
+const PrivatePrefix = "private/"
+
+var (
+	ErrURLExpired   = errors.New("storage: the url has expired")
+	ErrURLSignature = errors.New("storage: the url signature is invalid")
+	ErrNoURLSecret  = errors.New("storage: MEDIA_URL_SECRET or API_SECRET is needed to sign the media urls")
+)
+
+// IsPrivate tells if the object stored under the key is not served publicly
+func IsPrivate(key string) bool {
+	return strings.HasPrefix(key, PrivatePrefix)
+}
+
+// Presigner is implemented by the backends able to issue time limited urls themselves
+type Presigner interface {
+	Presign(key string, ttl time.Duration) (string, error)
+}
+
+// URLSigner issues time limited urls for the objects of a backend. Backends implementing Presigner
+// sign their own urls, the others get urls to BaseURL signed with an HMAC of the key and expiry.
+type URLSigner struct {
+	Backend Backend
+	Secret  []byte
+	BaseURL string
+	TTL     time.Duration
+}
+
+// Signer signs the urls of the private objects returned by the API, the server sets it up with its backend
+var Signer *URLSigner
+
+func NewURLSigner(backend Backend, secret string, baseURL string, ttl time.Duration) *URLSigner {
+	return &URLSigner{Backend: backend, Secret: []byte(secret), BaseURL: strings.TrimSuffix(baseURL, "/"), TTL: ttl}
+}
+
+// SignerFromEnv returns a signer for the backend keyed with MEDIA_URL_SECRET, or API_SECRET when it is not set,
+// issuing urls to the media handler valid for MEDIA_URL_TTL (one hour by default). A backend that does not
+// presign its urls needs one of the secrets, anyone could sign urls with an empty key.
+func SignerFromEnv(backend Backend) (*URLSigner, error) {
+	ttl, err := time.ParseDuration(os.Getenv("MEDIA_URL_TTL"))
+	if err != nil || ttl <= 0 {
+		ttl = time.Hour
+	}
+	secret := envOr("MEDIA_URL_SECRET", os.Getenv("API_SECRET"))
+	if _, ok := backend.(Presigner); !ok && secret == "" {
+		return nil, ErrNoURLSecret
+	}
+	return NewURLSigner(backend, secret, "/api/v1/media", ttl), nil
+}
+
+// URL returns a url to the object valid for the TTL of the signer, an empty url when it cannot be signed
+func (s *URLSigner) URL(key string) string {
+	if s == nil || key == "" {
+		return ""
+	}
+	if presigner, ok := s.Backend.(Presigner); ok {
+		url, err := presigner.Presign(key, s.TTL)
+		if err != nil {
+			log.Println("cannot presign the url of", key, err)
+			return ""
+		}
+		return url
+	}
+
+	expires := strconv.FormatInt(time.Now().Add(s.TTL).Unix(), 10)
+	return s.BaseURL + "/" + strings.TrimPrefix(key, "/") + "?expires=" + expires + "&signature=" + s.signature(key, expires)
+}
+
+// Verify checks the expiry and signature sent with a url issued by URL
+func (s *URLSigner) Verify(key string, expires string, signature string) error {
+	if s == nil || len(s.Secret) == 0 {
+		return ErrURLSignature
+	}
+	expiresAt, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrURLSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signature(key, expires))) {
+		return ErrURLSignature
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrURLExpired
+	}
+	return nil
+}
+
+func (s *URLSigner) signature(key string, expires string) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(strings.TrimPrefix(key, "/") + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
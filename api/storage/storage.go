@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// Backend stores the uploaded files. Objects are addressed by slash separated keys such as
+// "posts/4/<uuid>/medium.png", which every backend maps to its own layout.
+type Backend interface {
+	// Put stores the data under the key, replacing any object already there
+	Put(key string, data []byte, contentType string) error
+	// Open reads the object stored under the key, ErrNotFound when there is none
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the object, deleting a missing object is not an error
+	Delete(key string) error
+	// List returns the keys of all the objects starting with the prefix
+	List(prefix string) ([]string, error)
+	// URL returns the address clients download the object from, private objects have none
+	// and are downloaded through a URLSigner
+	URL(key string) string
+}
+
+// CleanKey normalizes a key and refuses the ones escaping the root of the backend
+func CleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + key)[1:]
+	if cleaned == "" || cleaned != strings.TrimPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
+
+// DeletePrefix removes all the objects starting with the prefix
+func DeletePrefix(backend Backend, prefix string) error {
+	keys, err := backend.List(prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := backend.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FromEnv returns the backend named by STORAGE_BACKEND: "local" (the default), "s3" or "memory"
+func FromEnv() (Backend, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		return NewLocal(envOr("STORAGE_LOCAL_DIR", "static/uploads"), envOr("STORAGE_LOCAL_PRIVATE_DIR", "storage/private"), envOr("STORAGE_LOCAL_URL", "/static/uploads")), nil
+	case "s3":
+		return NewS3(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			PublicURL: os.Getenv("S3_PUBLIC_URL"),
+		})
+	case "memory":
+		return NewMemory("/static/uploads"), nil
+	default:
+		return nil, fmt.Errorf("storage: unknown backend %q", backend)
+	}
+}
+
+func envOr(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,153 @@
+package imageformat
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif" // registers the gif decoder
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // registers the webp decoder
+)
+
+const (
+	jpegQuality = 85
+	// images above this many pixels are refused before being decoded, they would take too much memory
+	maxPixels = 40000000
+)
+
+var (
+	ErrUnsupportedImage = errors.New("only jpeg, png, gif and webp images are supported")
+	ErrImageTooLarge    = errors.New("the image dimensions are too large")
+)
+
+// supportedTypes are the content types an upload may be sniffed as
+var supportedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// Variant is a size every uploaded image is resized to, an image smaller than Width keeps its size
+type Variant struct {
+	Name  string
+	Width int
+}
+
+// Variants are the sizes generated for every upload, the WebP version is made from the largest one
+var Variants = []Variant{
+	{Name: "thumbnail", Width: 320},
+	{Name: "medium", Width: 768},
+	{Name: "large", Width: 1600},
+}
+
+// WebPVariant is the name of the WebP version of an upload
+const WebPVariant = "webp"
+
+// Image is one encoded version of an upload
+type Image struct {
+	Name        string
+	ContentType string
+	Ext         string
+	Width       int
+	Height      int
+	Data        []byte
+}
+
+// DetectType sniffs the content type of the data, the name and headers sent by the client are not trusted
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	if !supportedTypes[contentType] {
+		return "", ErrUnsupportedImage
+	}
+	return contentType, nil
+}
+
+// Process decodes an uploaded image and encodes all its variants and its WebP version.
+// The variants keep the format of the upload, except gif and webp ones which become png.
+func Process(data []byte) ([]Image, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, ErrImageTooLarge
+	}
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	images := []Image{}
+	var largest image.Image
+	for _, variant := range Variants {
+		resized := Resize(source, variant.Width)
+		encoded, err := encode(resized, contentType)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Name = variant.Name
+		images = append(images, encoded)
+		largest = resized
+	}
+
+	var buf bytes.Buffer
+	err = nativewebp.Encode(&buf, largest, nil)
+	if err != nil {
+		return nil, err
+	}
+	bounds := largest.Bounds()
+	images = append(images, Image{
+		Name:        WebPVariant,
+		ContentType: "image/webp",
+		Ext:         ".webp",
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Data:        buf.Bytes(),
+	})
+	return images, nil
+}
+
+// Resize scales the image down to the width, keeping its aspect ratio. Images are never scaled up.
+func Resize(source image.Image, width int) image.Image {
+	bounds := source.Bounds()
+	if bounds.Dx() <= width {
+		return source
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Over, nil)
+	return resized
+}
+
+func encode(img image.Image, contentType string) (Image, error) {
+	var buf bytes.Buffer
+	encoded := Image{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+		encoded.ContentType, encoded.Ext = "image/jpeg", ".jpg"
+	} else {
+		err = png.Encode(&buf, img)
+		encoded.ContentType, encoded.Ext = "image/png", ".png"
+	}
+	if err != nil {
+		return Image{}, err
+	}
+	encoded.Data = buf.Bytes()
+	return encoded, nil
+}
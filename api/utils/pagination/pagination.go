@@ -0,0 +1,172 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+
+	SortNewest = "newest"
+	SortOldest = "oldest"
+)
+
+var (
+	ErrInvalidLimit  = errors.New("limit should be a number between 1 and 100")
+	ErrInvalidPage   = errors.New("page should be a number greater than 0")
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrInvalidSort   = errors.New("sort should be newest or oldest")
+)
+
+// Pagination holds the paging params of a list request and the metadata sent back with the list.
+// A cursor pages on the row id, so it keeps working while new rows are added,
+// a page is a plain offset and is only used when no cursor is given.
+// Lists ordered on something else than the id (search results) use PaginateOrdered, their cursor holds the next offset.
+type Pagination struct {
+	Limit      int    `json:"limit"`
+	Page       int    `json:"page,omitempty"`
+	Sort       string `json:"sort"`
+	Total      int64  `json:"total"`
+	NextCursor string `json:"next_cursor"`
+
+	cursor     uint64
+	ordered    bool
+	nextOffset int
+}
+
+// New validates the raw query params, empty values fall back to the defaults
+func New(limit, page, cursor, sort string) (*Pagination, error) {
+	p := &Pagination{Limit: DefaultLimit, Sort: SortNewest}
+
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil || l < 1 || l > MaxLimit {
+			return nil, ErrInvalidLimit
+		}
+		p.Limit = l
+	}
+
+	if sort != "" {
+		if sort != SortNewest && sort != SortOldest {
+			return nil, ErrInvalidSort
+		}
+		p.Sort = sort
+	}
+
+	if cursor != "" {
+		id, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		p.cursor = id
+		return p, nil
+	}
+
+	if page != "" {
+		pg, err := strconv.Atoi(page)
+		if err != nil || pg < 1 {
+			return nil, ErrInvalidPage
+		}
+		p.Page = pg
+	}
+	return p, nil
+}
+
+// Default is used by the callers that do not get the params from a request
+func Default() *Pagination {
+	p, _ := New("", "", "", "")
+	return p
+}
+
+// Paginate counts every row matched by query into Total, then returns query ordered and limited to the current page.
+// idColumn is the column the rows are sorted and paged on, it defaults to id and only needs a table prefix on joins.
+// query should not be ordered yet, the order is set here.
+func (p *Pagination) Paginate(query *gorm.DB, idColumn string) (*gorm.DB, error) {
+	err := query.Session(&gorm.Session{}).Count(&p.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if idColumn == "" {
+		idColumn = "id"
+	}
+
+	query = query.Session(&gorm.Session{}).Limit(p.Limit)
+	if p.Sort == SortOldest {
+		query = query.Order(idColumn + " asc")
+		if p.cursor != 0 {
+			query = query.Where(idColumn+" > ?", p.cursor)
+		}
+	} else {
+		query = query.Order(idColumn + " desc")
+		if p.cursor != 0 {
+			query = query.Where(idColumn+" < ?", p.cursor)
+		}
+	}
+
+	if p.cursor == 0 && p.Page > 1 {
+		query = query.Offset((p.Page - 1) * p.Limit)
+	}
+	return query, nil
+}
+
+// PaginateOrdered counts every row matched by query into Total, then returns query sorted by order and limited to the current page
+func (p *Pagination) PaginateOrdered(query *gorm.DB, order string) (*gorm.DB, error) {
+	err := query.Session(&gorm.Session{}).Count(&p.Total).Error
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	if p.cursor != 0 {
+		offset = int(p.cursor)
+	} else if p.Page > 1 {
+		offset = (p.Page - 1) * p.Limit
+	}
+	p.ordered = true
+	p.nextOffset = offset + p.Limit
+
+	return query.Session(&gorm.Session{}).Order(order).Limit(p.Limit).Offset(offset), nil
+}
+
+// SetNextCursor is called with the rows loaded for the page, a slice (or a pointer to one) of structs with an ID field.
+// A full page means there may be more rows, so the cursor points after the last one.
+func (p *Pagination) SetNextCursor(rows interface{}) {
+	p.NextCursor = ""
+
+	v := reflect.Indirect(reflect.ValueOf(rows))
+	if v.Kind() != reflect.Slice || v.Len() == 0 || v.Len() < p.Limit {
+		return
+	}
+	if p.ordered {
+		p.NextCursor = encodeCursor(uint64(p.nextOffset))
+		return
+	}
+	id := reflect.Indirect(v.Index(v.Len() - 1)).FieldByName("ID")
+	if !id.IsValid() || !id.CanUint() || id.Uint() == 0 {
+		return
+	}
+	p.NextCursor = encodeCursor(id.Uint())
+}
+
+func encodeCursor(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(id, 10)))
+}
+
+func decodeCursor(cursor string) (uint64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
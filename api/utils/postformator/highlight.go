@@ -0,0 +1,94 @@
+package postformator
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+const (
+	HighlightStart = "<mark>"
+	HighlightStop  = "</mark>"
+
+	// the matches are marked with these private use characters while the text is still raw, they become the mark
+	// tags once the text is escaped
+	SentinelStart = "\uE000"
+	SentinelStop  = "\uE001"
+
+	maxSearchTerms = 10
+)
+
+// SearchTerms splits a search query in lowercase unique words
+func SearchTerms(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		terms = append(terms, term)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+	return terms
+}
+
+var stripSentinels = strings.NewReplacer(SentinelStart, "", SentinelStop, "")
+
+// EscapeHighlight escapes the text as HTML and turns the sentinels around its matches into mark tags
+func EscapeHighlight(text string) string {
+	return strings.NewReplacer(SentinelStart, HighlightStart, SentinelStop, HighlightStop).Replace(html.EscapeString(text))
+}
+
+// Highlight escapes text as HTML and wraps every search term found in it in a mark tag.
+// With maxWords above 0 only a snippet of that many words around the first match is kept.
+func Highlight(text string, terms []string, maxWords int) string {
+	text = stripSentinels.Replace(text)
+	if len(terms) == 0 {
+		return html.EscapeString(text)
+	}
+	quoted := make([]string, len(terms))
+	for i, term := range terms {
+		quoted[i] = regexp.QuoteMeta(term)
+	}
+	reg := regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+
+	if maxWords > 0 {
+		words := strings.Fields(text)
+		if len(words) > maxWords {
+			first := 0
+			for i, word := range words {
+				if reg.MatchString(word) {
+					first = i
+					break
+				}
+			}
+
+			// keep a bit of context before the first match
+			start := first - maxWords/3
+			if start < 0 {
+				start = 0
+			}
+			end := start + maxWords
+			if end > len(words) {
+				end = len(words)
+				start = end - maxWords
+			}
+
+			snippet := strings.Join(words[start:end], " ")
+			if start > 0 {
+				snippet = "... " + snippet
+			}
+			if end < len(words) {
+				snippet = snippet + " ..."
+			}
+			text = snippet
+		}
+	}
+
+	return EscapeHighlight(reg.ReplaceAllStringFunc(text, func(match string) string {
+		return SentinelStart + match + SentinelStop
+	}))
+}
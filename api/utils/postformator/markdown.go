@@ -0,0 +1,51 @@
+package postformator
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// markdown renders CommonMark with the GFM tables, strikethrough, autolinks and task lists.
+// Headings get an id, the anchors of the table of contents.
+// Raw HTML is kept by the renderer because everything it outputs goes through the sanitizer.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+)
+
+// sanitizer is the allowlist of what rendered posts may contain, anything else is dropped
+var sanitizer = newSanitizer()
+
+func newSanitizer() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	// the language of fenced code blocks, for syntax highlighting on the client
+	policy.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	// GFM task lists
+	policy.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	policy.AllowAttrs("checked", "disabled").OnElements("input")
+	policy.RequireNoFollowOnLinks(true)
+	return policy
+}
+
+// RenderMarkdown turns the markdown of a post in sanitized HTML, safe to be served as is
+func RenderMarkdown(source string) string {
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(source), &buf); err != nil {
+		// rendering in memory does not fail, but if it ever does the text is still readable
+		return "<p>" + html.EscapeString(source) + "</p>"
+	}
+	return SanitizeHTML(buf.String())
+}
+
+// SanitizeHTML removes every element and attribute that is not on the allowlist
+func SanitizeHTML(unsafe string) string {
+	return sanitizer.Sanitize(unsafe)
+}
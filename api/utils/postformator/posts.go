@@ -1,7 +1,6 @@
 package postformator
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 )
@@ -31,20 +30,14 @@ func CreatePostPermalinks(title string) string {
 	return permalinks
 }
 
+// CalculateReadingTime estimates the reading time of markdown content, e.g. "5 min read"
 func CalculateReadingTime(content string) string {
-	// Calculate the number of words in the content
-	words := strings.Fields(content)
-	wordCount := len(words)
-
-	// Assuming an average reading speed of 225 words per minute
-	// You can adjust this value based on your preference or requirement
-	wordsPerMinute := 225
-
-	// Calculate the estimated reading time in minutes
-	minutes := wordCount / wordsPerMinute
-
-	// Format the reading time as a string (e.g., "5 min read")
-	readingTime := fmt.Sprintf("%d min read", minutes)
+	return Summarize(content, 0).ReadTime()
+}
 
-	return readingTime
+// CreateTagSlug normalizes a tag name so "Go Lang", "go-lang" and "go_lang" share the same tag
+func CreateTagSlug(name string) string {
+	reg := regexp.MustCompile(`[^\p{L}\p{N}]+`)
+	slug := reg.ReplaceAllString(strings.ToLower(strings.TrimSpace(name)), "-")
+	return strings.Trim(slug, "-")
 }
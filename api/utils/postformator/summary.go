@@ -0,0 +1,154 @@
+package postformator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+)
+
+const (
+	// an average reading speed, code is read at half of it
+	wordsPerMinute     = 225
+	codeWordsPerMinute = wordsPerMinute / 2
+
+	// the first image of a post is looked at for 12 seconds, every next one a second less down to 3 seconds
+	firstImageSeconds = 12
+	minImageSeconds   = 3
+
+	excerptEllipsis = "…"
+)
+
+// Heading is an entry of the table of contents of a post, Anchor is the id of the heading in the rendered HTML
+type Heading struct {
+	Level  int    `json:"level"`
+	Text   string `json:"text"`
+	Anchor string `json:"anchor"`
+}
+
+// Summary is what is derived from the markdown of a post besides its HTML
+type Summary struct {
+	TOC            []Heading
+	Excerpt        string
+	WordCount      int
+	CodeWordCount  int
+	ImageCount     int
+	ReadingMinutes int
+}
+
+// ReadTime formats the reading time of the summary, e.g. "5 min read"
+func (s Summary) ReadTime() string {
+	return fmt.Sprintf("%d min read", s.ReadingMinutes)
+}
+
+// Summarize reads the markdown of a post for its table of contents, its excerpt of at most excerptWords words,
+// its word count and its reading time. The words of code blocks and image captions are not part of the word count,
+// they only make the reading time longer.
+func Summarize(source string, excerptWords int) Summary {
+	src := []byte(source)
+	doc := markdown.Parser().Parse(text.NewReader(src))
+
+	summary := Summary{TOC: []Heading{}}
+	var body strings.Builder
+	var heading strings.Builder
+	var current *ast.Heading
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch node := n.(type) {
+		case *ast.Heading:
+			if entering {
+				current = node
+				heading.Reset()
+				return ast.WalkContinue, nil
+			}
+			title := strings.Join(strings.Fields(heading.String()), " ")
+			entry := Heading{Level: node.Level, Text: title}
+			if id, ok := node.AttributeString("id"); ok {
+				if anchor, ok := id.([]byte); ok {
+					entry.Anchor = string(anchor)
+				}
+			}
+			summary.TOC = append(summary.TOC, entry)
+			summary.WordCount += len(strings.Fields(title))
+			current = nil
+			return ast.WalkContinue, nil
+		case *ast.FencedCodeBlock, *ast.CodeBlock:
+			if entering {
+				lines := n.Lines()
+				for i := 0; i < lines.Len(); i++ {
+					segment := lines.At(i)
+					summary.CodeWordCount += len(strings.Fields(string(segment.Value(src))))
+				}
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.Image:
+			if entering {
+				summary.ImageCount++
+			}
+			return ast.WalkSkipChildren, nil
+		case *ast.HTMLBlock, *ast.RawHTML:
+			return ast.WalkSkipChildren, nil
+		case *ast.Text:
+			if !entering {
+				return ast.WalkContinue, nil
+			}
+			value := string(node.Segment.Value(src))
+			if node.SoftLineBreak() || node.HardLineBreak() {
+				value += " "
+			}
+			if current != nil {
+				heading.WriteString(value)
+			} else {
+				body.WriteString(value)
+			}
+			return ast.WalkContinue, nil
+		case *ast.String:
+			if entering {
+				if current != nil {
+					heading.Write(node.Value)
+				} else {
+					body.Write(node.Value)
+				}
+			}
+			return ast.WalkContinue, nil
+		case *ast.AutoLink:
+			if entering && current == nil {
+				body.Write(node.Label(src))
+			}
+			return ast.WalkSkipChildren, nil
+		}
+
+		// blocks are separated in the plain text, so the last word of one is not glued to the first of the next
+		if !entering && n.Type() == ast.TypeBlock && current == nil {
+			body.WriteString(" ")
+		}
+		return ast.WalkContinue, nil
+	})
+
+	words := strings.Fields(body.String())
+	summary.WordCount += len(words)
+	summary.Excerpt = excerpt(words, excerptWords)
+	summary.ReadingMinutes = readingMinutes(summary.WordCount, summary.CodeWordCount, summary.ImageCount)
+	return summary
+}
+
+func excerpt(words []string, maxWords int) string {
+	if maxWords <= 0 || len(words) == 0 {
+		return ""
+	}
+	if len(words) <= maxWords {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:maxWords], " ") + excerptEllipsis
+}
+
+// readingMinutes is rounded up, a post with any content takes at least a minute
+func readingMinutes(words, codeWords, images int) int {
+	seconds := float64(words)*60/wordsPerMinute + float64(codeWords)*60/codeWordsPerMinute
+	for i := 0; i < images; i++ {
+		seconds += math.Max(firstImageSeconds-float64(i), minImageSeconds)
+	}
+	return int(math.Ceil(seconds / 60))
+}
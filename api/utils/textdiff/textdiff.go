@@ -0,0 +1,203 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells bounds the memory of the line matching, bigger changes are shown as a whole replacement
+const maxCells = 4000000
+
+type opKind byte
+
+const (
+	opEqual  opKind = ' '
+	opDelete opKind = '-'
+	opInsert opKind = '+'
+)
+
+type op struct {
+	kind opKind
+	line string
+	// line numbers in a and b, starting at 0
+	aLine, bLine int
+}
+
+// Unified returns the unified diff of the lines of a and b with context lines around every change,
+// it is empty when both texts are the same
+func Unified(aName, bName, a, b string, context int) string {
+	ops := diffLines(splitLines(a), splitLines(b))
+
+	hunks := groupHunks(ops, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for _, hunk := range hunks {
+		aStart, aCount, bStart, bCount := hunkRange(hunk)
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", formatRange(aStart, aCount), formatRange(bStart, bCount))
+		for _, o := range hunk {
+			out.WriteByte(byte(o.kind))
+			out.WriteString(o.line)
+			out.WriteByte('\n')
+		}
+	}
+	return out.String()
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines matches the lines with a longest common subsequence, after trimming the common prefix and suffix
+func diffLines(a, b []string) []op {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]op, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, op{opEqual, a[i], i, i})
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	for _, o := range diffMiddle(midA, midB) {
+		o.aLine += prefix
+		o.bLine += prefix
+		ops = append(ops, o)
+	}
+
+	for i := 0; i < suffix; i++ {
+		ai := len(a) - suffix + i
+		bi := len(b) - suffix + i
+		ops = append(ops, op{opEqual, a[ai], ai, bi})
+	}
+	return ops
+}
+
+func diffMiddle(a, b []string) []op {
+	ops := []op{}
+	if len(a)*len(b) > maxCells {
+		for i, line := range a {
+			ops = append(ops, op{opDelete, line, i, 0})
+		}
+		for j, line := range b {
+			ops = append(ops, op{opInsert, line, len(a), j})
+		}
+		return ops
+	}
+
+	// lcs[i][j] is the length of the common subsequence of a[i:] and b[j:]
+	lcs := make([][]int32, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, op{opEqual, a[i], i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, op{opDelete, a[i], i, j})
+			i++
+		default:
+			ops = append(ops, op{opInsert, b[j], i, j})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, op{opDelete, a[i], i, j})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, op{opInsert, b[j], i, j})
+	}
+	return ops
+}
+
+// groupHunks keeps the changes with up to context equal lines around them, close changes share a hunk
+func groupHunks(ops []op, context int) [][]op {
+	hunks := [][]op{}
+	var current []op
+	lastChange := -1
+
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		if current != nil && start <= lastChange+context+1 {
+			// extend the current hunk up to this change
+			current = append(current, ops[lastChange+1:i+1]...)
+		} else {
+			if current != nil {
+				hunks = append(hunks, closeHunk(current, ops, lastChange, context))
+			}
+			current = append([]op{}, ops[start:i+1]...)
+		}
+		lastChange = i
+	}
+	if current != nil {
+		hunks = append(hunks, closeHunk(current, ops, lastChange, context))
+	}
+	return hunks
+}
+
+func closeHunk(hunk []op, ops []op, lastChange, context int) []op {
+	end := lastChange + 1 + context
+	if end > len(ops) {
+		end = len(ops)
+	}
+	return append(hunk, ops[lastChange+1:end]...)
+}
+
+func hunkRange(hunk []op) (aStart, aCount, bStart, bCount int) {
+	aStart, bStart = hunk[0].aLine, hunk[0].bLine
+	for _, o := range hunk {
+		if o.kind != opInsert {
+			aCount++
+		}
+		if o.kind != opDelete {
+			bCount++
+		}
+	}
+	return aStart, aCount, bStart, bCount
+}
+
+// formatRange writes a hunk range the way diff -u does, an empty range points at the line before it
+func formatRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,65 @@
+package textdiff_test
+
+import (
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/utils/textdiff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b string
+		diff string
+	}{
+		{
+			name: "insert",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nx\nc\n",
+			diff: "--- old\n+++ new\n@@ -1,3 +1,4 @@\n a\n b\n+x\n c\n",
+		},
+		{
+			name: "delete",
+			a:    "a\nb\nc\n",
+			b:    "a\nc\n",
+			diff: "--- old\n+++ new\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name: "replace",
+			a:    "a\nb\nc\n",
+			b:    "a\nB\nc\n",
+			diff: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "from empty",
+			a:    "",
+			b:    "a\nb\n",
+			diff: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "to empty",
+			a:    "a\nb\n",
+			b:    "",
+			diff: "--- old\n+++ new\n@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "both empty",
+			a:    "",
+			b:    "",
+			diff: "",
+		},
+		{
+			name: "unchanged",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			diff: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.diff, textdiff.Unified("old", "new", tc.a, tc.b, 3))
+		})
+	}
+}
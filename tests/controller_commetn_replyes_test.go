@@ -29,10 +29,6 @@ func TestCommnetReplyePost(t *testing.T) {
 	// Author token and authPostID
 	firstUserToken, secondUserToken, secondCommentID, _, firstPostID := getUserTokensAndPostIDForCommentReplyes()
 
-	err = refreshReplyeTable()
-	if err != nil {
-		log.Fatal(err)
-	}
 	// Get test samples for updating post and iterate over them.
 	samples := testdata.CreateCommentReplyeSamples(firstUserToken, secondUserToken, secondCommentID, uint(firstPostID))
 	ExecuteCreateCommentReplyes(t, samples, &server)
@@ -3,6 +3,8 @@ package tests
 import (
 	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 
@@ -11,6 +13,7 @@ import (
 	executeablefunctions "github.com/Mdromi/exp-blog-backend/tests/executeable_functions"
 	"github.com/Mdromi/exp-blog-backend/tests/testdata"
 	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
 )
 
 var ExecuteCreateComments = executeablefunctions.ExecuteCreateComments
@@ -137,6 +140,37 @@ func TestDeleteComment(t *testing.T) {
 
 }
 
+func TestCommentsOfADraftAreHidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	err := refreshAllTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	post, _, _, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Cannot seed tables %v\n", err)
+	}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", post.ID).UpdateColumn("status", models.PostStatusDraft).Error
+	if err != nil {
+		log.Fatalf("Cannot make the post a draft %v\n", err)
+	}
+
+	r := gin.Default()
+	r.GET("/comments/:id", server.GetComments)
+	r.GET("/likes/:id", server.GetLikes)
+	r.GET("/posts/:id/reactions", server.GetReactions)
+
+	// a reader cannot tell a draft from a post that does not exist
+	postID := strconv.Itoa(int(post.ID))
+	for _, path := range []string{"/comments/" + postID, "/likes/" + postID, "/posts/" + postID + "/reactions"} {
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, http.StatusNotFound, path)
+	}
+}
+
 func getUserTokensAndPostIDForComments() (string, string, uint) {
 	var firstUserEmail, secondUserEmail string
 	var firstPostID uint
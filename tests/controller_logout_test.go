@@ -0,0 +1,110 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/middlewares"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := seedOneUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tokenInterface, err := server.SignIn(user.Email, "password")
+	if err != nil {
+		log.Fatalf("cannot login: %v\n", err)
+	}
+	tokenString := fmt.Sprintf("Bearer %v", tokenInterface["token"])
+
+	r := gin.Default()
+	r.POST("/logout", middlewares.TokenAuthMiddleware(), server.Logout)
+	r.GET("/users/:id", middlewares.TokenAuthMiddleware(), server.GetUser)
+
+	req, _ := http.NewRequest(http.MethodPost, "/logout", nil)
+	req.Header.Set("Authorization", tokenString)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// The same token must be rejected once logged out
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", tokenString)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	responseInterface := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &responseInterface)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+	}
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+	errorResponse := responseInterface["error"].(map[string]interface{})
+	assert.Equal(t, errorResponse["Token_revoked"], "Token has been revoked")
+}
+
+func TestLogoutAllSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := seedOneUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	firstLogin, err := server.SignIn(user.Email, "password")
+	if err != nil {
+		log.Fatalf("cannot login: %v\n", err)
+	}
+	secondLogin, err := server.SignIn(user.Email, "password")
+	if err != nil {
+		log.Fatalf("cannot login: %v\n", err)
+	}
+
+	r := gin.Default()
+	r.POST("/logout/all", middlewares.TokenAuthMiddleware(), server.LogoutAllSessions)
+	r.GET("/users/:id", middlewares.TokenAuthMiddleware(), server.GetUser)
+
+	req, _ := http.NewRequest(http.MethodPost, "/logout/all", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", firstLogin["token"]))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	// The other session is logged out as well
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", secondLogin["token"]))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusUnauthorized)
+
+	// logging in again right away gives a token that works
+	newLogin, err := server.SignIn(user.Email, "password")
+	if err != nil {
+		log.Fatalf("cannot login: %v\n", err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, fmt.Sprintf("/users/%d", user.ID), nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", newLogin["token"]))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+}
@@ -52,7 +52,7 @@ func ExecuteCreateCommentReplyes(t *testing.T, samples []testdata.CreateCommentR
 			// 	t.Errorf("Cannot convert to uint: %v", err)
 			// }
 
-			assert.Equal(t, responseMap["comment_id"], commentIDFloat)
+			assert.Equal(t, responseMap["parent_id"], commentIDFloat)
 			assert.Equal(t, responseMap["post_id"], float64(v.PostID))
 			assert.Equal(t, responseMap["profile_id"], float64(v.ProfileID))
 			assert.Equal(t, responseMap["body"], v.Body)
@@ -142,7 +142,7 @@ func ExecuteUpdateCommentReplye(t *testing.T, samples []testdata.UpdateCommentRe
 				t.Errorf("Cannot convert to uint: %v", err)
 			}
 
-			assert.Equal(t, responseMap["comment_id"], commentIDFloat)
+			assert.Equal(t, responseMap["parent_id"], commentIDFloat)
 			assert.Equal(t, responseMap["post_id"], v.PostID)
 			assert.Equal(t, responseMap["body"], v.Body)
 		}
@@ -72,6 +72,8 @@ func ExecuteGetPostByID(t *testing.T, samples []testdata.GetPostByIdTestCase, se
 			responseMap := responseInterface["response"].(map[string]interface{})
 			assert.Equal(t, responseMap["title"], v.Title)
 			assert.Equal(t, responseMap["content"], v.Content)
+			assert.Equal(t, responseMap["content_markdown"], v.Content)
+			assert.Contains(t, responseMap, "content_html")
 			assert.Equal(t, responseMap["author_id"], float64(v.Author_id))
 		}
 		if v.StatusCode == 400 || v.StatusCode == 404 {
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuspendAndRestorePost(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	_, post, err := seedOneUserProfileAndOnePost()
+	if err != nil {
+		log.Fatalf("Cannot seed profile and post %v\n", err)
+	}
+
+	rowsAffected, err := models.SuspendRecord(server.DB, &models.Post{}, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error suspending the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, rowsAffected, int64(1))
+
+	// A suspended post is hidden from the public finders
+	_, err = postInstance.FindPostById(server.DB, uint64(post.ID))
+	assert.NotNil(t, err)
+
+	suspended := []models.Post{}
+	err = models.AdminFind(server.DB, &models.Post{}, &suspended, models.AdminFilter{Status: models.StatusSuspended}, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error listing the suspended posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(suspended), 1)
+
+	_, err = models.RestoreRecord(server.DB, &models.Post{}, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error restoring the post: %v\n", err)
+		return
+	}
+	foundPost, err := postInstance.FindPostById(server.DB, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error getting the restored post: %v\n", err)
+		return
+	}
+	assert.Equal(t, foundPost.ID, post.ID)
+}
+
+func TestSaveAuditLog(t *testing.T) {
+	err := server.DB.Migrator().DropTable(&models.AuditLog{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = server.DB.AutoMigrate(&models.AuditLog{})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	auditLog := models.AuditLog{
+		ActorID:    1,
+		Action:     models.AuditActionSuspend,
+		TargetType: "post",
+		TargetID:   7,
+		Details:    "spam",
+	}
+	_, err = auditLog.SaveAuditLog(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the audit log: %v\n", err)
+		return
+	}
+
+	auditLogs, err := auditLog.FindAuditLogs(server.DB, "post", 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the audit logs: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*auditLogs), 1)
+	assert.Equal(t, (*auditLogs)[0].Details, "spam")
+}
+
+func TestAdminListLeavesThePasswordsOut(t *testing.T) {
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	_, err = seedUsers()
+	if err != nil {
+		log.Fatalf("Cannot seed users %v\n", err)
+	}
+
+	users := []models.User{}
+	err = models.AdminFind(server.DB, &models.User{}, &users, models.AdminFilter{Omit: []string{"password"}}, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error listing the users: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(users), 2)
+	for _, user := range users {
+		assert.Equal(t, user.Password, "")
+		assert.NotEqual(t, user.Email, "")
+	}
+}
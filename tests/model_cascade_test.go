@@ -0,0 +1,93 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeletePostCascade(t *testing.T) {
+	err := refreshUserProfilePostAndCommentReplyeTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post, comment and replye table %v\n", err)
+	}
+	post, _, _, _, err := seedUsersProfilePostsAndCommentReplyes()
+	if err != nil {
+		log.Fatalf("Error seeding user, post, comment and replye table %v\n", err)
+	}
+
+	err = post.DeletePostCascade(server.DB)
+	if err != nil {
+		t.Errorf("this is the error deleting the post: %v\n", err)
+		return
+	}
+
+	var comments, replyes int64
+	server.DB.Model(&models.Comment{}).Where("post_id = ?", post.ID).Count(&comments)
+	server.DB.Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replyes)
+	assert.Equal(t, comments, int64(0))
+	assert.Equal(t, replyes, int64(0))
+
+	// the rows are soft deleted with the post, until it is deleted for good
+	server.DB.Unscoped().Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replyes)
+	assert.Equal(t, replyes, int64(2))
+
+	err = post.DeletePostCascade(server.DB.Unscoped())
+	if err != nil {
+		t.Errorf("this is the error deleting the post for good: %v\n", err)
+		return
+	}
+	server.DB.Unscoped().Model(&models.Comment{}).Where("post_id = ?", post.ID).Count(&comments)
+	server.DB.Unscoped().Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replyes)
+	assert.Equal(t, comments, int64(0))
+	assert.Equal(t, replyes, int64(0))
+}
+
+func TestHardDeleteProfileCascade(t *testing.T) {
+	err := refreshUserProfilePostAndCommentTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post and comment table %v\n", err)
+	}
+	profile, post, err := seedOneUserProfileAndOnePost()
+	if err != nil {
+		log.Fatalf("Cannot seed profile and post %v\n", err)
+	}
+
+	series := models.Series{Title: "The tutorial", ProfileID: profile.ID, PostIDs: []uint{post.ID}}
+	_, err = series.SaveSeries(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the series: %v\n", err)
+		return
+	}
+	media := []models.MediaObject{
+		{Key: "profiles/1/profilePic/me.png", Size: 10, OwnerType: models.MediaOwnerProfilePic, OwnerID: profile.ID},
+		{Key: "private/posts/1/a/medium.png", Size: 10, OwnerType: models.MediaOwnerPost, OwnerID: post.ID},
+	}
+	for i := range media {
+		_, err = media[i].SaveMediaObject(server.DB)
+		if err != nil {
+			t.Errorf("this is the error saving the media object: %v\n", err)
+			return
+		}
+	}
+
+	err = profile.DeleteProfileCascade(models.HardDeleteDB(server.DB), uint32(profile.ID))
+	if err != nil {
+		t.Errorf("this is the error deleting the profile for good: %v\n", err)
+		return
+	}
+
+	var profiles, posts, seriesCount, seriesPosts, released int64
+	server.DB.Unscoped().Model(&models.Profile{}).Where("id = ?", profile.ID).Count(&profiles)
+	server.DB.Unscoped().Model(&models.Post{}).Where("author_id = ?", profile.ID).Count(&posts)
+	server.DB.Model(&models.Series{}).Where("profile_id = ?", profile.ID).Count(&seriesCount)
+	server.DB.Model(&models.SeriesPost{}).Where("series_id = ?", series.ID).Count(&seriesPosts)
+	server.DB.Model(&models.MediaObject{}).Where("released_at IS NOT NULL").Count(&released)
+	assert.Equal(t, profiles, int64(0))
+	assert.Equal(t, posts, int64(0))
+	assert.Equal(t, seriesCount, int64(0))
+	assert.Equal(t, seriesPosts, int64(0))
+	assert.Equal(t, released, int64(2))
+}
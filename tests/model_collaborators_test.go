@@ -0,0 +1,74 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostCollaborators(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profiles, err := seedUsersProfiles()
+	if err != nil {
+		log.Fatalf("Cannot seed profiles %v\n", err)
+	}
+	author, invited := profiles[0], profiles[1]
+
+	draft := models.Post{Title: "The draft", Content: "the content", AuthorID: author.ID, Status: models.PostStatusDraft}
+	draft.Prepare()
+	_, err = draft.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the draft: %v\n", err)
+		return
+	}
+
+	role, err := draft.RoleOf(server.DB, author.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, role, models.CollaboratorOwner)
+
+	collaborator := models.PostCollaborator{ProfileID: invited.ID, Role: models.CollaboratorEditor, InvitedBy: author.ID}
+	invitation, err := collaborator.InviteCollaborator(server.DB, &draft)
+	if err != nil {
+		t.Errorf("this is the error inviting the collaborator: %v\n", err)
+		return
+	}
+
+	_, err = collaborator.InviteCollaborator(server.DB, &draft)
+	assert.ErrorIs(t, err, models.ErrCollaboratorExists)
+
+	// the invitation gives no role until it is accepted
+	role, err = draft.RoleOf(server.DB, invited.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, role, "")
+	visible, err := draft.VisibleTo(server.DB, invited.ID)
+	assert.Nil(t, err)
+	assert.False(t, visible)
+
+	_, err = invitation.AcceptInvitation(server.DB)
+	if err != nil {
+		t.Errorf("this is the error accepting the invitation: %v\n", err)
+		return
+	}
+
+	role, err = draft.RoleOf(server.DB, invited.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, role, models.CollaboratorEditor)
+	assert.True(t, models.CanEditPost(role))
+	assert.False(t, models.CanManagePost(role))
+
+	// the draft is listed for its collaborators
+	posts, err := postInstance.FindAllPosts(server.DB, invited.ID, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 1)
+	assert.Equal(t, (*posts)[0].ID, draft.ID)
+}
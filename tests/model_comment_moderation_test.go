@@ -0,0 +1,138 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeldCommentWaitsForApproval(t *testing.T) {
+	err := refreshUserProfilePostAndCommentTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post and comment table %v\n", err)
+	}
+	post, profiles, _, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Error seeding user, post and comment table %v\n", err)
+	}
+
+	// the author of the post holds the comments on its posts
+	err = server.DB.Model(&models.Profile{}).Where("id = ?", post.AuthorID).UpdateColumn("moderate_comments", true).Error
+	if err != nil {
+		log.Fatalf("cannot update the profile: %v", err)
+	}
+
+	comment := models.Comment{
+		Body:      "This comment waits for the author",
+		ProfileID: uint32(profiles[1].ID),
+		PostID:    uint64(post.ID),
+	}
+	held, err := comment.SaveComment(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the comment: %v\n", err)
+		return
+	}
+	assert.Equal(t, held.Status, models.CommentStatusPending)
+
+	// the comments of the author are not held
+	own := models.Comment{
+		Body:      "The author answers right away",
+		ProfileID: uint32(post.AuthorID),
+		PostID:    uint64(post.ID),
+	}
+	saved, err := own.SaveComment(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the comment: %v\n", err)
+		return
+	}
+	assert.Equal(t, saved.Status, models.CommentStatusApproved)
+
+	comments, err := comment.GetComments(server.DB, uint64(post.ID), pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the comments: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*comments), 3)
+
+	queue, err := models.FindModerationQueue(server.DB, uint32(post.AuthorID), models.CommentStatusPending, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the queue: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*queue), 1)
+	assert.Equal(t, (*queue)[0].ID, held.ID)
+
+	_, err = held.SetCommentStatus(server.DB, models.CommentStatusApproved)
+	if err != nil {
+		t.Errorf("this is the error approving the comment: %v\n", err)
+		return
+	}
+	comments, err = comment.GetComments(server.DB, uint64(post.ID), pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the comments: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*comments), 4)
+}
+
+func TestPostSettingOverridesTheAuthor(t *testing.T) {
+	err := refreshUserProfilePostAndCommentTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post and comment table %v\n", err)
+	}
+	post, profiles, _, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Error seeding user, post and comment table %v\n", err)
+	}
+
+	err = server.DB.Model(&models.Profile{}).Where("id = ?", post.AuthorID).UpdateColumn("moderate_comments", true).Error
+	if err != nil {
+		log.Fatalf("cannot update the profile: %v", err)
+	}
+
+	// the post lets the comments through even though its author holds them
+	moderate := false
+	post.ModerateComments = &moderate
+
+	held, err := post.HoldsComments(server.DB, uint32(profiles[1].ID))
+	assert.Nil(t, err)
+	assert.False(t, held)
+
+	post.ModerateComments = nil
+	held, err = post.HoldsComments(server.DB, uint32(profiles[1].ID))
+	assert.Nil(t, err)
+	assert.True(t, held)
+}
+
+func TestPostSettingGoesBackToTheAuthor(t *testing.T) {
+	err := refreshUserProfilePostAndCommentTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post and comment table %v\n", err)
+	}
+	post, _, _, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Error seeding user, post and comment table %v\n", err)
+	}
+
+	moderate := false
+	post.ModerateComments = &moderate
+	_, err = post.UpdateAPost(server.DB)
+	if err != nil {
+		t.Fatalf("this is the error updating the post: %v\n", err)
+	}
+
+	// the post stops overriding its author
+	post.ModerateComments = nil
+	_, err = post.UpdateAPost(server.DB)
+	if err != nil {
+		t.Fatalf("this is the error updating the post: %v\n", err)
+	}
+
+	foundPost := models.Post{}
+	err = server.DB.Model(&models.Post{}).Where("id = ?", post.ID).Take(&foundPost).Error
+	assert.Nil(t, err)
+	assert.Nil(t, foundPost.ModerateComments)
+}
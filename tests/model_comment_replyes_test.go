@@ -2,9 +2,11 @@ package tests
 
 import (
 	"log"
+	"strings"
 	"testing"
 
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,26 +32,38 @@ func TestCreateCommentReplye(t *testing.T) {
 		if v.ID == 2 {
 			continue
 		}
-		commentInstance.ID = v.ID //commentInstance is defined in setup_test.go
+		commentInstance = v //commentInstance is defined in setup_test.go
 	}
 
-	newCommentReplye := models.Replyes{
+	parentID := uint64(commentInstance.ID)
+	newCommentReplye := models.Comment{
 		Body:      "This is the comment replye body",
-		CommentID: uint64(commentInstance.ID),
-		ProfileID: uint64(profile.ID),
-		PostID:    uint32(post.ID),
+		ParentID:  &parentID,
+		ProfileID: uint32(profile.ID),
+		PostID:    uint64(post.ID),
 	}
 
-	savedCommentReplye, err := newCommentReplye.SaveCommentReplyes(server.DB)
+	savedCommentReplye, err := newCommentReplye.SaveComment(server.DB)
 	if err != nil {
 		t.Errorf("this is the error saved the comment replye: %v\n", err)
 		return
 	}
 
-	assert.Equal(t, newCommentReplye.ProfileID, savedCommentReplye.ProfileID)
-	assert.Equal(t, newCommentReplye.CommentID, savedCommentReplye.CommentID)
+	assert.Equal(t, *savedCommentReplye.ParentID, parentID)
+	assert.Equal(t, savedCommentReplye.Depth, 1)
+	assert.True(t, strings.HasPrefix(savedCommentReplye.Path, commentInstance.Path))
 	assert.Equal(t, newCommentReplye.PostID, savedCommentReplye.PostID)
 	assert.Equal(t, newCommentReplye.Body, savedCommentReplye.Body)
+
+	// a reply goes on the post of its comment
+	wrongPost := models.Comment{
+		Body:      "This is the comment replye body",
+		ParentID:  &parentID,
+		ProfileID: uint32(profile.ID),
+		PostID:    uint64(post.ID) + 100,
+	}
+	_, err = wrongPost.SaveComment(server.DB)
+	assert.ErrorIs(t, err, models.ErrCommentParentNotFound)
 }
 
 func TestCommentReplyeForAPost(t *testing.T) {
@@ -63,8 +77,8 @@ func TestCommentReplyeForAPost(t *testing.T) {
 		log.Fatalf("Error seeding user, post and comment replye table %v\n", err)
 	}
 
-	//Where commentInstance is an instance of the post initialize in setup_test.go
-	_, err = commentReplyesInstance.GetCommentReplyes(server.DB, uint64(comment.ID))
+	//Where commentInstance is an instance of the comment initialize in setup_test.go
+	threads, err := commentInstance.GetComments(server.DB, uint64(post.ID), pagination.Default())
 	if err != nil {
 		t.Errorf("this is the error getting the comments: %v\n", err)
 		return
@@ -73,38 +87,46 @@ func TestCommentReplyeForAPost(t *testing.T) {
 	assert.Equal(t, len(replyes), 2)
 	assert.Equal(t, len(profiles), 2)
 
-	for i, v := range replyes {
-		assert.Equal(t, v.PostID, uint32(post.ID))
-		assert.Equal(t, v.CommentID, uint64(comment.ID))
-		assert.Equal(t, v.ProfileID, uint64(profiles[i].ID))
+	for _, v := range *threads {
+		if v.ID != comment.ID {
+			assert.Equal(t, v.ReplyCount, int64(0))
+			continue
+		}
+		assert.Equal(t, v.ReplyCount, int64(2))
+		assert.Equal(t, len(v.Replies), 2)
+		for i, r := range v.Replies {
+			assert.Equal(t, r.PostID, uint64(post.ID))
+			assert.Equal(t, *r.ParentID, uint64(comment.ID))
+			assert.Equal(t, r.ProfileID, uint32(profiles[i].ID))
+		}
+	}
+
+	// the replies of one comment
+	replies, err := commentInstance.GetCommentThreads(server.DB, uint64(post.ID), uint64(comment.ID), 1, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the replies: %v\n", err)
+		return
 	}
+	assert.Equal(t, len(*replies), 2)
 }
 
-func TestDeleteACommentReplye(t *testing.T) {
+func TestDeleteACommentWithItsReplyes(t *testing.T) {
 	err := refreshUserProfilePostAndCommentReplyeTable()
 	if err != nil {
 		log.Fatalf("Error refreshing user, post, comment, and replye table %v\n", err)
 	}
 
-	_, _, _, replyes, err := seedUsersProfilePostsAndCommentReplyes()
+	_, _, comment, _, err := seedUsersProfilePostsAndCommentReplyes()
 	if err != nil {
 		log.Fatalf("Error seeding user, post and comment replye table %v\n", err)
 	}
 
-	// Delete the first comment
-	for _, v := range replyes {
-		if v.ID == 2 {
-			continue
-		}
-		commentReplyesInstance.ID = v.ID //commentInstance is defined in setup_test.go
-	}
-
-	isDeleted, err := commentReplyesInstance.DeleteAReplyes(server.DB)
+	isDeleted, err := comment.DeleteAComment(server.DB)
 	if err != nil {
 		t.Errorf("this is the error deleting the comment: %v\n", err)
 		return
 	}
-	assert.Equal(t, isDeleted, int64(1))
+	assert.Equal(t, isDeleted, int64(3))
 }
 
 func TestDeleteCommentReplyesForAPost(t *testing.T) {
@@ -118,12 +140,17 @@ func TestDeleteCommentReplyesForAPost(t *testing.T) {
 		log.Fatalf("Error seeding user, post and comment replye table %v\n", err)
 	}
 
-	numberDeleted, err := commentReplyesInstance.DeletePostCommentReplyes(server.DB, uint64(post.ID))
+	// the two comments go with their two replies
+	numberDeleted, err := commentInstance.DeletePostComments(server.DB, uint64(post.ID))
 	if err != nil {
-		t.Errorf("this is the error deleting the like: %v\n", err)
+		t.Errorf("this is the error deleting the replyes: %v\n", err)
 		return
 	}
-	assert.Equal(t, numberDeleted, int64(2))
+	assert.Equal(t, numberDeleted, int64(4))
+
+	var replies int64
+	server.DB.Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replies)
+	assert.Equal(t, replies, int64(0))
 }
 
 func TestDeleteCommentReplyeForAUser(t *testing.T) {
@@ -133,12 +160,12 @@ func TestDeleteCommentReplyeForAUser(t *testing.T) {
 		log.Fatalf("Error refreshing user, post, comment, and replye table %v\n", err)
 	}
 
-	_, profiles, _, _, err := seedUsersProfilePostsAndCommentReplyes()
+	post, profiles, _, _, err := seedUsersProfilePostsAndCommentReplyes()
 	if err != nil {
 		log.Fatalf("Error seeding user, post and comment replye table %v\n", err)
 	}
 
-	// get the first user. When you delete this user, also delete his comment
+	// get the first user. When you delete this user, also delete his replye
 	for _, v := range profiles {
 		if v.ID == 2 {
 			continue
@@ -146,10 +173,58 @@ func TestDeleteCommentReplyeForAUser(t *testing.T) {
 		profileID = uint32(v.ID)
 	}
 
-	numberDeleted, err := commentReplyesInstance.DeleteUserProfileCommentReplyes(server.DB, profileID)
+	numberDeleted, err := commentInstance.DeleteUserComments(server.DB, profileID)
 	if err != nil {
-		t.Errorf("this is the error deleting the comment: %v\n", err)
+		t.Errorf("this is the error deleting the replye: %v\n", err)
 		return
 	}
 	assert.Equal(t, numberDeleted, int64(1))
+
+	var replies int64
+	server.DB.Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replies)
+	assert.Equal(t, replies, int64(1))
+}
+
+func TestMigrateCommentReplyes(t *testing.T) {
+	err := refreshUserProfilePostAndCommentReplyeTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post, comment, and replye table %v\n", err)
+	}
+
+	post, _, comments, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Error seeding user, post and comment table %v\n", err)
+	}
+
+	// a replye saved in the table used before the threads
+	err = server.DB.AutoMigrate(&models.Replyes{})
+	if err != nil {
+		log.Fatalf("cannot migrate the replyes table: %v", err)
+	}
+	replye := models.Replyes{
+		Body:      "This is the old replye body",
+		CommentID: uint64(comments[0].ID),
+		ProfileID: uint64(comments[1].ProfileID),
+		PostID:    uint32(post.ID),
+	}
+	err = server.DB.Create(&replye).Error
+	if err != nil {
+		log.Fatalf("cannot seed the replyes table: %v", err)
+	}
+
+	err = models.MigrateCommentThreads(server.DB)
+	if err != nil {
+		t.Errorf("this is the error migrating the replyes: %v\n", err)
+		return
+	}
+
+	migrated := models.Comment{}
+	err = server.DB.Model(&models.Comment{}).Where("parent_id = ?", comments[0].ID).Take(&migrated).Error
+	if err != nil {
+		t.Errorf("this is the error getting the migrated replye: %v\n", err)
+		return
+	}
+	assert.Equal(t, migrated.Body, replye.Body)
+	assert.Equal(t, migrated.Depth, 1)
+	assert.False(t, server.DB.Migrator().HasTable(&models.Replyes{}))
 }
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -45,7 +46,7 @@ func TestCommentsForAPost(t *testing.T) {
 		log.Fatalf("Error seeding user, post and comment table %v\n", err)
 	}
 	//Where commentInstance is an instance of the post initialize in setup_test.go
-	_, err = commentInstance.GetComments(server.DB, uint64(post.ID))
+	_, err = commentInstance.GetComments(server.DB, uint64(post.ID), pagination.Default())
 	if err != nil {
 		t.Errorf("this is the error getting the comments: %v\n", err)
 		return
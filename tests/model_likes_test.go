@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -41,6 +42,63 @@ func TestSaveALike(t *testing.T) {
 	}
 }
 
+func TestChangeAReaction(t *testing.T) {
+	err := refreshUserProfilePostAndLikeTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile, post and like table %v\n", err)
+	}
+
+	profile, post, err := seedOneUserProfileAndOnePost()
+	if err != nil {
+		log.Fatalf("Cannot seed profile and post %v\n", err)
+	}
+
+	// reacting again, with the same action or another one, keeps one reaction
+	for _, action := range []string{models.ActionLike, models.ActionDislike, models.ActionDislike} {
+		reaction := models.LikeDislike{ProfileID: profile.ID, PostID: post.ID, Action: action}
+		_, err = reaction.SaveLike(server.DB)
+		if err != nil {
+			t.Errorf("this is the error saving the %s: %v\n", action, err)
+			return
+		}
+	}
+	var reactions int64
+	server.DB.Unscoped().Model(&models.LikeDislike{}).Where("post_id = ? AND profile_id = ?", post.ID, profile.ID).Count(&reactions)
+	assert.Equal(t, reactions, int64(1))
+
+	reacted := models.Post{}
+	server.DB.Model(&models.Post{}).Where("id = ?", post.ID).Take(&reacted)
+	summary, err := reacted.ReactionSummary(server.DB, profile.ID)
+	if err != nil {
+		t.Errorf("this is the error getting the reactions: %v\n", err)
+		return
+	}
+	assert.Equal(t, summary.Counts, models.ReactionCounts{Dislike: 1})
+	assert.Equal(t, summary.Reaction, models.ActionDislike)
+
+	_, err = models.RemoveReaction(server.DB, post.ID, profile.ID)
+	if err != nil {
+		t.Errorf("this is the error removing the reaction: %v\n", err)
+		return
+	}
+	server.DB.Model(&models.Post{}).Where("id = ?", post.ID).Take(&reacted)
+	assert.Equal(t, reacted.Reactions, models.ReactionCounts{})
+
+	// the removed reaction comes back with the new action
+	reaction := models.LikeDislike{ProfileID: profile.ID, PostID: post.ID, Action: models.ActionSad}
+	saved, err := reaction.SaveLike(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the reaction again: %v\n", err)
+		return
+	}
+	assert.Equal(t, saved.Action, models.ActionSad)
+	server.DB.Unscoped().Model(&models.LikeDislike{}).Where("post_id = ? AND profile_id = ?", post.ID, profile.ID).Count(&reactions)
+	assert.Equal(t, reactions, int64(1))
+
+	_, err = (&models.LikeDislike{ProfileID: profile.ID, PostID: post.ID, Action: "love"}).SaveLike(server.DB)
+	assert.NotNil(t, err)
+}
+
 func TestGetLikeInfoForAPost(t *testing.T) {
 	err := refreshUserProfilePostAndLikeTable()
 	if err != nil {
@@ -51,7 +109,7 @@ func TestGetLikeInfoForAPost(t *testing.T) {
 		log.Fatalf("Error seeding user, post and like table %v\n", err)
 	}
 	// Where likeInstance is an instance of the post initialize in setup_test.go
-	_, err = likeInstance.GetLikesInfo(server.DB, post.ID)
+	_, err = likeInstance.GetLikesInfo(server.DB, post.ID, pagination.Default())
 	if err != nil {
 		t.Errorf("this is the error getting the likes: %v\n", err)
 		return
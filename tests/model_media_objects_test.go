@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReleaseMediaObjects(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	keys := []string{"avatars/1/first.png", "avatars/1/second.png", "profiles/1/coverPic/cover.png"}
+	owners := []string{models.MediaOwnerAvatar, models.MediaOwnerAvatar, models.MediaOwnerCoverPic}
+	for i, key := range keys {
+		object := models.MediaObject{Key: key, ContentType: "image/png", Size: 10, OwnerType: owners[i], OwnerID: 1}
+		_, err = object.SaveMediaObject(server.DB)
+		if err != nil {
+			t.Errorf("this is the error saving the media object: %v\n", err)
+			return
+		}
+	}
+
+	mediaObject := models.MediaObject{}
+	// the second avatar replaced the first one
+	released, err := mediaObject.ReleaseReplacedMediaObjects(server.DB, 1, models.MediaOwnerAvatar)
+	assert.Nil(t, err)
+	assert.Equal(t, released, int64(1))
+
+	objects, err := mediaObject.FindReleasedMediaObjects(server.DB, time.Now().Add(time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, len(*objects), 1)
+	assert.Equal(t, (*objects)[0].Key, "avatars/1/first.png")
+
+	// nothing is old enough for the grace period yet
+	objects, err = mediaObject.FindReleasedMediaObjects(server.DB, time.Now().Add(-time.Hour), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, len(*objects), 0)
+
+	// deleting the profile releases all its files
+	released, err = mediaObject.ReleaseMediaObjects(server.DB, 1, models.MediaOwnerAvatar, models.MediaOwnerProfilePic, models.MediaOwnerCoverPic)
+	assert.Nil(t, err)
+	assert.Equal(t, released, int64(2))
+}
+
+func TestGetMediaOrphans(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	releasedAt := time.Now().Add(-models.MediaGracePeriod() - time.Hour)
+	objects := []models.MediaObject{
+		{Key: "avatars/2/old.png", Size: 100, OwnerType: models.MediaOwnerAvatar, OwnerID: 2, ReleasedAt: &releasedAt},
+		{Key: "avatars/2/new.png", Size: 100, OwnerType: models.MediaOwnerAvatar, OwnerID: 2},
+	}
+	for _, object := range objects {
+		err = server.Storage.Put(object.Key, []byte("image"), "image/png")
+		if err != nil {
+			t.Fatalf("this is the error storing the object: %v\n", err)
+		}
+		_, err = object.SaveMediaObject(server.DB)
+		if err != nil {
+			t.Fatalf("this is the error saving the media object: %v\n", err)
+		}
+	}
+
+	r := gin.Default()
+	r.GET("/admin/media/orphans", server.GetMediaOrphans)
+	req, err := http.NewRequest(http.MethodGet, "/admin/media/orphans", nil)
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusOK)
+
+	responseInterface := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &responseInterface)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+		return
+	}
+	orphans := responseInterface["response"].([]interface{})
+	assert.Equal(t, len(orphans), 1)
+	assert.Equal(t, orphans[0].(map[string]interface{})["key"], "avatars/2/old.png")
+
+	// a dry run removes nothing
+	keys, err := server.Storage.List("avatars/2/")
+	assert.Nil(t, err)
+	assert.Equal(t, len(keys), 2)
+}
+
+func TestPostponeMediaObject(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	releasedAt := time.Now().Add(-models.MediaGracePeriod() - time.Hour)
+	stuck := models.MediaObject{Key: "avatars/3/stuck.png", Size: 10, OwnerType: models.MediaOwnerAvatar, OwnerID: 3, ReleasedAt: &releasedAt}
+	_, err = stuck.SaveMediaObject(server.DB)
+	if err != nil {
+		t.Fatalf("this is the error saving the media object: %v\n", err)
+	}
+
+	// a file the storage failed to delete waits for another grace period, behind the others
+	postponed, err := stuck.PostponeMediaObject(server.DB)
+	assert.Nil(t, err)
+	assert.Equal(t, postponed, int64(1))
+
+	mediaObject := models.MediaObject{}
+	objects, err := mediaObject.FindReleasedMediaObjects(server.DB, time.Now().Add(-models.MediaGracePeriod()), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, len(*objects), 0)
+}
+
+func TestTrackMediaObject(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	// the media sweep found a file no record uses
+	now := time.Now()
+	found := models.MediaObject{Key: "avatars/4/upload.png", OwnerType: models.MediaOwnerNone, ReleasedAt: &now}
+	tracked, err := found.TrackMediaObject(server.DB)
+	assert.Nil(t, err)
+	assert.Equal(t, tracked, int64(1))
+
+	// finding it again changes nothing
+	again := models.MediaObject{Key: "avatars/4/upload.png", OwnerType: models.MediaOwnerNone}
+	tracked, err = again.TrackMediaObject(server.DB)
+	assert.Nil(t, err)
+	assert.Equal(t, tracked, int64(0))
+
+	// the upload it belonged to takes it over
+	upload := models.MediaObject{Key: "avatars/4/upload.png", ContentType: "image/png", Size: 10, OwnerType: models.MediaOwnerAvatar, OwnerID: 4}
+	_, err = upload.SaveMediaObject(server.DB)
+	assert.Nil(t, err)
+
+	mediaObject := models.MediaObject{}
+	objects, err := mediaObject.FindReleasedMediaObjects(server.DB, time.Now().Add(time.Minute), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, len(*objects), 0)
+
+	released, err := mediaObject.ReleaseMediaObjectKeys(server.DB, []string{"avatars/4/upload.png"})
+	assert.Nil(t, err)
+	assert.Equal(t, released, int64(1))
+}
+
+func TestFindMediaReferences(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+	_, post, err := seedOneUserProfileAndOnePost()
+	if err != nil {
+		log.Fatalf("Cannot seed user, profile and post %v\n", err)
+	}
+
+	media := models.PostMedia{PostID: post.ID, ProfileID: post.AuthorID, Variants: []models.MediaVariant{{Name: "medium", Key: "private/posts/1/a/medium.png"}}}
+	_, err = media.SavePostMedia(server.DB)
+	if err != nil {
+		t.Fatalf("this is the error saving the post media: %v\n", err)
+	}
+	// a trashed post can be restored, its files are still in use
+	_, err = post.DeleteAPost(server.DB)
+	if err != nil {
+		t.Fatalf("this is the error deleting the post: %v\n", err)
+	}
+
+	references, err := models.FindMediaReferences(server.DB)
+	assert.Nil(t, err)
+	assert.Contains(t, references, models.MediaReference{Path: "private/posts/1/a/medium.png", OwnerType: models.MediaOwnerPost, OwnerID: post.ID})
+}
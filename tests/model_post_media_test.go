@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/imageformat"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessImage(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1000, 500)))
+	if err != nil {
+		t.Fatalf("cannot encode the image: %v\n", err)
+	}
+
+	images, err := imageformat.Process(buf.Bytes())
+	if err != nil {
+		t.Errorf("this is the error processing the image: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(images), len(imageformat.Variants)+1)
+	assert.Equal(t, images[0].Name, "thumbnail")
+	assert.Equal(t, images[0].Width, 320)
+	assert.Equal(t, images[0].Height, 160)
+	assert.Equal(t, images[0].ContentType, "image/png")
+	// the image is smaller than the large variant and keeps its size
+	assert.Equal(t, images[2].Width, 1000)
+	assert.Equal(t, images[3].Name, imageformat.WebPVariant)
+	assert.Equal(t, images[3].ContentType, "image/webp")
+
+	_, err = imageformat.Process([]byte("<html><body>not an image</body></html>"))
+	assert.ErrorIs(t, err, imageformat.ErrUnsupportedImage)
+}
+
+func TestSavePostMedia(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profiles, posts, err := seedUsersProfileAndPosts()
+	if err != nil {
+		log.Fatalf("Cannot seed profiles and posts %v\n", err)
+	}
+	post := posts[0]
+
+	media := models.PostMedia{
+		PostID:      post.ID,
+		ProfileID:   profiles[0].ID,
+		ContentType: "image/png",
+		Variants: []models.MediaVariant{
+			{Name: "medium", Key: "private/posts/1/a/medium.png", ContentType: "image/png", Width: 768, Height: 384},
+		},
+	}
+	_, err = media.SavePostMedia(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the media: %v\n", err)
+		return
+	}
+
+	assert.True(t, strings.HasPrefix(media.Variant("medium").URL, "/api/v1/media/private/posts/1/a/medium.png?expires="))
+
+	err = post.SetThumbnail(server.DB, media.Variant("medium").Key)
+	assert.Nil(t, err)
+
+	found, err := postInstance.FindPostById(server.DB, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error getting the post: %v\n", err)
+		return
+	}
+	// the urls of the private media are signed again when the post is loaded
+	assert.True(t, strings.HasPrefix(found.Thumbnails, "/api/v1/media/private/posts/1/a/medium.png?expires="))
+	assert.Equal(t, len(found.Media), 1)
+	assert.Equal(t, found.Media[0].Variants[0].Width, 768)
+	assert.Equal(t, strings.Split(found.Media[0].Variants[0].URL, "?")[0], strings.Split(found.Thumbnails, "?")[0])
+}
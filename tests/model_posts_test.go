@@ -2,9 +2,13 @@ package tests
 
 import (
 	"log"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/Mdromi/exp-blog-backend/api/utils/postformator"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -20,7 +24,7 @@ func TestFindAllPosts(t *testing.T) {
 	}
 
 	// Where postInstance is an instance of the post initialize in setup_test.go
-	posts, err := postInstance.FindAllPosts(server.DB)
+	posts, err := postInstance.FindAllPosts(server.DB, 0, pagination.Default())
 	if err != nil {
 		t.Errorf("this is the error getting the posts: %v\n", err)
 		return
@@ -34,6 +38,47 @@ func TestFindAllPosts(t *testing.T) {
 	}
 }
 
+func TestFindAllPostsPaginated(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	_, seededPosts, err := seedUsersProfileAndPosts()
+	if err != nil {
+		log.Fatalf("Error seeding user and post table %v\n", err)
+	}
+
+	pg, err := pagination.New("1", "", "", "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	posts, err := postInstance.FindAllPosts(server.DB, 0, pg)
+	if err != nil {
+		t.Errorf("this is the error getting the first page: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 1)
+	assert.Equal(t, pg.Total, int64(2))
+	assert.Equal(t, (*posts)[0].ID, seededPosts[1].ID)
+	assert.NotEqual(t, pg.NextCursor, "")
+
+	next, err := pagination.New("1", "", pg.NextCursor, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	posts, err = postInstance.FindAllPosts(server.DB, 0, next)
+	if err != nil {
+		t.Errorf("this is the error getting the second page: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 1)
+	assert.Equal(t, (*posts)[0].ID, seededPosts[0].ID)
+
+	_, err = pagination.New("500", "", "", "")
+	assert.NotNil(t, err)
+}
+
 func TestSavePost(t *testing.T) {
 	err := refreshUserProfileAndPostTable()
 	if err != nil {
@@ -173,3 +218,363 @@ func TestDeleteUserPosts(t *testing.T) {
 		log.Fatal(err)
 	}
 }
+
+func TestSearchPosts(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	_, seededPosts, err := seedUsersProfileAndPosts()
+	if err != nil {
+		log.Fatalf("Error seeding user and post table %v\n", err)
+	}
+
+	results, err := postInstance.SearchPosts(server.DB, models.PostSearch{Query: "world 2"}, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error searching the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*results), 1)
+	assert.Equal(t, (*results)[0].ID, seededPosts[1].ID)
+	assert.Contains(t, (*results)[0].ContentHighlight, "<mark>")
+
+	// the author filter combines with the query
+	results, err = postInstance.SearchPosts(server.DB, models.PostSearch{Query: "hello", AuthorID: seededPosts[0].AuthorID}, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error searching the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*results), 1)
+	assert.Equal(t, (*results)[0].ID, seededPosts[0].ID)
+
+	// the highlights are HTML, what the author wrote in the post is escaped in them
+	post := models.Post{
+		Title:    "Scripted",
+		Content:  "<script>alert(1)</script> a searchable script",
+		AuthorID: seededPosts[0].AuthorID,
+	}
+	post.Prepare()
+	_, err = post.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+	results, err = postInstance.SearchPosts(server.DB, models.PostSearch{Query: "searchable"}, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error searching the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*results), 1)
+	assert.NotContains(t, (*results)[0].ContentHighlight, "<script>")
+	assert.Contains(t, (*results)[0].ContentHighlight, "<mark>")
+
+	// the tags are searched as well, the search index is made once the tables are there
+	err = models.MigratePostSearch(server.DB)
+	assert.Nil(t, err)
+	err = seededPosts[0].SyncTags(server.DB, []string{"Gopher"})
+	assert.Nil(t, err)
+	results, err = postInstance.SearchPosts(server.DB, models.PostSearch{Query: "gopher"}, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error searching the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*results), 1)
+	assert.Equal(t, (*results)[0].ID, seededPosts[0].ID)
+}
+
+func TestPostPermalinks(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("Cannot seed profile %v\n", err)
+	}
+
+	// both titles give the same slug
+	first := models.Post{Title: "Hello, World", Content: "the content", AuthorID: profile.ID}
+	_, err = first.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+	second := models.Post{Title: "Hello World!", Content: "the content", AuthorID: profile.ID}
+	_, err = second.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, first.PostPermalinks, "hello-world")
+	assert.Equal(t, second.PostPermalinks, "hello-world-2")
+
+	// the old permalink still finds the renamed post
+	second.Title = "Goodbye World"
+	_, err = second.UpdateAPost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error updating the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, second.PostPermalinks, "goodbye-world")
+
+	foundPost, err := postInstance.FindPostBySlug(server.DB, "hello-world-2")
+	if err != nil {
+		t.Errorf("this is the error getting the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, foundPost.ID, second.ID)
+	assert.Equal(t, foundPost.PostPermalinks, "goodbye-world")
+}
+
+func TestPostLifecycle(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("Cannot seed profile %v\n", err)
+	}
+
+	draft := models.Post{Title: "The draft", Content: "the content", AuthorID: profile.ID, Status: models.PostStatusDraft}
+	draft.Prepare()
+	_, err = draft.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the draft: %v\n", err)
+		return
+	}
+
+	publishAt := time.Now().Add(-time.Minute)
+	scheduled := models.Post{Title: "The scheduled post", Content: "the content", AuthorID: profile.ID, Status: models.PostStatusScheduled, PublishAt: &publishAt}
+	_, err = scheduled.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the scheduled post: %v\n", err)
+		return
+	}
+
+	// readers see neither of them, the author sees both
+	posts, err := postInstance.FindAllPosts(server.DB, 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 0)
+
+	posts, err = postInstance.FindAllPosts(server.DB, profile.ID, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 2)
+
+	published, err := models.PublishDuePosts(server.DB)
+	if err != nil {
+		t.Errorf("this is the error publishing the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, published, int64(1))
+
+	posts, err = postInstance.FindAllPosts(server.DB, 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 1)
+	assert.Equal(t, (*posts)[0].ID, scheduled.ID)
+}
+
+func TestPostRevisions(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("Cannot seed profile %v\n", err)
+	}
+
+	post := models.Post{Title: "The title", Content: "first line\nsecond line", Tags: []string{"go"}, AuthorID: profile.ID}
+	_, err = post.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+	post.Content = "first line\nchanged line"
+	_, err = post.UpdateAPost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error updating the post: %v\n", err)
+		return
+	}
+
+	revision := models.PostRevision{}
+	revisions, err := revision.FindPostRevisions(server.DB, uint64(post.ID), pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the revisions: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*revisions), 2)
+
+	first := models.PostRevision{}
+	_, err = first.FindPostRevision(server.DB, uint64(post.ID), 1)
+	if err != nil {
+		t.Errorf("this is the error getting the revision: %v\n", err)
+		return
+	}
+	second := models.PostRevision{}
+	_, err = second.FindPostRevision(server.DB, uint64(post.ID), 2)
+	if err != nil {
+		t.Errorf("this is the error getting the revision: %v\n", err)
+		return
+	}
+	assert.Equal(t, first.Content, "first line\nsecond line")
+	assert.Equal(t, first.Tags, []string{"go"})
+
+	diff := first.Diff(&second)
+	assert.Contains(t, diff, "-second line")
+	assert.Contains(t, diff, "+changed line")
+}
+
+func TestPostMarkdown(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("Cannot seed profile %v\n", err)
+	}
+
+	post := models.Post{
+		Title:    "Markdown",
+		Content:  "Some **bold** \"quoted\" text <script>alert(1)</script>\n\n```go\nif a < b {}\n```\n\n| a | b |\n|---|---|\n| 1 | 2 |",
+		AuthorID: profile.ID,
+	}
+	post.Prepare()
+	_, err = post.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+
+	foundPost, err := postInstance.FindPostById(server.DB, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error getting the post: %v\n", err)
+		return
+	}
+	// the markdown is kept as written
+	assert.Equal(t, foundPost.Content, post.Content)
+	assert.Contains(t, foundPost.ContentHTML, "<strong>bold</strong>")
+	assert.Contains(t, foundPost.ContentHTML, `<code class="language-go">if a &lt; b {}`)
+	assert.Contains(t, foundPost.ContentHTML, "<table>")
+	assert.NotContains(t, foundPost.ContentHTML, "<script>")
+}
+
+func TestPostSummary(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("Cannot seed profile %v\n", err)
+	}
+
+	post := models.Post{
+		Title:    "Summary",
+		Content:  "# Getting started\n\n" + strings.Repeat("word ", 200) + "\n\n## Next steps\n\n```go\nfunc main() {}\n```\n\n![diagram](img/diagram.png)",
+		AuthorID: profile.ID,
+	}
+	post.Prepare()
+	_, err = post.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+
+	posts, err := postInstance.FindAllPosts(server.DB, 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 1)
+
+	foundPost := (*posts)[0]
+	assert.Equal(t, foundPost.TOC, []postformator.Heading{
+		{Level: 1, Text: "Getting started", Anchor: "getting-started"},
+		{Level: 2, Text: "Next steps", Anchor: "next-steps"},
+	})
+	assert.Contains(t, foundPost.ContentHTML, `<h1 id="getting-started">`)
+	assert.Equal(t, foundPost.WordCount, 204)
+	assert.Equal(t, foundPost.Excerpt, strings.TrimSpace(strings.Repeat("word ", models.ExcerptWords()))+"…")
+	// the words alone take less than a minute, the code and the image push it over
+	assert.Equal(t, foundPost.ReadTime, "2 min read")
+
+	// an edit leaving only an image empties the summary instead of keeping the old one
+	postUpdate := models.Post{Title: "Summary", Content: "![diagram](img/diagram.png)", AuthorID: profile.ID}
+	postUpdate.ID = foundPost.ID
+	postUpdate.Prepare()
+	_, err = postUpdate.UpdateAPost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error updating the post: %v\n", err)
+		return
+	}
+	updatedPost, err := postInstance.FindPostById(server.DB, uint64(foundPost.ID))
+	if err != nil {
+		t.Errorf("this is the error getting the post: %v\n", err)
+		return
+	}
+	assert.Empty(t, updatedPost.TOC)
+	assert.Equal(t, updatedPost.WordCount, 0)
+	assert.Equal(t, updatedPost.Excerpt, "")
+}
+
+func TestRenderLegacyPostsOnce(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("Cannot seed profile %v\n", err)
+	}
+
+	// the legacy escaper stored "Tom &amp; Jerry" escaped, without any HTML
+	legacy := models.Post{Title: "Legacy", Content: "Tom &amp;amp; Jerry", AuthorID: profile.ID}
+	err = server.DB.Model(&models.Post{}).Create(&legacy).Error
+	if err != nil {
+		log.Fatalf("cannot seed the legacy post: %v", err)
+	}
+	// a markdown post written with an entity keeps it
+	post := models.Post{Title: "Markdown", Content: "Tom &amp; Jerry", AuthorID: profile.ID}
+	post.Prepare()
+	_, err = post.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+
+	for i := 0; i < 2; i++ {
+		err = models.RenderLegacyPosts(server.DB)
+		if err != nil {
+			t.Errorf("this is the error rendering the legacy posts: %v\n", err)
+			return
+		}
+	}
+
+	for _, p := range []models.Post{legacy, post} {
+		found, err := postInstance.FindPostById(server.DB, uint64(p.ID))
+		if err != nil {
+			t.Errorf("this is the error getting the post: %v\n", err)
+			return
+		}
+		assert.Equal(t, found.Content, "Tom &amp; Jerry")
+		assert.True(t, found.Rendered)
+	}
+}
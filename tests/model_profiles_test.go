@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -19,7 +20,7 @@ func TestFindAllUsersProfile(t *testing.T) {
 		log.Fatal(err)
 	}
 
-	profile, err := profileInstance.FindAllUsersProfile(server.DB)
+	profile, err := profileInstance.FindAllUsersProfile(server.DB, pagination.Default())
 	if err != nil {
 		t.Errorf("this is the error getting the profiles: %v\n", err)
 		return
@@ -143,6 +144,34 @@ func TestUpdateUserProfile(t *testing.T) {
 	}
 }
 
+func TestUpdateUserProfileKeepsTheModeration(t *testing.T) {
+	err := refreshUserProfileTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	profile, err := seedOneUserProfile()
+	if err != nil {
+		log.Fatalf("cannot seed profile table: %v", err)
+	}
+	profileID := uint32(profile.ID)
+
+	moderate := true
+	updateProfile := models.Profile{Name: profile.Name, ModerateComments: &moderate}
+	updateProfile.Prepare()
+	updatedProfile, err := updateProfile.UpdateAUserProfile(server.DB, profileID)
+	assert.Nil(t, err)
+	assert.True(t, *updatedProfile.ModerateComments)
+
+	// a profile saved without the setting keeps it
+	updateProfile = models.Profile{Name: profile.Name, Bio: "another bio"}
+	updateProfile.Prepare()
+	updatedProfile, err = updateProfile.UpdateAUserProfile(server.DB, profileID)
+	assert.Nil(t, err)
+	assert.True(t, *updatedProfile.ModerateComments)
+	assert.Equal(t, updatedProfile.Bio, "another bio")
+}
+
 func TestDeleteUserProfile(t *testing.T) {
 	err := refreshUserProfileTable()
 	if err != nil {
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/auth"
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func refreshUserAndRefreshTokenTable() error {
+	migrator := server.DB.Migrator()
+
+	// Drop the User and RefreshToken tables if they exist
+	err := migrator.DropTable(&models.User{}, &models.RefreshToken{})
+	if err != nil {
+		return err
+	}
+
+	// AutoMigrate to create the User and RefreshToken tables
+	err = server.DB.AutoMigrate(&models.User{}, &models.RefreshToken{})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Successfully refreshed user and refresh token tables")
+	return nil
+}
+
+func seedRefreshToken(uid uint32) (models.RefreshToken, string) {
+	plainToken, tokenHash, err := auth.NewRefreshToken()
+	if err != nil {
+		log.Fatalf("cannot create refresh token: %v\n", err)
+	}
+	familyID, err := auth.NewTokenFamily()
+	if err != nil {
+		log.Fatalf("cannot create token family: %v\n", err)
+	}
+
+	refreshToken := models.RefreshToken{
+		UserID:    uid,
+		TokenHash: tokenHash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	err = server.DB.Model(&models.RefreshToken{}).Create(&refreshToken).Error
+	if err != nil {
+		log.Fatalf("cannot seed refresh tokens table: %v\n", err)
+	}
+	return refreshToken, plainToken
+}
+
+func TestFindRefreshTokenByHash(t *testing.T) {
+	err := refreshUserAndRefreshTokenTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	user, err := seedOneUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+	seeded, plainToken := seedRefreshToken(uint32(user.ID))
+
+	refreshToken := models.RefreshToken{}
+	found, err := refreshToken.FindRefreshTokenByHash(server.DB, auth.HashRefreshToken(plainToken))
+	if err != nil {
+		t.Errorf("this is the error getting the refresh token: %v\n", err)
+		return
+	}
+	assert.Equal(t, found.ID, seeded.ID)
+	assert.Nil(t, found.CheckUsable())
+
+	_, err = refreshToken.FindRefreshTokenByHash(server.DB, auth.HashRefreshToken("not a token"))
+	assert.Equal(t, err, models.ErrRefreshTokenNotFound)
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	err := refreshUserAndRefreshTokenTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	user, err := seedOneUser()
+	if err != nil {
+		log.Fatal(err)
+	}
+	seeded, _ := seedRefreshToken(uint32(user.ID))
+
+	_, nextHash, err := auth.NewRefreshToken()
+	if err != nil {
+		log.Fatal(err)
+	}
+	next := models.RefreshToken{TokenHash: nextHash, ExpiresAt: time.Now().Add(time.Hour)}
+	rotated, err := seeded.Rotate(server.DB, &next)
+	if err != nil {
+		t.Errorf("this is the error rotating the refresh token: %v\n", err)
+		return
+	}
+	assert.Equal(t, rotated.FamilyID, seeded.FamilyID)
+	assert.Equal(t, rotated.UserID, seeded.UserID)
+
+	// The old token is now revoked and replaced, presenting it again is a reuse
+	old := models.RefreshToken{}
+	err = server.DB.Model(&models.RefreshToken{}).Where("id = ?", seeded.ID).Take(&old).Error
+	if err != nil {
+		log.Fatal(err)
+	}
+	assert.Equal(t, old.CheckUsable(), models.ErrRefreshTokenReused)
+
+	// Rotating the same token twice must fail
+	_, otherHash, _ := auth.NewRefreshToken()
+	_, err = old.Rotate(server.DB, &models.RefreshToken{TokenHash: otherHash, ExpiresAt: time.Now().Add(time.Hour)})
+	assert.Equal(t, err, models.ErrRefreshTokenReused)
+
+	revoked, err := old.RevokeRefreshTokenFamily(server.DB, seeded.FamilyID)
+	if err != nil {
+		t.Errorf("this is the error revoking the family: %v\n", err)
+		return
+	}
+	assert.Equal(t, revoked, int64(1))
+}
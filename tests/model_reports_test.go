@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportsHideTheirTarget(t *testing.T) {
+	err := refreshUserProfilePostAndCommentTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post and comment table %v\n", err)
+	}
+	err = server.DB.Migrator().DropTable(&models.Report{})
+	if err != nil {
+		log.Fatalf("Error dropping the reports %v\n", err)
+	}
+	err = server.DB.AutoMigrate(&models.Report{}, &models.AuditLog{})
+	if err != nil {
+		log.Fatalf("Error migrating the reports %v\n", err)
+	}
+	_, profiles, comments, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Error seeding user, post and comment table %v\n", err)
+	}
+	t.Setenv("REPORT_HIDE_THRESHOLD", "2")
+
+	report := func(reporterID uint) models.Report {
+		r := models.Report{ReporterID: uint32(reporterID), TargetType: "reply", TargetID: uint64(comments[0].ID), Reason: "spam"}
+		r.Prepare()
+		return r
+	}
+
+	first := report(profiles[0].ID)
+	assert.Equal(t, len(first.Validate()), 0)
+	_, hidden, err := first.SaveReport(server.DB)
+	assert.Nil(t, err)
+	assert.False(t, hidden)
+	assert.Equal(t, first.TargetType, models.ReportTargetComment)
+
+	// a reader reports the same content once
+	again := report(profiles[0].ID)
+	_, _, err = again.SaveReport(server.DB)
+	assert.ErrorIs(t, err, models.ErrAlreadyReported)
+
+	second := report(profiles[1].ID)
+	_, hidden, err = second.SaveReport(server.DB)
+	assert.Nil(t, err)
+	assert.True(t, hidden)
+
+	var visible int64
+	server.DB.Model(&models.Comment{}).Scopes(models.NotSuspended).Where("id = ?", comments[0].ID).Count(&visible)
+	assert.Equal(t, visible, int64(0))
+
+	// the reports were unfounded, the comment is back
+	resolved, err := second.ResolveReports(server.DB, models.ReportActionDismiss, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, resolved, int64(2))
+	server.DB.Model(&models.Comment{}).Scopes(models.NotSuspended).Where("id = ?", comments[0].ID).Count(&visible)
+	assert.Equal(t, visible, int64(1))
+
+	// dismissing reports that did not hide the comment leaves a suspension of an admin alone
+	_, err = models.SuspendRecord(server.DB, &models.Comment{}, uint64(comments[1].ID))
+	assert.Nil(t, err)
+	other := models.Report{ReporterID: uint32(profiles[0].ID), TargetType: models.ReportTargetComment, TargetID: uint64(comments[1].ID), Reason: "spam"}
+	_, hidden, err = other.SaveReport(server.DB)
+	assert.Nil(t, err)
+	assert.False(t, hidden)
+	resolved, err = other.ResolveReports(server.DB, models.ReportActionDismiss, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, resolved, int64(1))
+	server.DB.Model(&models.Comment{}).Scopes(models.NotSuspended).Where("id = ?", comments[1].ID).Count(&visible)
+	assert.Equal(t, visible, int64(0))
+}
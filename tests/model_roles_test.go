@@ -0,0 +1,36 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateAUserRole(t *testing.T) {
+	err := refreshUserTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	user, err := seedOneUser()
+	if err != nil {
+		log.Fatalf("Cannot seed user: %v\n", err)
+	}
+	assert.Equal(t, user.Role, models.RoleAuthor)
+
+	roleUpdate := models.User{Role: models.RoleEditor}
+	updatedUser, err := roleUpdate.UpdateAUserRole(server.DB, uint32(user.ID))
+	if err != nil {
+		t.Errorf("this is the error updating the role: %v\n", err)
+		return
+	}
+	assert.Equal(t, updatedUser.Role, models.RoleEditor)
+	assert.True(t, models.RoleHasPermission(updatedUser.Role, models.PermissionModeratePosts))
+	assert.False(t, models.RoleHasPermission(updatedUser.Role, models.PermissionManageUsers))
+
+	invalidRole := models.User{Role: "owner"}
+	_, err = invalidRole.UpdateAUserRole(server.DB, uint32(user.ID))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,100 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSaveSeries(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+
+	profiles, posts, err := seedUsersProfileAndPosts()
+	if err != nil {
+		log.Fatalf("Cannot seed profiles and posts %v\n", err)
+	}
+	owner := profiles[0]
+
+	part2 := models.Post{Title: "Part 2", Content: "the content", AuthorID: owner.ID}
+	part2.Prepare()
+	_, err = part2.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+
+	series := models.Series{Title: "The tutorial", ProfileID: owner.ID, PostIDs: []uint{part2.ID, posts[0].ID}}
+	_, err = series.SaveSeries(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the series: %v\n", err)
+		return
+	}
+
+	navigation, err := models.FindSeriesNavigation(server.DB, posts[0].ID, 0)
+	if err != nil {
+		t.Errorf("this is the error getting the navigation: %v\n", err)
+		return
+	}
+	assert.Equal(t, navigation.Position, 2)
+	assert.Equal(t, navigation.Total, 2)
+	assert.Equal(t, navigation.Previous.ID, part2.ID)
+	assert.Nil(t, navigation.Next)
+
+	// reordering puts the first post first
+	err = series.ReorderSeriesPosts(server.DB, []uint{posts[0].ID, part2.ID})
+	if err != nil {
+		t.Errorf("this is the error reordering the series: %v\n", err)
+		return
+	}
+	foundSeries := models.Series{}
+	_, err = foundSeries.FindSeriesByID(server.DB, uint64(series.ID), 0)
+	if err != nil {
+		t.Errorf("this is the error getting the series: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(foundSeries.Posts), 2)
+	assert.Equal(t, foundSeries.Posts[0].ID, posts[0].ID)
+	assert.Equal(t, foundSeries.Posts[1].ID, part2.ID)
+
+	// the post of another profile cannot be added
+	err = series.ReorderSeriesPosts(server.DB, []uint{posts[0].ID, posts[1].ID})
+	assert.ErrorIs(t, err, models.ErrSeriesPostNotOwned)
+
+	// a post that is not in a series has no navigation
+	navigation, err = models.FindSeriesNavigation(server.DB, posts[1].ID, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, navigation)
+
+	seriesList, err := series.FindAllSeries(server.DB, owner.ID, 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the series: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*seriesList), 1)
+	assert.Equal(t, (*seriesList)[0].PostCount, int64(2))
+
+	// a draft is counted for its author only, like it is shown
+	err = server.DB.Model(&models.Post{}).Where("id = ?", part2.ID).UpdateColumn("status", models.PostStatusDraft).Error
+	if err != nil {
+		t.Errorf("this is the error turning the post into a draft: %v\n", err)
+		return
+	}
+	seriesList, err = series.FindAllSeries(server.DB, owner.ID, 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the series: %v\n", err)
+		return
+	}
+	assert.Equal(t, (*seriesList)[0].PostCount, int64(1))
+	seriesList, err = series.FindAllSeries(server.DB, owner.ID, owner.ID, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the series: %v\n", err)
+		return
+	}
+	assert.Equal(t, (*seriesList)[0].PostCount, int64(2))
+}
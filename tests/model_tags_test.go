@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"log"
+	"testing"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func refreshTagTable() error {
+	err := server.DB.Migrator().DropTable(&models.Tag{})
+	if err != nil {
+		return err
+	}
+	return server.DB.AutoMigrate(&models.Tag{})
+}
+
+func TestSyncPostTags(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+	err = refreshTagTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, post, err := seedOneUserProfileAndOnePost()
+	if err != nil {
+		log.Fatalf("Cannot seed profile and post %v\n", err)
+	}
+
+	// both spellings end up in the same tag
+	err = post.SyncTags(server.DB, []string{"Go Lang", "go_lang", "Web"})
+	if err != nil {
+		t.Errorf("this is the error syncing the tags: %v\n", err)
+		return
+	}
+	assert.Equal(t, post.Tags, []string{"Go Lang", "Web"})
+
+	foundPost, err := postInstance.FindPostById(server.DB, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error getting the post: %v\n", err)
+		return
+	}
+	assert.ElementsMatch(t, foundPost.Tags, []string{"Go Lang", "Web"})
+
+	tag := models.Tag{}
+	goTag, err := tag.FindTagBySlug(server.DB, "go-lang")
+	if err != nil {
+		t.Errorf("this is the error getting the tag: %v\n", err)
+		return
+	}
+	posts, err := postInstance.FindTagPosts(server.DB, goTag.ID, 0, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the tag posts: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*posts), 1)
+}
+
+func TestRenameAndMergeTags(t *testing.T) {
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, profile and post table %v\n", err)
+	}
+	err = refreshTagTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, post, err := seedOneUserProfileAndOnePost()
+	if err != nil {
+		log.Fatalf("Cannot seed profile and post %v\n", err)
+	}
+	err = post.SyncTags(server.DB, []string{"golang", "go"})
+	if err != nil {
+		log.Fatalf("Cannot seed the tags %v\n", err)
+	}
+
+	tags, err := models.FindOrCreateTags(server.DB, []string{"golang", "go"})
+	if err != nil {
+		t.Errorf("this is the error getting the tags: %v\n", err)
+		return
+	}
+	golang, goTag := tags[0], tags[1]
+
+	_, err = golang.RenameTag(server.DB, "Go")
+	assert.Equal(t, err, models.ErrTagExists)
+
+	renamed, err := golang.RenameTag(server.DB, "Go Language")
+	if err != nil {
+		t.Errorf("this is the error renaming the tag: %v\n", err)
+		return
+	}
+	assert.Equal(t, renamed.Slug, "go-language")
+
+	_, err = goTag.MergeTag(server.DB, &golang)
+	if err != nil {
+		t.Errorf("this is the error merging the tags: %v\n", err)
+		return
+	}
+
+	allTags, err := golang.FindAllTags(server.DB, pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error getting the tags: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(*allTags), 1)
+	assert.Equal(t, (*allTags)[0].PostCount, int64(1))
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRestorePostFromTrash(t *testing.T) {
+	err := refreshUserProfilePostAndCommentReplyeTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post, comment and replye table %v\n", err)
+	}
+	post, _, comment, _, err := seedUsersProfilePostsAndCommentReplyes()
+	if err != nil {
+		log.Fatalf("Error seeding user, post, comment and replye table %v\n", err)
+	}
+
+	post.DeletedBy = uint32(post.AuthorID)
+	err = post.DeletePostCascade(server.DB)
+	if err != nil {
+		t.Errorf("this is the error deleting the post: %v\n", err)
+		return
+	}
+
+	trashed := []models.Post{}
+	err = models.FindTrashed(server.DB, &models.Post{}, &trashed, "author_id", uint32(post.AuthorID), pagination.Default())
+	if err != nil {
+		t.Errorf("this is the error listing the trash: %v\n", err)
+		return
+	}
+	assert.Equal(t, len(trashed), 1)
+
+	// a comment of a deleted post cannot come back on its own
+	trashedComment := models.Comment{}
+	err = server.DB.Unscoped().Model(&models.Comment{}).Where("id = ?", comment.ID).Take(&trashedComment).Error
+	if err != nil {
+		t.Errorf("this is the error getting the deleted comment: %v\n", err)
+		return
+	}
+	err = models.FindTrashedRecord(server.DB, &trashedComment, uint64(comment.ID), "profile_id", trashedComment.ProfileID)
+	if err != nil {
+		t.Errorf("this is the error getting the deleted comment: %v\n", err)
+		return
+	}
+	err = trashedComment.RestoreCommentCascade(server.DB)
+	assert.ErrorIs(t, err, models.ErrTrashParentDeleted)
+
+	err = trashed[0].RestorePostCascade(server.DB)
+	if err != nil {
+		t.Errorf("this is the error restoring the post: %v\n", err)
+		return
+	}
+	var comments, replyes int64
+	server.DB.Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NULL", post.ID).Count(&comments)
+	server.DB.Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replyes)
+	assert.Equal(t, comments, int64(2))
+	assert.Equal(t, replyes, int64(2))
+}
+
+func TestTrashKeepsWhatOthersDeleted(t *testing.T) {
+	err := refreshUserProfilePostAndCommentReplyeTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post, comment and replye table %v\n", err)
+	}
+	post, profiles, _, replyes, err := seedUsersProfilePostsAndCommentReplyes()
+	if err != nil {
+		log.Fatalf("Error seeding user, post, comment and replye table %v\n", err)
+	}
+
+	// the owner of the post deletes a reply, its author cannot take it back
+	reply := replyes[0]
+	reply.DeletedBy = uint32(post.AuthorID)
+	_, err = reply.DeleteAComment(server.DB)
+	if err != nil {
+		t.Errorf("this is the error deleting the replye: %v\n", err)
+		return
+	}
+	trashedComments := []models.Comment{}
+	err = models.FindTrashed(server.DB, &models.Comment{}, &trashedComments, "profile_id", reply.ProfileID, pagination.Default())
+	assert.Nil(t, err)
+	assert.Equal(t, len(trashedComments), 0)
+	err = models.FindTrashedRecord(server.DB, &models.Comment{}, uint64(reply.ID), "profile_id", reply.ProfileID)
+	assert.NotNil(t, err)
+
+	// its author deletes the other one and can restore it
+	reply = replyes[1]
+	reply.DeletedBy = uint32(profiles[1].ID)
+	_, err = reply.DeleteAComment(server.DB)
+	if err != nil {
+		t.Errorf("this is the error deleting the replye: %v\n", err)
+		return
+	}
+	err = models.FindTrashed(server.DB, &models.Comment{}, &trashedComments, "profile_id", reply.ProfileID, pagination.Default())
+	assert.Nil(t, err)
+	assert.Equal(t, len(trashedComments), 1)
+
+	// an editor deletes the post, its author cannot take it back
+	post.DeletedBy = uint32(profiles[1].ID)
+	err = post.DeletePostCascade(server.DB)
+	if err != nil {
+		t.Errorf("this is the error deleting the post: %v\n", err)
+		return
+	}
+	err = models.FindTrashedRecord(server.DB, &models.Post{}, uint64(post.ID), "author_id", uint32(post.AuthorID))
+	assert.NotNil(t, err)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	err := refreshUserProfilePostAndCommentReplyeTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post, comment and replye table %v\n", err)
+	}
+	post, _, _, _, err := seedUsersProfilePostsAndCommentReplyes()
+	if err != nil {
+		log.Fatalf("Error seeding user, post, comment and replye table %v\n", err)
+	}
+
+	err = post.DeletePostCascade(server.DB)
+	if err != nil {
+		t.Errorf("this is the error deleting the post: %v\n", err)
+		return
+	}
+
+	// nothing is older than the retention yet
+	purged, err := models.PurgeTrash(server.DB, time.Now().Add(-models.TrashRetention()))
+	assert.Nil(t, err)
+	assert.Equal(t, purged, int64(0))
+
+	purged, err = models.PurgeTrash(server.DB, time.Now().Add(time.Minute))
+	assert.Nil(t, err)
+	assert.Equal(t, purged, int64(1))
+
+	var posts, replyes int64
+	server.DB.Unscoped().Model(&models.Post{}).Where("id = ?", post.ID).Count(&posts)
+	server.DB.Unscoped().Model(&models.Comment{}).Where("post_id = ? AND parent_id IS NOT NULL", post.ID).Count(&replyes)
+	assert.Equal(t, posts, int64(0))
+	assert.Equal(t, replyes, int64(0))
+}
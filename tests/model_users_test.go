@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/utils/pagination"
 	"github.com/stretchr/testify/assert"
 	_ "gorm.io/driver/mysql"    //mysql driver
 	_ "gorm.io/driver/postgres" //postgres driver
@@ -21,7 +22,7 @@ func TestFindAllUsers(t *testing.T) {
 		log.Fatal(err)
 	}
 
-	users, err := userInstance.FindAllUsers(server.DB)
+	users, err := userInstance.FindAllUsers(server.DB, pagination.Default())
 	if err != nil {
 		t.Errorf("this is the error getting the users: %v\n", err)
 		return
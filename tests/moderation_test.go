@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"log"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/moderation"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerationFilters(t *testing.T) {
+	comment := func(authorID uint32, text string) moderation.Content {
+		return moderation.Content{Kind: moderation.KindComment, AuthorID: authorID, Text: text}
+	}
+
+	blocklist := moderation.NewBlocklist([]string{"Casino", "free money"})
+	reason, _ := blocklist.Check(comment(1, "Best CASINO in town"))
+	assert.NotEqual(t, reason, "")
+	reason, _ = blocklist.Check(comment(1, "Get FREE, money now"))
+	assert.NotEqual(t, reason, "")
+	reason, _ = blocklist.Check(comment(1, "Casinos are a nice topic"))
+	assert.Equal(t, reason, "")
+
+	links := moderation.LinkLimit{moderation.KindComment: 2}
+	reason, _ = links.Check(comment(1, "see https://a.example and www.b.example"))
+	assert.Equal(t, reason, "")
+	reason, _ = links.Check(comment(1, "see https://a.example, http://b.example and www.c.example"))
+	assert.NotEqual(t, reason, "")
+
+	duplicate := moderation.NewDuplicate(time.Hour)
+	text := "I really liked the part about the migrations"
+	saved := comment(1, text)
+	saved.ID = 10
+	duplicate.Record(saved)
+	// saving the same comment again is fine, writing it again is not
+	reason, _ = duplicate.Check(saved)
+	assert.Equal(t, reason, "")
+	reason, _ = duplicate.Check(comment(1, "I really liked the part about the MIGRATIONS!"))
+	assert.NotEqual(t, reason, "")
+	reason, _ = duplicate.Check(comment(2, text))
+	assert.Equal(t, reason, "")
+
+	bayes := moderation.NewBayes(0.9, 2)
+	reason, _ = bayes.Check(comment(1, "cheap pills online"))
+	assert.Equal(t, reason, "", "an untrained classifier lets everything through")
+	bayes.Train("cheap pills online buy now", true)
+	bayes.Train("buy cheap watches online now", true)
+	bayes.Train("great post about go generics", false)
+	bayes.Train("thanks for explaining the gorm hooks", false)
+	reason, _ = bayes.Check(comment(1, "buy cheap pills now"))
+	assert.NotEqual(t, reason, "")
+	reason, _ = bayes.Check(comment(1, "thanks for the post about generics"))
+	assert.Equal(t, reason, "")
+}
+
+func TestFlaggedContentIsHeldForReview(t *testing.T) {
+	err := refreshUserProfilePostAndCommentTable()
+	if err != nil {
+		log.Fatalf("Error refreshing user, post and comment table %v\n", err)
+	}
+	post, profiles, _, err := seedUsersProfilePostsAndComments()
+	if err != nil {
+		log.Fatalf("Error seeding user, post and comment table %v\n", err)
+	}
+
+	defaultFilter := moderation.Default
+	moderation.Default = moderation.NewBlocklist([]string{"casino"})
+	defer func() { moderation.Default = defaultFilter }()
+
+	comment := models.Comment{
+		Body:      "Visit my casino",
+		ProfileID: uint32(profiles[1].ID),
+		PostID:    uint64(post.ID),
+	}
+	saved, err := comment.SaveComment(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the comment: %v\n", err)
+		return
+	}
+	assert.Equal(t, saved.Status, models.CommentStatusPending)
+	assert.NotEqual(t, saved.FlagReason, "")
+
+	// a clean edit shows the comment again
+	saved.Body = "Visit my blog"
+	edited, err := saved.UpdateAComment(server.DB)
+	if err != nil {
+		t.Errorf("this is the error updating the comment: %v\n", err)
+		return
+	}
+	assert.Equal(t, edited.Status, models.CommentStatusApproved)
+	assert.Equal(t, edited.FlagReason, "")
+
+	flagged := models.Post{
+		Title:    "The casino post",
+		Content:  "This is the content",
+		AuthorID: profiles[0].ID,
+	}
+	flagged.Prepare()
+	savedPost, err := flagged.SavePost(server.DB)
+	if err != nil {
+		t.Errorf("this is the error saving the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, savedPost.Status, models.PostStatusReview)
+
+	approved, err := savedPost.EndReview(server.DB, true)
+	if err != nil {
+		t.Errorf("this is the error approving the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, approved.Status, models.PostStatusPublished)
+}
+
+func TestLabelSpam(t *testing.T) {
+	err := server.DB.Migrator().DropTable(&models.SpamSample{})
+	if err != nil {
+		log.Fatalf("Error dropping the spam samples %v\n", err)
+	}
+	err = server.DB.AutoMigrate(&models.SpamSample{})
+	if err != nil {
+		log.Fatalf("Error migrating the spam samples %v\n", err)
+	}
+
+	sample := models.SpamSample{Kind: moderation.KindComment, RecordID: 1, Text: "cheap pills", Spam: true, LabelledBy: 1}
+	_, err = sample.LabelSpam(server.DB)
+	if err != nil {
+		t.Errorf("this is the error labelling the comment: %v\n", err)
+		return
+	}
+
+	// labelling it again changes the label of the same sample
+	relabel := models.SpamSample{Kind: moderation.KindComment, RecordID: 1, Text: "cheap pills", Spam: false, LabelledBy: 1}
+	_, err = relabel.LabelSpam(server.DB)
+	if err != nil {
+		t.Errorf("this is the error labelling the comment again: %v\n", err)
+		return
+	}
+
+	var samples int64
+	server.DB.Model(&models.SpamSample{}).Where("kind = ? AND record_id = ? AND spam = ?", moderation.KindComment, 1, false).Count(&samples)
+	assert.Equal(t, samples, int64(1))
+	server.DB.Model(&models.SpamSample{}).Count(&samples)
+	assert.Equal(t, samples, int64(1))
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/Mdromi/exp-blog-backend/api/controllers"
 	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/storage"
 	"github.com/joho/godotenv"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -24,7 +25,6 @@ var linksInstance = models.SocialLink{}
 var postInstance = models.Post{}
 var likeInstance = models.LikeDislike{}
 var commentInstance = models.Comment{}
-var commentReplyesInstance = models.Replyes{}
 
 func TestMain(m *testing.M) {
 	//Since we add our .env in .gitignore, Circle CI cannot see it, so see the else statement
@@ -39,6 +39,16 @@ func TestMain(m *testing.M) {
 	} else {
 		CIBuild()
 	}
+
+	// the uploads of the tests never touch the disk
+	server.Storage = storage.NewMemory("/static/uploads")
+	storage.Signer = storage.NewURLSigner(server.Storage, "media-secret", "/api/v1/media", time.Hour)
+
+	// SignIn stores a refresh token, so the table has to exist for every test that logs in
+	err := server.DB.AutoMigrate(&models.RefreshToken{}, &models.Tag{}, &models.PostSlug{}, &models.PostRevision{}, &models.Series{}, &models.SeriesPost{}, &models.PostCollaborator{}, &models.PostMedia{}, &models.MediaObject{}, &models.SpamSample{}, &models.Report{})
+	if err != nil {
+		log.Fatalf("Error migrating refresh tokens %v\n", err)
+	}
 	os.Exit(m.Run())
 }
 
@@ -119,6 +129,10 @@ func getRandomNonDuplicate(username string) (string, string) {
 func refreshAllTable() error {
 	migrator := server.DB.Migrator()
 
+	if err := dropPostTables(); err != nil {
+		return err
+	}
+
 	// Drop the Profile table if it exists
 	err := migrator.DropTable(&models.User{}, &models.Profile{}, &models.SocialLink{}, &models.ResetPassword{}, &models.Post{}, &models.LikeDislike{}, &models.Comment{}, models.Replyes{})
 	if err != nil {
@@ -126,7 +140,7 @@ func refreshAllTable() error {
 	}
 
 	// AutoMigrate to create the Profile table
-	err = server.DB.AutoMigrate(&models.User{}, &models.Profile{}, &models.SocialLink{}, &models.ResetPassword{}, &models.Post{}, &models.LikeDislike{}, &models.Comment{}, models.Replyes{})
+	err = server.DB.AutoMigrate(&models.User{}, &models.Profile{}, &models.SocialLink{}, &models.ResetPassword{}, &models.Post{}, &models.LikeDislike{}, &models.Comment{})
 	if err != nil {
 		fmt.Println("err", err)
 		return err
@@ -136,6 +150,20 @@ func refreshAllTable() error {
 	return nil
 }
 
+// the post_tags join table has no model to drop it with, and it has to go before the posts it references.
+// The permalink and revision histories, the series, the collaborators and the media go with them, so the slugs of the dropped posts are free again.
+func dropPostTables() error {
+	err := server.DB.Exec("DROP TABLE IF EXISTS post_tags").Error
+	if err != nil {
+		return err
+	}
+	err = server.DB.Migrator().DropTable(&models.PostSlug{}, &models.PostRevision{}, &models.SeriesPost{}, &models.Series{}, &models.PostCollaborator{}, &models.PostMedia{}, &models.MediaObject{})
+	if err != nil {
+		return err
+	}
+	return server.DB.AutoMigrate(&models.PostSlug{}, &models.PostRevision{}, &models.Series{}, &models.SeriesPost{}, &models.PostCollaborator{}, &models.PostMedia{}, &models.MediaObject{})
+}
+
 func refreshUserTable() error {
 	migrator := server.DB.Migrator()
 
@@ -298,6 +326,10 @@ func seedUsersProfiles() ([]*models.Profile, error) {
 func refreshUserAndPostTable() error {
 	migrator := server.DB.Migrator()
 
+	if err := dropPostTables(); err != nil {
+		return err
+	}
+
 	// Drop the User and Post tables if they exist
 	err := migrator.DropTable(&models.User{}, &models.Post{})
 	if err != nil {
@@ -317,6 +349,10 @@ func refreshUserAndPostTable() error {
 func refreshUserProfileAndPostTable() error {
 	migrator := server.DB.Migrator()
 
+	if err := dropPostTables(); err != nil {
+		return err
+	}
+
 	// Drop the User and Post tables if they exist
 	err := migrator.DropTable(&models.User{}, &models.Post{}, &models.Profile{})
 	if err != nil {
@@ -389,6 +425,10 @@ func refreshUserProfilePostAndLikeTable() error {
 
 	var err error
 
+	if err := dropPostTables(); err != nil {
+		return err
+	}
+
 	// Drop the User, Profile, Post, and Like tables if they exist
 	err = migrator.DropTable(&models.User{}, &models.Profile{}, &models.Post{}, &models.LikeDislike{})
 	if err != nil {
@@ -450,6 +490,10 @@ func seedUsersProfilePostsAndLikes() (*models.Post, []*models.Profile, []*models
 func refreshUserProfilePostAndCommentTable() error {
 	migrator := server.DB.Migrator()
 
+	if err := dropPostTables(); err != nil {
+		return err
+	}
+
 	// Drop the User, Post, and Comment tables if they exist
 	err := migrator.DropTable(&models.User{}, &models.Profile{}, &models.Post{}, &models.Comment{})
 	if err != nil {
@@ -511,41 +555,26 @@ func seedUsersProfilePostsAndComments() (models.Post, []*models.Profile, []model
 func refreshUserProfilePostAndCommentReplyeTable() error {
 	migrator := server.DB.Migrator()
 
-	// Drop the User, Post, and Comment tables if they exist
-	err := migrator.DropTable(&models.User{}, &models.Profile{}, &models.Post{}, &models.Comment{}, &models.Replyes{})
-	if err != nil {
+	if err := dropPostTables(); err != nil {
 		return err
 	}
 
-	// AutoMigrate to create the User, Post, and Comment tables
-	err = server.DB.AutoMigrate(&models.User{}, &models.Profile{}, &models.Post{}, &models.Comment{}, &models.Replyes{})
-	if err != nil {
-		return err
-	}
-
-	log.Printf("Successfully refreshed user, post, comment, and replye tables")
-	return nil
-}
-func refreshReplyeTable() error {
-	migrator := server.DB.Migrator()
-
-	// Drop the User, Post, and Comment tables if they exist
-	err := migrator.DropTable(&models.Replyes{})
+	// Drop the User, Post, and Comment tables if they exist, the replies are comments too
+	err := migrator.DropTable(&models.User{}, &models.Profile{}, &models.Post{}, &models.Comment{}, &models.Replyes{})
 	if err != nil {
 		return err
 	}
 
 	// AutoMigrate to create the User, Post, and Comment tables
-	err = server.DB.AutoMigrate(&models.Replyes{})
+	err = server.DB.AutoMigrate(&models.User{}, &models.Profile{}, &models.Post{}, &models.Comment{})
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Successfully refreshed replye tables")
+	log.Printf("Successfully refreshed user, post, comment, and replye tables")
 	return nil
 }
-
-func seedUsersProfilePostsAndCommentReplyes() (models.Post, []*models.Profile, models.Comment, []models.Replyes, error) {
+func seedUsersProfilePostsAndCommentReplyes() (models.Post, []*models.Profile, models.Comment, []models.Comment, error) {
 	post, _, comments, err := seedUsersProfilePostsAndComments()
 	if err != nil {
 		log.Fatalf("Error seeding user, post and comment table %v\n", err)
@@ -556,7 +585,7 @@ func seedUsersProfilePostsAndCommentReplyes() (models.Post, []*models.Profile, m
 		if v.ID == 2 {
 			continue
 		}
-		commentInstance.ID = v.ID //commentInstance is defined in setup_test.go
+		commentInstance = v //commentInstance is defined in setup_test.go
 	}
 
 	profiles, err := seedUsersProfiles()
@@ -564,23 +593,24 @@ func seedUsersProfilePostsAndCommentReplyes() (models.Post, []*models.Profile, m
 		log.Fatalf("cannot seed users profile table on comment replyes: %v", err)
 	}
 
-	var newCommentReplye = []models.Replyes{
-		models.Replyes{
+	parentID := uint64(commentInstance.ID)
+	var newCommentReplye = []models.Comment{
+		models.Comment{
 			Body:      "user 1 made this comment replye",
-			CommentID: uint64(commentInstance.ID),
-			ProfileID: uint64(profiles[0].ID),
-			PostID:    uint32(post.ID),
+			ParentID:  &parentID,
+			ProfileID: uint32(profiles[0].ID),
+			PostID:    uint64(post.ID),
 		},
-		models.Replyes{
+		models.Comment{
 			Body:      "user 2 made this comment replye",
-			CommentID: uint64(commentInstance.ID),
-			ProfileID: uint64(profiles[1].ID),
-			PostID:    uint32(post.ID),
+			ParentID:  &parentID,
+			ProfileID: uint32(profiles[1].ID),
+			PostID:    uint64(post.ID),
 		},
 	}
 
 	for i, _ := range newCommentReplye {
-		err = server.DB.Model(&models.Replyes{}).Create(&newCommentReplye[i]).Error
+		err = server.DB.Model(&models.Comment{}).Create(&newCommentReplye[i]).Error
 		if err != nil {
 			log.Fatalf("cannot seed comment replyes table: %v", err)
 		}
@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mdromi/exp-blog-backend/api/models"
+	"github.com/Mdromi/exp-blog-backend/api/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageBackends(t *testing.T) {
+	backends := map[string]storage.Backend{
+		"local":  storage.NewLocal(t.TempDir(), t.TempDir(), "/static/uploads"),
+		"memory": storage.NewMemory("/static/uploads"),
+	}
+
+	for name, backend := range backends {
+		err := backend.Put("posts/1/a/medium.png", []byte("first"), "image/png")
+		assert.Nil(t, err, name)
+		err = backend.Put("posts/2/b/medium.png", []byte("second"), "image/png")
+		assert.Nil(t, err, name)
+
+		f, err := backend.Open("posts/1/a/medium.png")
+		if err != nil {
+			t.Errorf("%s: this is the error opening the object: %v\n", name, err)
+			continue
+		}
+		data, _ := io.ReadAll(f)
+		f.Close()
+		assert.Equal(t, string(data), "first", name)
+		assert.Equal(t, backend.URL("posts/1/a/medium.png"), "/static/uploads/posts/1/a/medium.png", name)
+
+		keys, err := backend.List("posts/1/")
+		assert.Nil(t, err, name)
+		assert.Equal(t, keys, []string{"posts/1/a/medium.png"}, name)
+
+		err = storage.DeletePrefix(backend, "posts/1/")
+		assert.Nil(t, err, name)
+		_, err = backend.Open("posts/1/a/medium.png")
+		assert.ErrorIs(t, err, storage.ErrNotFound, name)
+
+		// the private objects have no public url
+		err = backend.Put("private/posts/3/c/medium.png", []byte("private"), "image/png")
+		assert.Nil(t, err, name)
+		assert.Equal(t, backend.URL("private/posts/3/c/medium.png"), "", name)
+		keys, err = backend.List("private/")
+		assert.Nil(t, err, name)
+		assert.Equal(t, keys, []string{"private/posts/3/c/medium.png"}, name)
+
+		// keys cannot escape the root of the backend
+		err = backend.Put("../outside.png", []byte("outside"), "image/png")
+		assert.ErrorIs(t, err, storage.ErrInvalidKey, name)
+	}
+}
+
+func TestUploadPostMedia(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	err := refreshUserProfileAndPostTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	profile, tokenString := seedProfileAndSignIn(server.DB)
+
+	post := models.Post{Title: "The post with images", Content: "the content", AuthorID: profile.ID}
+	post.Prepare()
+	_, err = post.SavePost(server.DB)
+	if err != nil {
+		t.Fatalf("this is the error saving the post: %v\n", err)
+	}
+
+	var img bytes.Buffer
+	err = png.Encode(&img, image.NewNRGBA(image.Rect(0, 0, 1000, 500)))
+	if err != nil {
+		t.Fatalf("cannot encode the image: %v\n", err)
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("file", "cover.png")
+	part.Write(img.Bytes())
+	writer.WriteField("thumbnail", "true")
+	writer.Close()
+
+	r := gin.Default()
+	r.POST("/posts/:id/media", server.UploadPostMedia)
+	req, err := http.NewRequest(http.MethodPost, "/posts/"+strconv.Itoa(int(post.ID))+"/media", &body)
+	if err != nil {
+		t.Errorf("this is the error: %v\n", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", tokenString)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, rr.Code, http.StatusCreated)
+
+	responseInterface := make(map[string]interface{})
+	err = json.Unmarshal(rr.Body.Bytes(), &responseInterface)
+	if err != nil {
+		t.Errorf("Cannot convert to json: %v", err)
+		return
+	}
+	responseMap := responseInterface["response"].(map[string]interface{})
+	assert.Equal(t, responseMap["original_name"], "cover.png")
+	assert.Equal(t, responseMap["width"], float64(1000))
+	assert.Equal(t, len(responseMap["variants"].([]interface{})), 4)
+
+	// every version went to the storage backend of the server, as a private object
+	keys, err := server.Storage.List("private/posts/" + strconv.Itoa(int(post.ID)) + "/")
+	assert.Nil(t, err)
+	assert.Equal(t, len(keys), 4)
+
+	found, err := postInstance.FindPostById(server.DB, uint64(post.ID))
+	if err != nil {
+		t.Errorf("this is the error getting the post: %v\n", err)
+		return
+	}
+	assert.Equal(t, strings.Split(found.Thumbnails, "?")[0], strings.Split(found.Media[0].Variant("medium").URL, "?")[0])
+}
+
+func TestSignedMediaURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	err := server.Storage.Put("private/posts/9/a/medium.png", []byte("the image"), "image/png")
+	if err != nil {
+		t.Fatalf("this is the error storing the object: %v\n", err)
+	}
+	expired := storage.NewURLSigner(server.Storage, "media-secret", "/api/v1/media", -time.Minute)
+	signedURL := storage.Signer.URL("private/posts/9/a/medium.png")
+
+	samples := []struct {
+		url        string
+		statusCode int
+	}{
+		{url: signedURL, statusCode: http.StatusOK},
+		{url: expired.URL("private/posts/9/a/medium.png"), statusCode: http.StatusForbidden},
+		// the signature is for another object
+		{url: strings.Replace(signedURL, "/9/", "/8/", 1), statusCode: http.StatusForbidden},
+		{url: "/api/v1/media/private/posts/9/a/medium.png", statusCode: http.StatusForbidden},
+	}
+
+	r := gin.Default()
+	r.GET("/api/v1/media/*key", server.GetMedia)
+	for _, v := range samples {
+		req, err := http.NewRequest(http.MethodGet, v.url, nil)
+		if err != nil {
+			t.Errorf("this is the error: %v\n", err)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, rr.Code, v.statusCode)
+		if v.statusCode == http.StatusOK {
+			assert.Equal(t, rr.Body.String(), "the image")
+			assert.Equal(t, rr.Header().Get("Content-Type"), "image/png")
+		}
+	}
+}
+
+func TestURLSignerNeedsASecret(t *testing.T) {
+	t.Setenv("MEDIA_URL_SECRET", "")
+	t.Setenv("API_SECRET", "")
+	_, err := storage.SignerFromEnv(storage.NewMemory("/static/uploads"))
+	assert.ErrorIs(t, err, storage.ErrNoURLSecret)
+
+	t.Setenv("API_SECRET", "api-secret")
+	signer, err := storage.SignerFromEnv(storage.NewMemory("/static/uploads"))
+	assert.Nil(t, err)
+	assert.Equal(t, signer.Secret, []byte("api-secret"))
+}
@@ -107,7 +107,7 @@ func CreateCommentReplyeSamples(firstUserToken, secondUserToken, secondCommentID
 	}
 }
 
-func GetCommentReplyeSamples(profiles []*models.Profile, replyes []models.Replyes, postID, commentID string) []GetCommentReplyeTestCase {
+func GetCommentReplyeSamples(profiles []*models.Profile, replyes []models.Comment, postID, commentID string) []GetCommentReplyeTestCase {
 	return []GetCommentReplyeTestCase{
 		{
 			CommentID:     commentID,